@@ -31,13 +31,25 @@ import (
 	"k8s.io/apiserver/pkg/cel/environment"
 )
 
+// statelessCELEnvSet is the CEL environment of the matchConditions.
 // nonStrictStatelessCELCompiler is a cel Compiler that does not enforce strict cost enforcement.
+// matchConditionsCompiler compiles the matchConditions in the same environment,
+// to evaluate them.
 //
 //nolint:gochecknoglobals // lets keep the compiler available for the how module
 var (
-	nonStrictStatelessCELCompiler = plugincel.NewCompiler(environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion()))
+	statelessCELEnvSet            = environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion())
+	nonStrictStatelessCELCompiler = plugincel.NewCompiler(statelessCELEnvSet)
+	matchConditionsCompiler       = plugincel.NewConditionCompiler(statelessCELEnvSet)
 )
 
+// MatchConditionsCompiler returns the compiler of the matchConditions, built
+// from the CEL environment used to validate them when a policy is created or
+// updated, so both accept the same expressions.
+func MatchConditionsCompiler() plugincel.ConditionCompiler {
+	return matchConditionsCompiler
+}
+
 const maxMatchConditionsCount = 64
 
 type sensitiveResource struct {
@@ -131,26 +131,27 @@ are configured.
 {{- end -}}
 
 {{- define "audit-scanner.command" -}}
-{{- $parallelNamespaces := .Values.auditScanner.parallelNamespaces | int -}}
+{{- /* the deprecated parallelNamespaces and parallelPolicies values are ignored */ -}}
 {{- $parallelResources := .Values.auditScanner.parallelResources | int -}}
-{{- $parallelPolicies := .Values.auditScanner.parallelPolicies | int -}}
+{{- $maxInflightRequests := .Values.auditScanner.maxInflightRequests | int -}}
+{{- $maxInflightRequestsPerPolicyServer := .Values.auditScanner.maxInflightRequestsPerPolicyServer | int -}}
 {{- $pageSize := .Values.auditScanner.pageSize| int -}}
 - /audit-scanner
 - --kubewarden-namespace
 - {{ .Release.Namespace }}
 - --loglevel
 - {{ .Values.auditScanner.logLevel }}
-{{- if gt $parallelNamespaces 0 }}
-- --parallel-namespaces
-- "{{ $parallelNamespaces }}"
-{{- end }}
 {{- if gt $parallelResources 0 }}
 - --parallel-resources
 - "{{ $parallelResources }}"
 {{- end }}
-{{- if gt $parallelPolicies 0 }}
-- --parallel-policies
-- "{{ $parallelPolicies }}"
+{{- if gt $maxInflightRequests 0 }}
+- --max-inflight-requests
+- "{{ $maxInflightRequests }}"
+{{- end }}
+{{- if gt $maxInflightRequestsPerPolicyServer 0 }}
+- --max-inflight-requests-per-policy-server
+- "{{ $maxInflightRequestsPerPolicyServer }}"
 {{- end }}
 {{- if gt $pageSize 0 }}
 - --page-size
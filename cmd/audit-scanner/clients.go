@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/manifests"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/scheme"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// The sources of the policies used to audit the manifests.
+const (
+	policiesFromManifests = "manifests"
+	policiesFromCluster   = "cluster"
+)
+
+// clientsOptions holds the settings shared by the clients of the scanner.
+type clientsOptions struct {
+	kubewardenNamespace string
+	policyServerURL     string
+	wildcardDenylist    []schema.GroupResource
+	skippedNs           []string
+	pageSize            int64
+	reportKind          report.CrdKind
+	// dualWriteLegacy writes a wgpolicyk8s.io copy of each OpenReports report
+	dualWriteLegacy bool
+	// reportWriter configures the writes of the reports stored in the cluster
+	reportWriter report.WriterConfig
+	// runLockNames are the names of the Leases serializing the scan runs,
+	// one per kind of reports written
+	runLockNames []string
+	logger       *slog.Logger
+}
+
+// auditClients are the clients used by the scanner to fetch the policies and
+// the resources, and to store the reports.
+type auditClients struct {
+	policiesClient *policies.Client
+	k8sClient      *k8s.Client
+	reportStore    report.Store
+	// configMapStore stores the summaries and the checkpoints of the scan runs
+	configMapStore *report.ConfigMapStore
+	// runLocks prevent the runs storing the reports in the cluster from
+	// overlapping. They are empty when the reports are not stored in the
+	// cluster
+	runLocks []*k8s.RunLock
+	// migrateLegacyReports converts the legacy wgpolicyk8s.io reports to
+	// the kind of the reports of the scan. It's nil when there's nothing to
+	// migrate
+	migrateLegacyReports func(ctx context.Context) error
+}
+
+// newClusterClients returns the clients auditing the resources of the cluster
+// with the policies deployed in it.
+func newClusterClients(opts clientsOptions) (*auditClients, error) {
+	config := ctrl.GetConfigOrDie()
+	dynamicClient := dynamic.NewForConfigOrDie(config)
+	clientset := kubernetes.NewForConfigOrDie(config)
+
+	auditScheme, err := scheme.NewScheme()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheme: %w", err)
+	}
+	client, err := client.New(config, client.Options{Scheme: auditScheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	discoveryClient := memory.NewMemCacheClient(clientset.Discovery())
+	var (
+		reportStore          report.Store
+		migrateLegacyReports func(ctx context.Context) error
+	)
+	switch {
+	case opts.dualWriteLegacy:
+		dualWriteStore := report.NewDualWriteStore(client, opts.reportWriter, opts.logger)
+		reportStore = dualWriteStore
+		// the legacy reports are kept up to date, they are only copied
+		migrateLegacyReports = dualWriteStore.ConvertLegacyPolicyReports
+	case opts.reportKind == report.ReportKindOpenReport:
+		openReportStore := report.NewOpenReportStore(client, opts.reportWriter, opts.logger)
+		reportStore = openReportStore
+		migrateLegacyReports = openReportStore.MigrateLegacyPolicyReports
+	default:
+		reportStore = report.NewReportStoreOfKind(opts.reportKind, client, opts.reportWriter, opts.logger)
+	}
+
+	runLocks := make([]*k8s.RunLock, 0, len(opts.runLockNames))
+	for _, runLockName := range opts.runLockNames {
+		runLocks = append(runLocks, k8s.NewRunLock(clientset, opts.kubewardenNamespace, runLockName, opts.logger))
+	}
+
+	return &auditClients{
+		policiesClient:       policies.NewClient(client, discoveryClient, opts.kubewardenNamespace, opts.policyServerURL, opts.wildcardDenylist, opts.logger),
+		k8sClient:            k8s.NewClient(dynamicClient, clientset, opts.kubewardenNamespace, opts.skippedNs, opts.pageSize, opts.logger),
+		reportStore:          reportStore,
+		configMapStore:       report.NewConfigMapStore(client, opts.logger),
+		runLocks:             runLocks,
+		migrateLegacyReports: migrateLegacyReports,
+	}, nil
+}
+
+// newManifestsClients returns the clients auditing the objects of the given
+// manifest files, with the policies defined by the manifests or deployed in
+// the cluster. The reports are kept in memory, they are never written to the
+// cluster.
+func newManifestsClients(opts clientsOptions, paths []string, manifestsNamespace, policiesFrom string) (*auditClients, error) {
+	loadedManifests, err := manifests.Load(paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifests: %w", err)
+	}
+	auditScheme, err := scheme.NewScheme()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheme: %w", err)
+	}
+
+	var (
+		restMapper     meta.RESTMapper
+		reportClient   client.Client
+		policiesClient *policies.Client
+	)
+	switch policiesFrom {
+	case policiesFromManifests:
+		if opts.policyServerURL == "" {
+			return nil, errors.New("the policy-server-url flag is required when the policies come from the manifests")
+		}
+		// the wildcards cannot be expanded without the API server, the
+		// policies using them are skipped
+		restMapper = loadedManifests.NewRESTMapper(nil, auditScheme)
+		reportClient, err = loadedManifests.NewPolicyClient(auditScheme, restMapper, manifestsNamespace, opts.kubewardenNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load policies from manifests: %w", err)
+		}
+		policiesClient = policies.NewClient(reportClient, nil, opts.kubewardenNamespace, opts.policyServerURL, opts.wildcardDenylist, opts.logger)
+	case policiesFromCluster:
+		config := ctrl.GetConfigOrDie()
+		clientset := kubernetes.NewForConfigOrDie(config)
+		restMapper, err = newClusterRESTMapper(config, loadedManifests, auditScheme)
+		if err != nil {
+			return nil, err
+		}
+		clusterClient, err := client.New(config, client.Options{Scheme: auditScheme, Mapper: restMapper})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		discoveryClient := memory.NewMemCacheClient(clientset.Discovery())
+		policiesClient = policies.NewClient(clusterClient, discoveryClient, opts.kubewardenNamespace, opts.policyServerURL, opts.wildcardDenylist, opts.logger)
+		reportClient = fake.NewClientBuilder().WithScheme(auditScheme).WithRESTMapper(restMapper).Build()
+	default:
+		return nil, fmt.Errorf("invalid policies-from '%s': supported values are '%s' and '%s'", policiesFrom, policiesFromManifests, policiesFromCluster)
+	}
+
+	source, err := loadedManifests.NewSource(restMapper, manifestsNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load resources from manifests: %w", err)
+	}
+
+	return &auditClients{
+		policiesClient: policiesClient,
+		k8sClient:      k8s.NewClientWithSource(source, opts.kubewardenNamespace, opts.skippedNs, opts.pageSize, opts.logger),
+		reportStore:    report.NewReportStoreOfKind(opts.reportKind, reportClient, report.WriterConfig{}, opts.logger),
+		configMapStore: report.NewConfigMapStore(reportClient, opts.logger),
+	}, nil
+}
+
+// newClusterRESTMapper returns a RESTMapper resolving the kinds known by the
+// cluster, and the ones defined by the CustomResourceDefinitions of the
+// manifests.
+func newClusterRESTMapper(config *rest.Config, loadedManifests *manifests.Manifests, auditScheme *runtime.Scheme) (meta.RESTMapper, error) {
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	clusterMapper, err := apiutil.NewDynamicRESTMapper(config, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create REST mapper: %w", err)
+	}
+	return loadedManifests.NewRESTMapper(clusterMapper, auditScheme), nil
+}
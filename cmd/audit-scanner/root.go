@@ -2,21 +2,29 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/output"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/scanner"
-	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/scheme"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/sink"
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
 )
 
 const (
@@ -24,17 +32,109 @@ const (
 	defaultParallelResources   = 100
 	defaultParallelPolicies    = 5
 	defaultParallelNamespaces  = 1
+	defaultMaxInflightRequests = 100
 	defaultPageSize            = 100
+	defaultResyncPeriod        = time.Hour
+	defaultCheckpointMaxAge    = 24 * time.Hour
+	// settings of the requests sent to the PolicyServers
+	defaultPolicyServerTimeout         = 10 * time.Second
+	defaultPolicyServerRetries         = 3
+	defaultPolicyServerRetryBackoff    = 200 * time.Millisecond
+	defaultPolicyServerRetryMaxBackoff = 5 * time.Second
+	defaultCircuitBreakerThreshold     = 20
+	defaultCircuitBreakerCooldown      = 30 * time.Second
+	// rate limit of the reports written to the cluster, disabled by default
+	// so the scans are not slower than before the limit was introduced
+	defaultReportWriteQPS   = 0
+	defaultReportWriteBurst = 0
+	// settings of the requests sent by the http report sink
+	defaultReportSinkTimeout         = 10 * time.Second
+	defaultReportSinkRetries         = 3
+	defaultReportSinkRetryBackoff    = 500 * time.Millisecond
+	defaultReportSinkRetryMaxBackoff = 30 * time.Second
+	defaultReportSinkBatchSize       = 100
+	// name of the ServiceAccount used by the audit scanner, used to build the
+	// default user of the admission requests
+	defaultServiceAccountName = "audit-scanner"
+	// exit code of the process when the gate thresholds are exceeded
+	gateFailedExitCode = 2
+	// exit code of the process when the run did not audit all the resources
+	incompleteExitCode = 3
+	// environment variable holding the index of the Pods of an indexed Job
+	jobCompletionIndexEnv = "JOB_COMPLETION_INDEX"
+)
+
+var (
+	// errGateFailed is returned when the scan run exceeds the gate thresholds.
+	errGateFailed = errors.New("the scan run exceeded the violations threshold")
+	// errRunIncomplete is returned when the scan run did not audit all the
+	// resources.
+	errRunIncomplete = errors.New("the scan run is incomplete")
 )
 
 //nolint:gocognit,funlen // This function is the CLI entrypoint and it's expected to be long.
 func NewRootCommand() *cobra.Command {
 	var (
-		level        string   // log level.
-		outputScan   bool     // print result of scan as JSON to stdout.
-		skippedNs    []string // list of namespaces to be skipped from scan.
-		insecureSSL  bool     // skip SSL cert validation when connecting to PolicyServers endpoints.
-		disableStore bool     // disable storing the results in the k8s cluster.
+		level         string        // log level.
+		outputScan    bool          // print result of scan as JSON to stdout.
+		skippedNs     []string      // list of namespaces to be skipped from scan.
+		insecureSSL   bool          // skip SSL cert validation when connecting to PolicyServers endpoints.
+		disableStore  bool          // disable storing the results in the k8s cluster.
+		incremental   bool          // reuse the results of previous scans for unchanged resources and policies.
+		denylist      []string      // list of resources never audited by policies with wildcard rules.
+		username      string        // username of the user performing the admission requests.
+		groups        []string      // groups of the user performing the admission requests.
+		watch         bool          // audit the resources continuously, when they or the policies change.
+		resyncPeriod  time.Duration // interval between full scans in watch mode.
+		failOn        []string      // policy results counted as violations by the gate.
+		maxViolations int           // violations tolerated by the gate.
+		gatedPolicies []string      // policies considered by the gate.
+		gateFile      string        // file where the gate result is written, stdout when empty.
+		outputFormat  string        // format of the results written to the output.
+		outputFile    string        // file where the results are written, stdout when empty.
+		manifestPaths []string      // manifest files and directories audited instead of the cluster resources.
+		policiesFrom  string        // where the policies used to audit the manifests come from.
+		manifestsNs   string        // namespace of the namespaced objects of the manifests without one.
+	)
+	// save the progress of the runs, so an interrupted run is resumed.
+	var (
+		checkpoint       bool
+		checkpointMaxAge time.Duration
+	)
+	// number of resources whose results are stored in a report.
+	var reportGranularity string
+	// store a report per policy, with the resources failing it.
+	var policyCentricReports bool
+	// destinations of the audit results, the cluster and the external sinks.
+	var (
+		reportSinks    []string
+		reportSinkFile string
+		reportSinkHTTP sink.HTTPConfig
+	)
+	// rate limit of the reports written to the cluster.
+	var reportWriter report.WriterConfig
+	// write a wgpolicyk8s.io copy of each OpenReports report.
+	var dualWriteLegacyReports bool
+	// timeout, retries and circuit breaker of the requests sent to the PolicyServers.
+	var requests scanner.PolicyServerRequestsConfig
+	// lock preventing the runs from overlapping.
+	var (
+		disableRunLock bool
+		runLockWait    time.Duration
+	)
+	// split the audit among several scanner replicas.
+	var (
+		shardIndex  int
+		shardCount  int
+		runUID      string
+		coordinator bool
+	)
+	// restrict the audit to a subset of the namespaces, resources and policies.
+	var (
+		namespaceSelector string
+		includeResources  []string
+		excludeResources  []string
+		auditedPolicies   []string
 	)
 
 	// rootCmd represents the base command when called without any subcommands.
@@ -74,17 +174,17 @@ There will be a ClusterPolicyReport with results for cluster-wide resources.`,
 			if err != nil {
 				return fmt.Errorf("failed to get client-key flag: %w", err)
 			}
-			parallelNamespacesAudits, err := cmd.Flags().GetInt("parallel-namespaces")
-			if err != nil {
-				return fmt.Errorf("failed to get parallel-namespaces flag: %w", err)
-			}
 			parallelResourcesAudits, err := cmd.Flags().GetInt("parallel-resources")
 			if err != nil {
 				return fmt.Errorf("failed to get parallel-resources flag: %w", err)
 			}
-			parallelPoliciesAudit, err := cmd.Flags().GetInt("parallel-policies")
+			maxInflightRequests, err := cmd.Flags().GetInt("max-inflight-requests")
 			if err != nil {
-				return fmt.Errorf("failed to get parallel-policies flag: %w", err)
+				return fmt.Errorf("failed to get max-inflight-requests flag: %w", err)
+			}
+			maxInflightRequestsPerPolicyServer, err := cmd.Flags().GetInt("max-inflight-requests-per-policy-server")
+			if err != nil {
+				return fmt.Errorf("failed to get max-inflight-requests-per-policy-server flag: %w", err)
 			}
 			pageSize, err := cmd.Flags().GetInt("page-size")
 			if err != nil {
@@ -104,57 +204,297 @@ There will be a ClusterPolicyReport with results for cluster-wide resources.`,
 			default:
 				return fmt.Errorf("invalid report-kind '%s': supported values are '%s' and '%s'", reportKindStr, report.OpenReportsKind, report.PolicyReportKind)
 			}
+			if dualWriteLegacyReports && reportKind != report.ReportKindOpenReport {
+				return fmt.Errorf("the dual-write-legacy-reports flag writes the legacy reports alongside the OpenReports, it requires the report-kind flag set to '%s'", report.OpenReportsKind)
+			}
 
-			config := ctrl.GetConfigOrDie()
-			dynamicClient := dynamic.NewForConfigOrDie(config)
-			clientset := kubernetes.NewForConfigOrDie(config)
+			wildcardDenylist := parseGroupResources(denylist)
 
-			auditScheme, err := scheme.NewScheme()
-			if err != nil {
-				return fmt.Errorf("failed to create scheme: %w", err)
+			auditFilter := policies.Filter{
+				Policies:         auditedPolicies,
+				IncludeResources: parseGroupResources(includeResources),
+				ExcludeResources: parseGroupResources(excludeResources),
 			}
-			client, err := client.New(config, client.Options{Scheme: auditScheme})
+			nsLabelSelector, err := labels.Parse(namespaceSelector)
 			if err != nil {
-				return fmt.Errorf("failed to create kubernetes client: %w", err)
+				return fmt.Errorf("invalid namespace-selector: %w", err)
+			}
+
+			userInfo := newUserInfo(kubewardenNamespace, username, groups)
+
+			var gate *report.Gate
+			if len(failOn) > 0 {
+				if err = report.ValidateGateFailOn(failOn); err != nil {
+					return fmt.Errorf("invalid fail-on flag: %w", err)
+				}
+				gate = &report.Gate{
+					FailOn:        failOn,
+					MaxViolations: maxViolations,
+					Policies:      gatedPolicies,
+				}
+			} else if cmd.Flags().Changed("max-violations") || cmd.Flags().Changed("policies") || gateFile != "" {
+				return errors.New("the max-violations, policies and gate-result-file flags require the fail-on flag")
+			}
+
+			if !cmd.Flags().Changed("shard-index") {
+				if index, found := os.LookupEnv(jobCompletionIndexEnv); found {
+					if shardIndex, err = strconv.Atoi(index); err != nil {
+						return fmt.Errorf("invalid %s: %w", jobCompletionIndexEnv, err)
+					}
+				}
+			}
+			if err = validateSharding(shardIndex, shardCount, runUID, coordinator); err != nil {
+				return err
+			}
+			sharded := shardCount > 1
+			if sharded && (namespace != "" || watch || len(manifestPaths) > 0) {
+				return errors.New("a sharded audit scans all the namespaces, it cannot be used together with the namespace, watch or manifests flags")
+			}
+			if watch {
+				if clusterWide || namespace != "" {
+					return errors.New("the watch mode audits all the resources, it cannot be used together with the cluster or namespace flags")
+				}
+				if namespaceSelector != "" || !auditFilter.IsEmpty() {
+					return errors.New("the watch mode audits all the resources, it cannot be used together with the namespace-selector, include-resources, exclude-resources or policy flags")
+				}
+				if gate != nil {
+					return errors.New("the watch mode never ends, it cannot be used together with the fail-on flag")
+				}
+				if len(manifestPaths) > 0 {
+					return errors.New("the manifests cannot be watched, the watch mode cannot be used together with the manifests flag")
+				}
+			}
+			if coordinator && (disableStore || gate != nil) {
+				return errors.New("the coordinator deletes the stored reports without scanning, it cannot be used together with the disable-store or fail-on flags")
+			}
+			// each shard holds its own lock and checkpoint, the coordinator holds the lock of the whole run
+			runLockNames := []string{k8s.RunLockName(reportKindStr)}
+			checkpointName := report.CheckpointName(reportKindStr)
+			if sharded && !coordinator {
+				runLockNames = []string{k8s.ShardRunLockName(reportKindStr, shardIndex)}
+				checkpointName = report.ShardCheckpointName(reportKindStr, shardIndex)
+			}
+			// the dual-write runs delete the legacy reports too, they must
+			// not overlap with the runs storing them
+			if dualWriteLegacyReports {
+				legacyRunLockName := k8s.RunLockName(report.PolicyReportKind)
+				if sharded && !coordinator {
+					legacyRunLockName = k8s.ShardRunLockName(report.PolicyReportKind, shardIndex)
+				}
+				runLockNames = append(runLockNames, legacyRunLockName)
+			}
+			if checkpoint {
+				if watch || len(manifestPaths) > 0 || disableStore || coordinator {
+					return errors.New("the checkpoint flag cannot be used together with the watch, manifests, disable-store or coordinator flags")
+				}
+				if namespaceSelector != "" || !auditFilter.IsEmpty() {
+					return errors.New("a filtered audit cannot be resumed, the checkpoint flag cannot be used together with the namespace-selector, include-resources, exclude-resources or policy flags")
+				}
+				// the summary of a resumed run misses the results audited
+				// before the interruption
+				if gate != nil {
+					return errors.New("the gate evaluates the results of a whole run, the checkpoint flag cannot be used together with the fail-on flag")
+				}
+				if checkpointMaxAge < 0 {
+					return fmt.Errorf("invalid checkpoint-max-age %s: it must not be negative", checkpointMaxAge)
+				}
+			} else {
+				checkpointName = ""
+			}
+			if !slices.Contains(report.SupportedGranularities(), report.Granularity(reportGranularity)) {
+				return fmt.Errorf("invalid report-granularity '%s': supported values are %v", reportGranularity, report.SupportedGranularities())
+			}
+			if report.Granularity(reportGranularity) == report.GranularityNamespace {
+				// the reports of a namespace hold the results of all its resources
+				if watch || incremental || checkpoint {
+					return errors.New("the reports of the namespace granularity are rewritten by each run, it cannot be used together with the watch, incremental or checkpoint flags")
+				}
+				if !auditFilter.IsEmpty() {
+					return errors.New("a filtered audit does not audit all the resources of a namespace, the namespace report granularity cannot be used together with the include-resources, exclude-resources or policy flags")
+				}
+			}
+			// the reports of the policies are built from the results of a whole run
+			if policyCentricReports && (watch || incremental || disableStore || len(manifestPaths) > 0) {
+				return errors.New("the policy-centric-reports flag cannot be used together with the watch, incremental, disable-store or manifests flags")
+			}
+			if policyCentricReports && (namespace != "" || clusterWide || namespaceSelector != "" || len(auditFilter.IncludeResources) > 0 || len(auditFilter.ExcludeResources) > 0) {
+				return errors.New("a partial audit does not find all the resources failing a policy, the policy-centric-reports flag cannot be used together with the namespace, cluster, namespace-selector, include-resources or exclude-resources flags")
 			}
-			logger := slog.New(NewHandler(os.Stdout, level))
-			policiesClient := policies.NewClient(client, kubewardenNamespace, policyServerURL, logger)
 
-			k8sClient := k8s.NewClient(dynamicClient, clientset, kubewardenNamespace, skippedNs, int64(pageSize), logger)
-			reportStore := report.NewReportStoreOfKind(reportKind, client, logger)
+			if !slices.Contains(scanner.SupportedLoadBalancings(), requests.LoadBalancing) {
+				return fmt.Errorf("invalid load-balancing '%s': supported values are %v", requests.LoadBalancing, scanner.SupportedLoadBalancings())
+			}
 
-			if reportKind == report.ReportKindOpenReport {
-				if err = report.DeleteAllLegacyPolicyReports(context.Background(), client, logger); err != nil {
-					logger.Warn("Failed to delete legacy wgpolicyk8s.io PolicyReports, continuing", "error", err)
+			if outputFormat == "" && outputFile != "" {
+				return errors.New("the output-file flag requires the output-format flag")
+			}
+			resultsToStdout := outputFormat != "" && outputFile == ""
+			gateToStdout := gate != nil && gateFile == ""
+			if resultsToStdout && gateToStdout {
+				return errors.New("the results and the gate result cannot be both written to stdout, the output-format and fail-on flags require the output-file or gate-result-file flag")
+			}
+			if watch && outputFormat != "" && !output.Format(outputFormat).IsStreamed() {
+				return fmt.Errorf("the watch mode never ends, the %s output format cannot be used", outputFormat)
+			}
+			if disableStore && cmd.Flags().Changed("report-sink") {
+				return errors.New("the disable-store flag disables all the report sinks, it cannot be used together with the report-sink flag")
+			}
+			storeCRDs := slices.Contains(reportSinks, string(sink.KindCRD))
+			// the features reading back the stored reports need the CRDs
+			if !storeCRDs && (incremental || checkpoint || coordinator) {
+				return fmt.Errorf("the incremental, checkpoint and coordinator flags read the reports stored in the cluster, they require the %s report sink", sink.KindCRD)
+			}
+
+			outputWriter, err := newOutputWriter(outputFormat, outputFile)
+			if err != nil {
+				return err
+			}
+
+			// the logs don't get mixed with the results or the gate result
+			// written to stdout
+			logOutput := os.Stdout
+			if resultsToStdout || gateToStdout {
+				logOutput = os.Stderr
+			}
+			logger := slog.New(NewHandler(logOutput, level))
+			for _, deprecatedFlag := range []string{"parallel-namespaces", "parallel-policies"} {
+				if cmd.Flags().Changed(deprecatedFlag) {
+					logger.Warn("the flag is deprecated and ignored, use the parallel-resources, max-inflight-requests and max-inflight-requests-per-policy-server flags instead",
+						slog.String("flag", deprecatedFlag))
+				}
+			}
+			sinks, err := newReportSinks(reportSinks, reportSinkFile, reportSinkHTTP, logger)
+			if err != nil {
+				return err
+			}
+			clientsOpts := clientsOptions{
+				kubewardenNamespace: kubewardenNamespace,
+				policyServerURL:     policyServerURL,
+				wildcardDenylist:    wildcardDenylist,
+				skippedNs:           skippedNs,
+				pageSize:            int64(pageSize),
+				reportKind:          reportKind,
+				dualWriteLegacy:     dualWriteLegacyReports,
+				runLockNames:        runLockNames,
+				logger:              logger,
+			}
+			reportWriter.SkipUnchanged = true
+			clientsOpts.reportWriter = reportWriter
+			var clients *auditClients
+			if len(manifestPaths) > 0 {
+				if outputFormat == "" && !outputScan && gate == nil {
+					return errors.New("the reports of the manifests are not stored, the manifests flag requires the output-format, output-scan or fail-on flag")
+				}
+				clients, err = newManifestsClients(clientsOpts, manifestPaths, manifestsNs, policiesFrom)
+			} else {
+				if cmd.Flags().Changed("policies-from") || cmd.Flags().Changed("manifests-namespace") {
+					return errors.New("the policies-from and manifests-namespace flags require the manifests flag")
 				}
+				clients, err = newClusterClients(clientsOpts)
 			}
+			if err != nil {
+				return err
+			}
+			// the summaries of the runs are stored next to the reports
+			var runSummaryStore report.RunSummaryStore
+			if storeCRDs {
+				runSummaryStore = clients.configMapStore
+			}
+			// the results are sent to the sinks too, or only to them
+			var fanOut *sink.FanOutStore
+			if len(sinks) > 0 {
+				stores := make([]report.Store, 0, len(sinks)+1)
+				if storeCRDs {
+					stores = append(stores, clients.reportStore)
+				}
+				for _, reportSink := range sinks {
+					stores = append(stores, reportSink)
+				}
+				fanOut = sink.NewFanOutStore(stores...)
+				clients.reportStore = fanOut
+				runSummaryStore = sink.NewFanOutRunSummaryStore(runSummaryStore, sinks...)
+			}
+			clients.policiesClient.SetFilter(auditFilter)
+			clients.k8sClient.SetNamespaceSelector(nsLabelSelector)
 
 			scannerConfig := scanner.Config{
-				PoliciesClient: policiesClient,
-				K8sClient:      k8sClient,
-				ReportStore:    reportStore,
+				PoliciesClient: clients.policiesClient,
+				K8sClient:      clients.k8sClient,
+				ReportStore:    clients.reportStore,
 				TLS: scanner.TLSConfig{
 					Insecure:       insecureSSL,
 					CAFile:         caFile,
 					ClientCertFile: clientCertFile,
 					ClientKeyFile:  clientKeyFile,
 				},
+				PolicyServerRequests: requests,
 				Parallelization: scanner.ParallelizationConfig{
-					ParallelNamespacesAudits: parallelNamespacesAudits,
-					ParallelResourcesAudits:  parallelResourcesAudits,
-					PoliciesAudits:           parallelPoliciesAudit,
+					ParallelResourcesAudits:            parallelResourcesAudits,
+					MaxInflightRequests:                maxInflightRequests,
+					MaxInflightRequestsPerPolicyServer: maxInflightRequestsPerPolicyServer,
 				},
 				OutputScan:   outputScan,
+				Output:       outputWriter,
 				DisableStore: disableStore,
+				Incremental:  incremental,
+				UserInfo:     userInfo,
 				Logger:       logger.With("component", "scanner"),
 				ReportKind:   reportKind,
+				// the summaries are stored next to the Kubewarden components
+				RunSummaryNamespace: kubewardenNamespace,
+				RunSummaryStore:     runSummaryStore,
+				Shard: scanner.ShardConfig{
+					Index: shardIndex,
+					Count: shardCount,
+				},
+				CheckpointName:   checkpointName,
+				CheckpointStore:  clients.configMapStore,
+				CheckpointMaxAge: checkpointMaxAge,
+				// a report per resource, or per namespace
+				ReportGranularity: report.Granularity(reportGranularity),
+				// a report per policy too, with the resources failing it
+				PolicyCentricReports: policyCentricReports,
 			}
 
 			scanner, err := scanner.NewScanner(scannerConfig)
 			if err != nil {
 				return fmt.Errorf("failed to create scanner: %w", err)
 			}
-			return startScanner(namespace, clusterWide, scanner)
+			// stop the scan gracefully on SIGTERM, sent when the CronJob
+			// deadline is exceeded or the node is drained
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			// the runs delete the reports of the previous ones, they must not overlap
+			if !disableStore && !disableRunLock {
+				// the locks are always acquired in the same order
+				for _, runLock := range clients.runLocks {
+					var releaseRunLock func()
+					ctx, releaseRunLock, err = runLock.Acquire(ctx, runLockWait)
+					if err != nil {
+						return fmt.Errorf("failed to acquire the scan run lock: %w", err)
+					}
+					defer releaseRunLock()
+				}
+			}
+			// the legacy reports are migrated while holding the lock, the
+			// shards of a sharded run would migrate them concurrently
+			if clients.migrateLegacyReports != nil && storeCRDs && !disableStore && !sharded {
+				if err = clients.migrateLegacyReports(ctx); err != nil {
+					logger.WarnContext(ctx, "Failed to migrate legacy wgpolicyk8s.io PolicyReports, continuing", slog.String("error", err.Error()))
+				}
+			}
+			if watch {
+				return errors.Join(scanner.Watch(ctx, resyncPeriod), closeOutputWriter(outputWriter), closeReportSinks(ctx, fanOut))
+			}
+			if coordinator {
+				return errors.Join(scanner.FinishShardedRun(ctx, runUID, clusterWide), closeOutputWriter(outputWriter), closeReportSinks(ctx, fanOut))
+			}
+			// a new run, or the run interrupted before the scanner was restarted
+			runUID, err = scanner.StartRun(ctx, runUID)
+			if err != nil {
+				return fmt.Errorf("failed to start the scan run: %w", err)
+			}
+			return startScanner(ctx, namespace, clusterWide, runUID, scanner, gate, gateFile, outputWriter, fanOut)
 		},
 	}
 
@@ -168,39 +508,313 @@ There will be a ClusterPolicyReport with results for cluster-wide resources.`,
 	rootCmd.Flags().StringP("policy-server-url", "u", "", "URI to the PolicyServers the Audit Scanner will query. Example: https://localhost:3000. Useful for out-of-cluster debugging")
 	rootCmd.Flags().StringVarP(&level, "loglevel", "l", "", fmt.Sprintf("level of the logs. Supported values are: %v", SupportedLogLevels()))
 	rootCmd.Flags().BoolVarP(&outputScan, "output-scan", "o", false, "print result of scan in JSON to stdout")
+	rootCmd.Flags().StringVar(&outputFormat, "output-format", "", fmt.Sprintf("write a result per policy and resource in the given format. Supported values are: %v", output.SupportedFormats()))
+	rootCmd.Flags().StringVar(&outputFile, "output-file", "", "file where the results are written when output-format is set. Defaults to stdout, the logs are written to stderr then")
+	rootCmd.Flags().StringSliceVar(&manifestPaths, "manifests", nil, "comma separated list of manifest files and directories to audit instead of the resources of the cluster. The directories are read recursively. This flag can be repeated")
+	rootCmd.Flags().StringVar(&policiesFrom, "policies-from", policiesFromManifests, fmt.Sprintf("where the policies used to audit the manifests come from. Supported values are '%s' and '%s'. Policies from the manifests require the policy-server-url flag", policiesFromManifests, policiesFromCluster))
+	rootCmd.Flags().StringVar(&manifestsNs, "manifests-namespace", "default", "namespace of the namespaced objects of the manifests not setting one")
+	rootCmd.Flags().StringVar(&namespaceSelector, "namespace-selector", "", "label selector of the namespaces to be evaluated, e.g. env=prod,team!=infra. The other namespaces are skipped from scan")
+	rootCmd.MarkFlagsMutuallyExclusive("namespace", "namespace-selector")
+	rootCmd.Flags().StringSliceVar(&includeResources, "include-resources", nil, "comma separated list of resources, in the resource.group format, to be evaluated. The other resources are skipped from scan. This flag can be repeated")
+	rootCmd.Flags().StringSliceVar(&excludeResources, "exclude-resources", nil, "comma separated list of resources, in the resource.group format, to be skipped from scan. This flag can be repeated")
+	rootCmd.Flags().StringSliceVar(&auditedPolicies, "policy", nil, "comma separated list of the unique names of the policies to be evaluated, e.g. clusterwide-my-policy or namespaced-default-my-policy. The other policies are skipped from scan. This flag can be repeated")
 	rootCmd.Flags().StringSliceVarP(&skippedNs, "ignore-namespaces", "i", nil, "comma separated list of namespace names to be skipped from scan. This flag can be repeated")
 	rootCmd.Flags().BoolVar(&insecureSSL, "insecure-ssl", false, "skip SSL cert validation when connecting to PolicyServers endpoints. Useful for development")
 	rootCmd.Flags().StringP("extra-ca", "f", "", "File path to CA cert in PEM format of PolicyServer endpoints")
 	rootCmd.Flags().StringP("client-cert", "", "", "File path to client cert in PEM format used for mTLS communication with the PolicyServer endpoints")
 	rootCmd.Flags().StringP("client-key", "", "", "File path to client key in PEM format used for mTLS communication with the PolicyServer endpoints")
 	rootCmd.MarkFlagsRequiredTogether("client-cert", "client-key")
+	rootCmd.Flags().DurationVar(&requests.Timeout, "policy-server-timeout", defaultPolicyServerTimeout, "timeout of each request sent to the PolicyServers")
+	rootCmd.Flags().IntVar(&requests.MaxRetries, "policy-server-retries", defaultPolicyServerRetries, "number of times a request failing because the PolicyServer is unreachable or unavailable is retried")
+	rootCmd.Flags().DurationVar(&requests.InitialBackoff, "policy-server-retry-backoff", defaultPolicyServerRetryBackoff, "delay before the first retry of a request sent to a PolicyServer. It's doubled at each retry, with a random jitter")
+	rootCmd.Flags().DurationVar(&requests.MaxBackoff, "policy-server-retry-max-backoff", defaultPolicyServerRetryMaxBackoff, "maximum delay between the retries of a request sent to a PolicyServer")
+	rootCmd.Flags().IntVar(&requests.CircuitBreakerThreshold, "circuit-breaker-threshold", defaultCircuitBreakerThreshold, "number of consecutive failed requests after which a PolicyServer is considered down and its evaluations are errored right away. 0 disables the circuit breaker")
+	rootCmd.Flags().StringVar((*string)(&requests.LoadBalancing), "load-balancing", string(scanner.LoadBalancingRoundRobin), fmt.Sprintf("how the requests are distributed among the ready replicas of a PolicyServer. Supported values are: %v", scanner.SupportedLoadBalancings()))
+	rootCmd.Flags().DurationVar(&requests.CircuitBreakerCooldown, "circuit-breaker-cooldown", defaultCircuitBreakerCooldown, "time waited before sending requests again to a PolicyServer considered down")
 	rootCmd.Flags().BoolVar(&disableStore, "disable-store", false, "disable storing the results in the k8s cluster")
+	rootCmd.Flags().StringSliceVar(&reportSinks, "report-sink", []string{string(sink.KindCRD)}, fmt.Sprintf("comma separated list of the destinations of the audit results: the reports stored in the cluster, the batches of CloudEvents posted to report-sink-url, or the CloudEvents appended to report-sink-file, a JSON object per line. The deletion of the reports of the previous runs is sent as a run completed event. Supported values are: %v. This flag can be repeated", sink.SupportedKinds()))
+	rootCmd.Flags().StringVar(&reportSinkFile, "report-sink-file", "", "file where the file report sink appends the CloudEvents")
+	rootCmd.Flags().StringVar(&reportSinkHTTP.URL, "report-sink-url", "", "URL of the endpoint where the http report sink posts the batches of CloudEvents")
+	rootCmd.Flags().StringVar(&reportSinkHTTP.CAFile, "report-sink-ca", "", "File path to CA cert in PEM format of the endpoint of the http report sink")
+	rootCmd.Flags().StringVar(&reportSinkHTTP.ClientCertFile, "report-sink-client-cert", "", "File path to client cert in PEM format used for mTLS communication with the endpoint of the http report sink")
+	rootCmd.Flags().StringVar(&reportSinkHTTP.ClientKeyFile, "report-sink-client-key", "", "File path to client key in PEM format used for mTLS communication with the endpoint of the http report sink")
+	rootCmd.MarkFlagsRequiredTogether("report-sink-client-cert", "report-sink-client-key")
+	rootCmd.Flags().DurationVar(&reportSinkHTTP.Timeout, "report-sink-timeout", defaultReportSinkTimeout, "timeout of each request sent by the http report sink")
+	rootCmd.Flags().IntVar(&reportSinkHTTP.MaxRetries, "report-sink-retries", defaultReportSinkRetries, "number of times a batch of CloudEvents failing because the endpoint is unreachable or unavailable is sent again")
+	rootCmd.Flags().DurationVar(&reportSinkHTTP.InitialBackoff, "report-sink-retry-backoff", defaultReportSinkRetryBackoff, "delay before the first retry of a batch of CloudEvents. It's doubled at each retry, with a random jitter")
+	rootCmd.Flags().DurationVar(&reportSinkHTTP.MaxBackoff, "report-sink-retry-max-backoff", defaultReportSinkRetryMaxBackoff, "maximum delay between the retries of a batch of CloudEvents")
+	rootCmd.Flags().IntVar(&reportSinkHTTP.BatchSize, "report-sink-batch-size", defaultReportSinkBatchSize, "number of CloudEvents posted with each request by the http report sink. The events are sent when a batch is full, and when the resources of a namespace or the cluster wide resources are all audited")
+	rootCmd.Flags().BoolVar(&disableRunLock, "disable-run-lock", false, "do not acquire the Lease preventing the scan runs storing the same kind of reports from overlapping")
+	rootCmd.Flags().DurationVar(&runLockWait, "run-lock-wait", 0, "time waited for another scan run to release the Lease before failing. By default, the scanner fails right away")
+	rootCmd.Flags().IntVar(&shardCount, "shard-count", 1, "number of scanner replicas sharing the audit. Each replica audits the namespaces and the cluster wide resources of its shard. Requires the run-uid flag when greater than 1")
+	rootCmd.Flags().IntVar(&shardIndex, "shard-index", 0, fmt.Sprintf("shard audited by this replica, from 0 to shard-count - 1. Defaults to the %s environment variable, set in the Pods of an indexed Job", jobCompletionIndexEnv))
+	rootCmd.Flags().StringVar(&runUID, "run-uid", "", "UID of the scan run, shared by all the shards of a sharded audit. Defaults to a random UID")
+	rootCmd.Flags().BoolVar(&coordinator, "coordinator", false, "coordinate a sharded audit instead of scanning: wait for all the shards of the run to finish, then delete the reports of the previous runs. Requires the shard-count and run-uid flags")
+	rootCmd.Flags().BoolVar(&checkpoint, "checkpoint", false, "save the progress of the scan runs in a ConfigMap, so a restarted scanner resumes the interrupted run instead of starting a new one")
+	rootCmd.Flags().DurationVar(&checkpointMaxAge, "checkpoint-max-age", defaultCheckpointMaxAge, "age of the interrupted runs past which they are not resumed anymore, a new run is started instead. 0 means no limit")
+	rootCmd.Flags().StringVar(&reportGranularity, "report-granularity", string(report.GranularityResource), fmt.Sprintf("number of resources whose results are stored in a report: a report per resource, or a report per namespace and a cluster report for the cluster wide resources, split in numbered parts when they approach the size limit of the objects. Supported values are: %v", report.SupportedGranularities()))
+	rootCmd.Flags().BoolVar(&policyCentricReports, "policy-centric-reports", false, "store a report per policy too, listing the resources failing the policy or whose evaluation errored. The reports of the cluster wide policies are cluster reports, the ones of the namespaced policies are stored in the namespace of the policy")
+	rootCmd.Flags().Float32Var(&reportWriter.QPS, "report-write-qps", defaultReportWriteQPS, "maximum number of reports written to the cluster per second, the other writes wait their turn. 0 means no limit")
+	rootCmd.Flags().IntVar(&reportWriter.Burst, "report-write-burst", defaultReportWriteBurst, "number of reports written to the cluster at once before the report-write-qps limit applies. Defaults to report-write-qps")
+	rootCmd.Flags().BoolVar(&incremental, "incremental", false, "reuse the results stored by previous scans when neither the resource nor the policy changed")
+	rootCmd.Flags().StringSliceVar(&denylist, "wildcard-denylist", defaultWildcardDenylist(), "comma separated list of resources, in the resource.group format, that are not audited by policies with wildcard rules. This flag can be repeated")
+	rootCmd.Flags().StringVar(&username, "request-username", "", "username set in the userInfo of the admission requests sent to the policies. Defaults to the audit-scanner ServiceAccount in the Kubewarden namespace")
+	rootCmd.Flags().StringSliceVar(&groups, "request-groups", nil, "comma separated list of groups set in the userInfo of the admission requests sent to the policies. Defaults to the groups of the audit-scanner ServiceAccount. This flag can be repeated")
+	rootCmd.Flags().BoolVar(&watch, "watch", false, "keep running and audit the resources when they or the policies targeting them change")
+	rootCmd.Flags().DurationVar(&resyncPeriod, "resync-period", defaultResyncPeriod, "interval between full scans of the cluster when running in watch mode")
+	rootCmd.Flags().StringSliceVar(&failOn, "fail-on", nil, fmt.Sprintf("comma separated list of policy results counted as violations, supported values are 'fail' and 'error'. When set, a summary of the violations is written in JSON to stdout, or to gate-result-file, and the process exits with code %d when there are more than max-violations, with code %d when the run did not audit all the resources", gateFailedExitCode, incompleteExitCode))
+	rootCmd.Flags().IntVar(&maxViolations, "max-violations", 0, "number of violations tolerated before failing. Requires the fail-on flag")
+	rootCmd.Flags().StringSliceVar(&gatedPolicies, "policies", nil, "comma separated list of the unique names of the policies whose violations are counted, e.g. clusterwide-my-policy or namespaced-default-my-policy. The scan fails when one of them is not part of the scan. Defaults to all the policies. Requires the fail-on flag")
+	rootCmd.Flags().StringVar(&gateFile, "gate-result-file", "", "file where the summary of the violations is written. Defaults to stdout, the logs are written to stderr then. Requires the fail-on flag")
 	rootCmd.Flags().IntP("parallel-namespaces", "", defaultParallelNamespaces, "number of Namespaces to scan in parallel")
-	rootCmd.Flags().IntP("parallel-resources", "", defaultParallelResources, "number of resources to scan in parallel")
+	_ = rootCmd.Flags().MarkDeprecated("parallel-namespaces", "all the Namespaces are scanned in parallel, the requests sent to the PolicyServers are shared fairly among them. Use max-inflight-requests and max-inflight-requests-per-policy-server to bound them")
+	rootCmd.Flags().IntP("parallel-resources", "", defaultParallelResources, "number of resources to scan in parallel, shared by all the Namespaces and the cluster wide resources. At most that many Namespaces are scanned in parallel too")
 	rootCmd.Flags().IntP("parallel-policies", "", defaultParallelPolicies, "number of policies to evaluate for a given resource in parallel")
+	_ = rootCmd.Flags().MarkDeprecated("parallel-policies", "the policies of a resource are evaluated in parallel, use max-inflight-requests and max-inflight-requests-per-policy-server to bound the requests sent to the PolicyServers")
+	rootCmd.Flags().IntP("max-inflight-requests", "", defaultMaxInflightRequests, "maximum number of requests sent to all the PolicyServers at the same time")
+	rootCmd.Flags().IntP("max-inflight-requests-per-policy-server", "", 0, "maximum number of requests sent to a single PolicyServer at the same time. 0 means no limit, besides max-inflight-requests")
 	rootCmd.Flags().IntP("page-size", "", defaultPageSize, "number of resources to fetch from the Kubernetes API server when paginating")
 	rootCmd.Flags().StringP("report-kind", "", report.PolicyReportKind, "Report resource kind to be used. Supported values are 'openreport' and 'policyreport'")
+	rootCmd.Flags().BoolVar(&dualWriteLegacyReports, "dual-write-legacy-reports", false, "write a wgpolicyk8s.io PolicyReport or ClusterPolicyReport copy of each OpenReports report, so the consumers of the legacy reports can switch over gradually. The legacy reports are converted to OpenReports, but they are not deleted. Requires the report-kind flag set to 'openreport'")
 
 	return rootCmd
 }
 
+// parseGroupResources parses the given resources, in the resource.group format.
+func parseGroupResources(resources []string) []schema.GroupResource {
+	groupResources := make([]schema.GroupResource, 0, len(resources))
+	for _, resource := range resources {
+		groupResources = append(groupResources, schema.ParseGroupResource(resource))
+	}
+	return groupResources
+}
+
+// defaultWildcardDenylist returns the resources that are not audited by policies
+// with wildcard rules by default. These are noisy resources, frequently
+// created and deleted, or the audit results themselves.
+func defaultWildcardDenylist() []string {
+	return []string{
+		"events",
+		"events.events.k8s.io",
+		"leases.coordination.k8s.io",
+		"policyreports.wgpolicyk8s.io",
+		"clusterpolicyreports.wgpolicyk8s.io",
+		"reports.openreports.io",
+		"clusterreports.openreports.io",
+	}
+}
+
+// newUserInfo returns the user performing the admission requests sent to the
+// policies. When not set, the username and the groups are the ones of the
+// audit-scanner ServiceAccount, so policies can recognize the audit requests.
+func newUserInfo(kubewardenNamespace, username string, groups []string) authenticationv1.UserInfo {
+	if username == "" {
+		username = serviceaccount.MakeUsername(kubewardenNamespace, defaultServiceAccountName)
+	}
+	if len(groups) == 0 {
+		groups = append(serviceaccount.MakeGroupNames(kubewardenNamespace), user.AllAuthenticated)
+	}
+
+	return authenticationv1.UserInfo{
+		Username: username,
+		Groups:   groups,
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(rootCmd *cobra.Command) {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error on cmd.Execute(): %s\n", err.Error())
+		// the violations already counted by an incomplete run exceed the
+		// thresholds too
+		if errors.Is(err, errGateFailed) {
+			os.Exit(gateFailedExitCode)
+		}
+		if errors.Is(err, errRunIncomplete) {
+			os.Exit(incompleteExitCode)
+		}
 		os.Exit(1)
 	}
 }
 
+// validateSharding validates the flags splitting the audit among several
+// scanner replicas.
+func validateSharding(shardIndex, shardCount int, runUID string, coordinator bool) error {
+	if shardCount < 1 {
+		return fmt.Errorf("invalid shard-count %d: it must be greater than 0", shardCount)
+	}
+	if shardIndex < 0 || shardIndex >= shardCount {
+		return fmt.Errorf("invalid shard-index %d: it must be between 0 and %d", shardIndex, shardCount-1)
+	}
+	if shardCount > 1 && runUID == "" {
+		return errors.New("the shards of a sharded audit must share the run UID, the shard-count flag requires the run-uid flag")
+	}
+	if coordinator && shardCount == 1 {
+		return errors.New("the coordinator flag requires the shard-count flag")
+	}
+	return nil
+}
+
 //nolint:wrapcheck // this function calls internal package which already wrap the errors with context
-func startScanner(namespace string, clusterWide bool, scanner *scanner.Scanner) error {
+func startScanner(ctx context.Context, namespace string, clusterWide bool, runUID string, scanner *scanner.Scanner, gate *report.Gate, gateFile string, outputWriter output.Writer, reportSinks *sink.FanOutStore) error {
 	if clusterWide && namespace != "" {
 		fmt.Fprintln(os.Stderr, "Cannot scan cluster wide and only a namespace at the same time")
 	}
 
-	runUID := uuid.New().String()
-	ctx := context.Background()
+	scanErr := scan(ctx, namespace, clusterWide, runUID, scanner)
+	// the summary of an interrupted run is stored too, flagged as incomplete
+	summary, finishErr := scanner.FinishRun(context.WithoutCancel(ctx), runUID)
+	err := errors.Join(scanErr, finishErr, closeOutputWriter(outputWriter), closeReportSinks(ctx, reportSinks))
+	// a scan failing before it audited the first resource has no summary
+	// flagged as incomplete
+	if scanErr != nil {
+		summary.Incomplete = true
+	}
+	if summary.Incomplete {
+		err = errors.Join(err, errRunIncomplete)
+	}
+	if gate == nil {
+		return err
+	}
+
+	// the gate result of an incomplete run is written too, it does not pass
+	return errors.Join(err, evaluateGate(gate, gateFile, summary))
+}
+
+// fileOutputWriter closes the file where the results are written together
+// with the output.Writer.
+type fileOutputWriter struct {
+	output.Writer
+	file *os.File
+}
+
+func (w *fileOutputWriter) Close() error {
+	return errors.Join(w.Writer.Close(), w.file.Close())
+}
+
+// newOutputWriter returns the writer of the results in the given format, to
+// the given file or to stdout. It returns nil when no format is given.
+func newOutputWriter(format, file string) (output.Writer, error) {
+	if format == "" {
+		return nil, nil //nolint:nilnil // no output has been requested
+	}
+	if !slices.Contains(output.SupportedFormats(), output.Format(format)) {
+		return nil, fmt.Errorf("invalid output-format '%s': supported values are %v", format, output.SupportedFormats())
+	}
+	if file == "" {
+		writer, err := output.NewWriter(output.Format(format), os.Stdout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output writer: %w", err)
+		}
+		return writer, nil
+	}
+
+	outputFile, err := os.Create(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	writer, err := output.NewWriter(output.Format(format), outputFile)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to create output writer: %w", err), outputFile.Close())
+	}
+
+	return &fileOutputWriter{Writer: writer, file: outputFile}, nil
+}
+
+func closeOutputWriter(writer output.Writer) error {
+	if writer == nil {
+		return nil
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write the results to the output: %w", err)
+	}
+	return nil
+}
+
+// newReportSinks returns the report sinks of the given kinds, besides the CRD
+// stores built with the clients.
+func newReportSinks(kinds []string, file string, httpConfig sink.HTTPConfig, logger *slog.Logger) ([]*sink.Sink, error) {
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("at least a report-sink is required: supported values are %v", sink.SupportedKinds())
+	}
+
+	var sinks []*sink.Sink
+	for _, kind := range kinds {
+		switch sink.Kind(kind) {
+		case sink.KindCRD:
+			continue
+		case sink.KindFile:
+			if file == "" {
+				return nil, errors.New("the file report sink requires the report-sink-file flag")
+			}
+			fileSink, err := sink.NewFileSink(file, logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create the file report sink: %w", err)
+			}
+			sinks = append(sinks, fileSink)
+		case sink.KindHTTP:
+			if httpConfig.URL == "" {
+				return nil, errors.New("the http report sink requires the report-sink-url flag")
+			}
+			httpSink, err := sink.NewHTTPSink(httpConfig, logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create the http report sink: %w", err)
+			}
+			sinks = append(sinks, httpSink)
+		default:
+			return nil, fmt.Errorf("invalid report-sink '%s': supported values are %v", kind, sink.SupportedKinds())
+		}
+	}
+	return sinks, nil
+}
+
+// closeReportSinks sends the results not sent yet to the report sinks. The
+// results of an interrupted run are sent too.
+func closeReportSinks(ctx context.Context, reportSinks *sink.FanOutStore) error {
+	if reportSinks == nil {
+		return nil
+	}
+	if err := reportSinks.Close(context.WithoutCancel(ctx)); err != nil {
+		return fmt.Errorf("failed to send the results to the report sinks: %w", err)
+	}
+	return nil
+}
+
+// evaluateGate writes the outcome of the gate in JSON format to the given
+// file, or to stdout, and returns errGateFailed when the thresholds are
+// exceeded. The gated policies which are not part of a complete run are an
+// error, their violations would never be counted. The ones of an incomplete
+// run may be part of the namespaces not scanned.
+func evaluateGate(gate *report.Gate, file string, summary *report.RunSummary) error {
+	if unknown := gate.UnknownPolicies(summary); len(unknown) > 0 && !summary.Incomplete {
+		return fmt.Errorf("the gated policies %v are not part of the scan run", unknown)
+	}
+
+	result := gate.Evaluate(summary)
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode the gate result: %w", err)
+	}
+	resultJSON = append(resultJSON, '\n')
+	if file == "" {
+		_, err = os.Stdout.Write(resultJSON)
+	} else {
+		err = os.WriteFile(file, resultJSON, 0o600)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write the gate result: %w", err)
+	}
+
+	// an incomplete run within the thresholds is reported by the caller
+	if result.Violations > result.MaxViolations {
+		return fmt.Errorf("%w: %d violations, %d allowed", errGateFailed, result.Violations, result.MaxViolations)
+	}
+	return nil
+}
+
+//nolint:wrapcheck // this function calls internal package which already wrap the errors with context
+func scan(ctx context.Context, namespace string, clusterWide bool, runUID string, scanner *scanner.Scanner) error {
 	if clusterWide {
 		// only scan clusterwide
 		return scanner.ScanClusterWideResources(ctx, runUID)
@@ -3,14 +3,18 @@ package constants
 import "errors"
 
 const (
-	KubewardenPoliciesGroup                   = "policies.kubewarden.io"
-	KubewardenPoliciesVersion                 = "v1"
-	KubewardenKindClusterAdmissionPolicy      = "ClusterAdmissionPolicy"
-	KubewardenKindClusterAdmissionPolicyGroup = "ClusterAdmissionPolicyGroup"
-	KubewardenKindAdmissionPolicy             = "AdmissionPolicy"
-	KubewardenKindAdmissionPolicyGroup        = "AdmissionPolicyGroup"
-	DefaultClusterwideReportName              = "clusterwide"
-	AuditScannerRunUIDLabel                   = "kubewarden.io/audit-scanner-run-uid"
+	KubewardenPoliciesGroup                        = "policies.kubewarden.io"
+	KubewardenPoliciesVersion                      = "v1"
+	KubewardenKindClusterAdmissionPolicy           = "ClusterAdmissionPolicy"
+	KubewardenKindClusterAdmissionPolicyGroup      = "ClusterAdmissionPolicyGroup"
+	KubewardenKindAdmissionPolicy                  = "AdmissionPolicy"
+	KubewardenKindAdmissionPolicyGroup             = "AdmissionPolicyGroup"
+	KubewardenResourceClusterAdmissionPolicies     = "clusteradmissionpolicies"
+	KubewardenResourceClusterAdmissionPolicyGroups = "clusteradmissionpolicygroups"
+	KubewardenResourceAdmissionPolicies            = "admissionpolicies"
+	KubewardenResourceAdmissionPolicyGroups        = "admissionpolicygroups"
+	DefaultClusterwideReportName                   = "clusterwide"
+	AuditScannerRunUIDLabel                        = "kubewarden.io/audit-scanner-run-uid"
 )
 
 // ErrResourceNotFound is an error used to tell that the required resource is not found.
@@ -0,0 +1,29 @@
+package httpclient
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff returns the delay before the given retry attempt, starting from 0.
+// The delay doubles at each attempt, up to maxBackoff, and a random jitter
+// picks it between its half and its full value, so the retries of the
+// parallel requests are spread over time.
+func Backoff(attempt int, initialBackoff, maxBackoff time.Duration) time.Duration {
+	delay := initialBackoff
+	for range attempt {
+		if maxBackoff > 0 && delay >= maxBackoff {
+			break
+		}
+		delay *= 2
+	}
+	if maxBackoff > 0 && delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1) //nolint:gosec // the jitter doesn't need a secure random number
+}
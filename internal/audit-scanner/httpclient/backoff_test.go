@@ -0,0 +1,18 @@
+package httpclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	for attempt := range 10 {
+		delay := Backoff(attempt, 100*time.Millisecond, time.Second)
+		expected := min(100*time.Millisecond<<attempt, time.Second)
+		assert.GreaterOrEqual(t, delay, expected/2)
+		assert.LessOrEqual(t, delay, expected)
+	}
+	assert.Zero(t, Backoff(3, 0, time.Second))
+}
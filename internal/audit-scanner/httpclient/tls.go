@@ -0,0 +1,48 @@
+// Package httpclient holds the helpers shared by the HTTP clients of the
+// audit scanner, the one sending the admission reviews to the PolicyServers
+// and the one posting the events to the report sink.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// NewTLSConfig returns the TLS settings of the connections to a server,
+// trusting the system CAs and the CA certificate of caFile, when not empty.
+// The client certificate and key authenticate the connections with mTLS when
+// both clientCertFile and clientKeyFile are set. All the files are in PEM
+// format.
+func NewTLSConfig(caFile, clientCertFile, clientKeyFile string) (*tls.Config, error) {
+	// Get the SystemCertPool to build an in-app cert pool from it
+	// Continue with an empty pool on error
+	rootCAs, _ := x509.SystemCertPool()
+	if rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if caFile != "" {
+		caCert, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %q with CA cert: %w", caFile, err)
+		}
+		if ok := rootCAs.AppendCertsFromPEM(caCert); !ok {
+			return nil, errors.New("failed to append cert to in-app RootCAs trust store")
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    rootCAs,
+	}
+	if clientCertFile != "" && clientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -2,28 +2,80 @@ package k8s
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/pager"
 )
 
-// Client retrieves resources and namespaces from a Kubernetes cluster.
-type Client struct {
+// ResourceSource provides the resources and the namespaces to audit.
+type ResourceSource interface {
+	// ListResources lists the resources of the given GVR in the given
+	// namespace, or in all the namespaces when it's empty.
+	ListResources(ctx context.Context, gvr schema.GroupVersionResource, nsName string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error)
+	// ListNamespaces lists the namespaces matching the field and label
+	// selectors of the options.
+	ListNamespaces(ctx context.Context, opts metav1.ListOptions) (*corev1.NamespaceList, error)
+	GetNamespace(ctx context.Context, nsName string) (*corev1.Namespace, error)
+}
+
+// clusterSource is the ResourceSource of a Kubernetes cluster.
+type clusterSource struct {
 	// dynamicClient is used to get resource lists
 	dynamicClient dynamic.Interface
 	// client is used to get namespaces
 	clientset kubernetes.Interface
+}
+
+func (c *clusterSource) ListResources(ctx context.Context, gvr schema.GroupVersionResource, nsName string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list, err := c.dynamicClient.Resource(gvr).Namespace(nsName).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't list resources %s in namespace %s: %w", gvr.String(), nsName, err)
+	}
+	return list, nil
+}
+
+func (c *clusterSource) ListNamespaces(ctx context.Context, opts metav1.ListOptions) (*corev1.NamespaceList, error) {
+	namespaceList, err := c.clientset.CoreV1().Namespaces().List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't list namespaces: %w", err)
+	}
+	return namespaceList, nil
+}
+
+func (c *clusterSource) GetNamespace(ctx context.Context, nsName string) (*corev1.Namespace, error) {
+	namespace, err := c.clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get namespace %s: %w", nsName, err)
+	}
+	return namespace, nil
+}
+
+// Client retrieves resources and namespaces from a Kubernetes cluster, or from
+// another ResourceSource.
+type Client struct {
+	// source provides the resources and the namespaces
+	source ResourceSource
+	// dynamicClient is used to watch the resources. It's nil when the
+	// resources don't come from a cluster
+	dynamicClient dynamic.Interface
 	// list of skipped namespaces from audit, by name. It includes kubewardenNamespace
 	skippedNs []string
+	// namespaceSelector selects the audited namespaces by label. All the
+	// namespaces are audited when nil
+	namespaceSelector labels.Selector
 	// pageSize is the number of resources to fetch when paginating
 	pageSize int64
 	// logger is used to log the messages
@@ -32,17 +84,31 @@ type Client struct {
 
 // NewClient returns a new client.
 func NewClient(dynamicClient dynamic.Interface, clientset kubernetes.Interface, kubewardenNamespace string, skippedNs []string, pageSize int64, logger *slog.Logger) *Client {
+	client := NewClientWithSource(&clusterSource{dynamicClient, clientset}, kubewardenNamespace, skippedNs, pageSize, logger)
+	client.dynamicClient = dynamicClient
+
+	return client
+}
+
+// NewClientWithSource returns a new client retrieving the resources and the
+// namespaces from the given source. The client cannot watch the resources.
+func NewClientWithSource(source ResourceSource, kubewardenNamespace string, skippedNs []string, pageSize int64, logger *slog.Logger) *Client {
 	skippedNs = append(skippedNs, kubewardenNamespace)
 
 	return &Client{
-		dynamicClient,
-		clientset,
-		skippedNs,
-		pageSize,
-		logger.With("component", "k8sclient"),
+		source:    source,
+		skippedNs: skippedNs,
+		pageSize:  pageSize,
+		logger:    logger.With("component", "k8sclient"),
 	}
 }
 
+// SetNamespaceSelector restricts the audited namespaces to the ones matching
+// the given label selector.
+func (f *Client) SetNamespaceSelector(selector labels.Selector) {
+	f.namespaceSelector = selector
+}
+
 func (f *Client) GetResources(gvr schema.GroupVersionResource, nsName string) *pager.ListPager {
 	listPager := pager.New(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
 		list, err := f.listResources(ctx, gvr, nsName, opts)
@@ -60,20 +126,12 @@ func (f *Client) listResources(ctx context.Context,
 ) (
 	*unstructured.UnstructuredList, error,
 ) {
-	resourceID := schema.GroupVersionResource{
-		Group:    gvr.Group,
-		Version:  gvr.Version,
-		Resource: gvr.Resource,
-	}
-
-	list, err := f.dynamicClient.Resource(resourceID).Namespace(nsName).List(ctx, opts)
-	if err != nil {
-		return nil, fmt.Errorf("can't list resources %s in namespace %s: %w", gvr.String(), nsName, err)
-	}
-	return list, nil
+	//nolint:wrapcheck // the sources already wrap the errors with context
+	return f.source.ListResources(ctx, gvr, nsName, opts)
 }
 
-// GetAuditedNamespaces gets all namespaces besides the ones in skippedNs.
+// GetAuditedNamespaces gets all namespaces besides the ones in skippedNs,
+// matching the namespace selector if any.
 func (f *Client) GetAuditedNamespaces(ctx context.Context) (*corev1.NamespaceList, error) {
 	// This function cannot be tested with fake client, as filtering is done server-side
 	skipNsFields := fields.Everything()
@@ -81,18 +139,26 @@ func (f *Client) GetAuditedNamespaces(ctx context.Context) (*corev1.NamespaceLis
 		skipNsFields = fields.AndSelectors(skipNsFields, fields.OneTermNotEqualSelector("metadata.name", nsName))
 		f.logger.DebugContext(ctx, "skipping ns", slog.String("ns", nsName))
 	}
-
-	namespaceList, err := f.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{FieldSelector: skipNsFields.String()})
-	if err != nil {
-		return nil, fmt.Errorf("can't list namespaces: %w", err)
+	opts := metav1.ListOptions{FieldSelector: skipNsFields.String()}
+	if f.namespaceSelector != nil {
+		opts.LabelSelector = f.namespaceSelector.String()
 	}
-	return namespaceList, nil
+
+	//nolint:wrapcheck // the sources already wrap the errors with context
+	return f.source.ListNamespaces(ctx, opts)
 }
 
 func (f *Client) GetNamespace(ctx context.Context, nsName string) (*corev1.Namespace, error) {
-	namespace, err := f.clientset.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("can't get namespace %s: %w", nsName, err)
+	//nolint:wrapcheck // the sources already wrap the errors with context
+	return f.source.GetNamespace(ctx, nsName)
+}
+
+// NewInformerFactory returns a factory of shared informers watching the
+// resources of the cluster. A resyncPeriod of 0 disables the informers resync.
+// It returns an error when the resources don't come from a cluster.
+func (f *Client) NewInformerFactory(resyncPeriod time.Duration) (dynamicinformer.DynamicSharedInformerFactory, error) {
+	if f.dynamicClient == nil {
+		return nil, errors.New("the resources cannot be watched, they don't come from a cluster")
 	}
-	return namespace, nil
+	return dynamicinformer.NewDynamicSharedInformerFactory(f.dynamicClient, resyncPeriod), nil
 }
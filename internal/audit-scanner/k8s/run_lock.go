@@ -0,0 +1,143 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// runLockLeaseDuration is how long the lock is considered held after
+	// its last renewal. A scanner killed without releasing the lock blocks
+	// the next runs for this long
+	runLockLeaseDuration = 30 * time.Second
+	// runLockRenewDeadline is how long the holder retries to renew the lock
+	// before giving up the run
+	runLockRenewDeadline = 20 * time.Second
+	// runLockRetryPeriod is the interval between the attempts to acquire and
+	// renew the lock
+	runLockRetryPeriod = 5 * time.Second
+)
+
+// ErrRunLockHeld is returned when the lock is held by another scan run.
+var ErrRunLockHeld = errors.New("another scan run holds the lock")
+
+// RunLock prevents the scan runs storing the same kind of reports from
+// overlapping. Each run deletes the reports of the other runs once finished,
+// so overlapping runs would delete each other's reports. The lock is a
+// coordination.k8s.io Lease, renewed during the run.
+type RunLock struct {
+	lock   *resourcelock.LeaseLock
+	logger *slog.Logger
+}
+
+// NewRunLock returns the lock held by the scan runs with the Lease of the
+// given name, created in the given namespace.
+func NewRunLock(clientset kubernetes.Interface, namespace, name string, logger *slog.Logger) *RunLock {
+	// the identity must be unique, the hostname is the Pod name
+	hostname, _ := os.Hostname()
+	identity := hostname + "_" + uuid.New().String()
+
+	return &RunLock{
+		lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Client: clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: identity,
+			},
+		},
+		logger: logger.With("lease", name),
+	}
+}
+
+// RunLockName returns the name of the Lease locking the scan runs storing the
+// given kind of reports.
+func RunLockName(reportKind string) string {
+	return "audit-scanner-" + reportKind
+}
+
+// ShardRunLockName returns the name of the Lease locking the runs of the given
+// shard of a sharded audit, storing the given kind of reports.
+func ShardRunLockName(reportKind string, shardIndex int) string {
+	return fmt.Sprintf("%s-shard-%d", RunLockName(reportKind), shardIndex)
+}
+
+// Acquire acquires the lock, waiting up to the given duration when it's held
+// by another run. It returns ErrRunLockHeld when the lock cannot be acquired
+// in time. Otherwise, the lock is renewed until the returned release function
+// is called. The returned context is canceled when the lock is lost, because
+// it cannot be renewed, so the run stops before its reports overlap with the
+// ones of another run.
+func (l *RunLock) Acquire(ctx context.Context, wait time.Duration) (context.Context, func(), error) {
+	runCtx, cancelRun := context.WithCancel(ctx)
+	// the lock is renewed until it's released, even when the run is
+	// canceled, so the reports are flushed while the lock is held
+	electorCtx, stopElector := context.WithCancel(context.WithoutCancel(ctx))
+	acquired := make(chan struct{})
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            l.lock,
+		LeaseDuration:   runLockLeaseDuration,
+		RenewDeadline:   runLockRenewDeadline,
+		RetryPeriod:     runLockRetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            l.lock.LeaseMeta.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				close(acquired)
+			},
+			OnStoppedLeading: func() {
+				select {
+				case <-acquired:
+					if electorCtx.Err() == nil {
+						l.logger.ErrorContext(ctx, "scan run lock lost, stopping the run")
+					}
+				default:
+				}
+				cancelRun()
+			},
+		},
+	})
+	if err != nil {
+		stopElector()
+		cancelRun()
+		return nil, nil, fmt.Errorf("failed to create the scan run lock: %w", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		elector.Run(electorCtx)
+	}()
+	release := func() {
+		stopElector()
+		<-stopped
+	}
+
+	// the lock is tried right away, then at each retry period
+	timer := time.NewTimer(max(wait, runLockRetryPeriod/2))
+	defer timer.Stop()
+	select {
+	case <-acquired:
+		l.logger.InfoContext(ctx, "scan run lock acquired")
+		return runCtx, release, nil
+	case <-timer.C:
+		release()
+		return nil, nil, fmt.Errorf("%w: %s", ErrRunLockHeld, elector.GetLeader())
+	case <-ctx.Done():
+		release()
+		return nil, nil, fmt.Errorf("waiting for the scan run lock canceled: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunLock(t *testing.T) {
+	clientset := fake.NewClientset()
+
+	runLock1 := NewRunLock(clientset, "kubewarden", RunLockName("policyreport"), slog.Default())
+	runCtx, release, err := runLock1.Acquire(t.Context(), 0)
+	require.NoError(t, err)
+	require.NoError(t, runCtx.Err())
+
+	lease, err := clientset.CoordinationV1().Leases("kubewarden").Get(t.Context(), "audit-scanner-policyreport", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, runLock1.lock.Identity(), *lease.Spec.HolderIdentity)
+
+	// the lock of the other report kind is independent
+	_, releaseOther, err := NewRunLock(clientset, "kubewarden", RunLockName("openreports"), slog.Default()).Acquire(t.Context(), 0)
+	require.NoError(t, err)
+	releaseOther()
+
+	// another run cannot acquire the lock
+	runLock2 := NewRunLock(clientset, "kubewarden", RunLockName("policyreport"), slog.Default())
+	_, _, err = runLock2.Acquire(t.Context(), 0)
+	require.ErrorIs(t, err, ErrRunLockHeld)
+	assert.Contains(t, err.Error(), runLock1.lock.Identity())
+
+	// once released, the lock can be acquired by another run
+	release()
+	require.Error(t, runCtx.Err())
+	_, release, err = runLock2.Acquire(t.Context(), 0)
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,126 @@
+package manifests
+
+import (
+	"fmt"
+	"slices"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// policyKinds are the kinds of the Kubewarden policies.
+var policyKinds = []string{
+	"AdmissionPolicy",
+	"AdmissionPolicyGroup",
+	"ClusterAdmissionPolicy",
+	"ClusterAdmissionPolicyGroup",
+}
+
+// defaultPolicyServer is the PolicyServer of the policies not setting one.
+const defaultPolicyServer = "default"
+
+// NewPolicyClient returns an in-memory client holding the Kubewarden policies
+// defined by the manifests. The policies are considered active, and the
+// fields omitted by the manifests get the defaults of the CRDs.
+//
+// The client also holds a PolicyServer, and its Service in the
+// kubewardenNamespace, for each PolicyServer used by the policies. The
+// reports are written to the client too, so the manifests are audited without
+// touching a cluster.
+func (m *Manifests) NewPolicyClient(scheme *runtime.Scheme, restMapper meta.RESTMapper, defaultNamespace, kubewardenNamespace string) (client.Client, error) {
+	objects := []client.Object{}
+	policyServers := []string{}
+	for _, object := range m.objects {
+		if !isPolicy(object) {
+			continue
+		}
+
+		policy := object.DeepCopy()
+		if policy.GetNamespace() == "" && !isClusterPolicy(policy) {
+			policy.SetNamespace(defaultNamespace)
+		}
+		if policy.GetUID() == "" {
+			policy.SetUID(objectUID(*policy))
+		}
+		if err := setPolicyDefaults(policy); err != nil {
+			return nil, fmt.Errorf("invalid policy %s: %w", policy.GetName(), err)
+		}
+		objects = append(objects, policy)
+
+		policyServer, _, _ := unstructured.NestedString(policy.Object, "spec", "policyServer")
+		if !slices.Contains(policyServers, policyServer) {
+			policyServers = append(policyServers, policyServer)
+		}
+	}
+
+	for _, name := range policyServers {
+		policyServer := &policiesv1.PolicyServer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		}
+		objects = append(objects, policyServer, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      policyServer.NameWithPrefix(),
+				Namespace: kubewardenNamespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/instance": policyServer.NameWithPrefix(),
+				},
+			},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{
+					{
+						Name: "https",
+						Port: 443,
+					},
+				},
+			},
+		})
+	}
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(restMapper).
+		WithObjects(objects...).
+		Build(), nil
+}
+
+func isPolicy(object unstructured.Unstructured) bool {
+	gvk := object.GroupVersionKind()
+	return gvk.Group == policiesv1.GroupVersion.Group && slices.Contains(policyKinds, gvk.Kind)
+}
+
+func isClusterPolicy(object *unstructured.Unstructured) bool {
+	kind := object.GetKind()
+	return kind == "ClusterAdmissionPolicy" || kind == "ClusterAdmissionPolicyGroup"
+}
+
+// setPolicyDefaults sets the defaults of the CRDs on the fields omitted by
+// the given policy, and marks it as active.
+func setPolicyDefaults(policy *unstructured.Unstructured) error {
+	defaults := map[string]any{
+		"policyServer":    defaultPolicyServer,
+		"mode":            "protect",
+		"backgroundAudit": true,
+		"timeoutSeconds":  int64(10),
+	}
+	for field, value := range defaults {
+		if _, found, _ := unstructured.NestedFieldNoCopy(policy.Object, "spec", field); found {
+			continue
+		}
+		if err := unstructured.SetNestedField(policy.Object, value, "spec", field); err != nil {
+			return fmt.Errorf("cannot set spec.%s: %w", field, err)
+		}
+	}
+
+	if err := unstructured.SetNestedField(policy.Object, string(policiesv1.PolicyStatusActive), "status", "policyStatus"); err != nil {
+		return fmt.Errorf("cannot set status.policyStatus: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package manifests loads Kubernetes manifests from files, so they can be
+// audited without deploying them to a cluster.
+package manifests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const decoderBufferSize = 4096
+
+// Manifests holds the objects read from the manifest files.
+type Manifests struct {
+	objects []unstructured.Unstructured
+}
+
+// Load reads the objects defined in the given files and directories. The
+// directories are walked recursively, only the files with the .yaml, .yml and
+// .json extensions are read. A file can define multiple YAML documents and
+// List objects.
+func Load(paths []string) (*Manifests, error) {
+	manifests := &Manifests{}
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			// the files given explicitly are read whatever their extension
+			if filePath != path && !isManifestFile(filePath) {
+				return nil
+			}
+
+			objects, err := readFile(filePath)
+			if err != nil {
+				return err
+			}
+			manifests.objects = append(manifests.objects, objects...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load manifests from %s: %w", path, err)
+		}
+	}
+
+	return manifests, nil
+}
+
+// Objects returns the objects read from the manifest files.
+func (m *Manifests) Objects() []unstructured.Unstructured {
+	return m.objects
+}
+
+func isManifestFile(path string) bool {
+	return slices.Contains([]string{".yaml", ".yml", ".json"}, strings.ToLower(filepath.Ext(path)))
+}
+
+func readFile(path string) ([]unstructured.Unstructured, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	objects := []unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(file, decoderBufferSize)
+	for {
+		content := map[string]any{}
+		if err = decoder.Decode(&content); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		// empty documents, e.g. the ones left by the templates of a Helm chart
+		if len(content) == 0 {
+			continue
+		}
+
+		object := unstructured.Unstructured{Object: content}
+		if object.GetAPIVersion() == "" || object.GetKind() == "" {
+			return nil, fmt.Errorf("invalid object in %s: apiVersion and kind are required", path)
+		}
+		if !object.IsList() {
+			objects = append(objects, object)
+			continue
+		}
+
+		err = object.EachListItem(func(item runtime.Object) error {
+			listItem, ok := item.(*unstructured.Unstructured)
+			if !ok {
+				return errors.New("failed to convert list item to *unstructured.Unstructured")
+			}
+			objects = append(objects, *listItem)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid list in %s: %w", path, err)
+		}
+	}
+
+	return objects, nil
+}
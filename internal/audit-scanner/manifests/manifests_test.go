@@ -0,0 +1,231 @@
+package manifests
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/scheme"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const deploymentsManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: deployment1
+  namespace: namespace1
+---
+# an empty document, like the ones left by the Helm templates
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: deployment2
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: namespace1
+    labels:
+      env: prod
+`
+
+const crdManifest = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: things.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: Thing
+    plural: things
+  versions:
+  - name: v1
+---
+apiVersion: example.com/v1
+kind: Thing
+metadata:
+  name: thing1
+`
+
+const policiesManifest = `
+apiVersion: policies.kubewarden.io/v1
+kind: ClusterAdmissionPolicy
+metadata:
+  name: pod-privileged
+spec:
+  module: registry://ghcr.io/kubewarden/policies/pod-privileged:v0.2.2
+  rules:
+  - apiGroups: [""]
+    apiVersions: ["v1"]
+    resources: ["pods"]
+    operations: ["CREATE"]
+  mutating: false
+---
+apiVersion: policies.kubewarden.io/v1
+kind: AdmissionPolicy
+metadata:
+  name: no-background-audit
+spec:
+  policyServer: reserved
+  backgroundAudit: false
+  module: registry://ghcr.io/kubewarden/policies/pod-privileged:v0.2.2
+  rules:
+  - apiGroups: [""]
+    apiVersions: ["v1"]
+    resources: ["pods"]
+    operations: ["CREATE"]
+  mutating: false
+`
+
+const podManifest = `{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {"name": "pod1", "namespace": "namespace2"}
+}`
+
+func writeManifests(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+
+	files := map[string]string{
+		"deployments.yaml":  deploymentsManifest,
+		"crd.yml":           crdManifest,
+		"policies.yaml":     policiesManifest,
+		"nested/pod.json":   podManifest,
+		"nested/README.txt": "not a manifest",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeManifests(t)
+
+	manifests, err := Load([]string{dir})
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, object := range manifests.Objects() {
+		names = append(names, object.GetKind()+"/"+object.GetName())
+	}
+	assert.ElementsMatch(t, []string{
+		"Deployment/deployment1",
+		"Deployment/deployment2",
+		"Namespace/namespace1",
+		"CustomResourceDefinition/things.example.com",
+		"Thing/thing1",
+		"ClusterAdmissionPolicy/pod-privileged",
+		"AdmissionPolicy/no-background-audit",
+		"Pod/pod1",
+	}, names)
+
+	_, err = Load([]string{filepath.Join(dir, "nested", "README.txt")})
+	require.Error(t, err)
+}
+
+func TestSource(t *testing.T) {
+	manifests, err := Load([]string{writeManifests(t)})
+	require.NoError(t, err)
+	auditScheme, err := scheme.NewScheme()
+	require.NoError(t, err)
+
+	source, err := manifests.NewSource(manifests.NewRESTMapper(nil, auditScheme), "default")
+	require.NoError(t, err)
+
+	deployments, err := source.ListResources(t.Context(), schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, "", metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, deployments.Items, 2)
+	assert.Equal(t, "namespace1", deployments.Items[0].GetNamespace())
+	// the namespaced objects without a namespace are placed in the default one
+	assert.Equal(t, "default", deployments.Items[1].GetNamespace())
+	assert.NotEmpty(t, deployments.Items[1].GetUID())
+
+	deployments, err = source.ListResources(t.Context(), schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, "default", metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, deployments.Items, 1)
+	assert.Equal(t, "deployment2", deployments.Items[0].GetName())
+
+	things, err := source.ListResources(t.Context(), schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "things"}, "", metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, things.Items, 1)
+	assert.Empty(t, things.Items[0].GetNamespace())
+
+	namespaces, err := source.ListNamespaces(t.Context(), metav1.ListOptions{
+		FieldSelector: fields.OneTermNotEqualSelector("metadata.name", "namespace2").String(),
+	})
+	require.NoError(t, err)
+	namespaceNames := []string{}
+	for _, namespace := range namespaces.Items {
+		namespaceNames = append(namespaceNames, namespace.GetName())
+	}
+	assert.Equal(t, []string{"default", "namespace1"}, namespaceNames)
+
+	namespaces, err = source.ListNamespaces(t.Context(), metav1.ListOptions{LabelSelector: "env=prod"})
+	require.NoError(t, err)
+	require.Len(t, namespaces.Items, 1)
+	assert.Equal(t, "namespace1", namespaces.Items[0].GetName())
+
+	namespace, err := source.GetNamespace(t.Context(), "namespace1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod"}, namespace.GetLabels())
+
+	namespace, err = source.GetNamespace(t.Context(), "namespace2")
+	require.NoError(t, err)
+	assert.NotEmpty(t, namespace.GetUID())
+
+	_, err = source.GetNamespace(t.Context(), "namespace3")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestNewPolicyClient(t *testing.T) {
+	manifests, err := Load([]string{writeManifests(t)})
+	require.NoError(t, err)
+	auditScheme, err := scheme.NewScheme()
+	require.NoError(t, err)
+	restMapper := manifests.NewRESTMapper(nil, auditScheme)
+
+	policyClient, err := manifests.NewPolicyClient(auditScheme, restMapper, "default", "kubewarden")
+	require.NoError(t, err)
+
+	clusterAdmissionPolicy := policiesv1.ClusterAdmissionPolicy{}
+	require.NoError(t, policyClient.Get(t.Context(), client.ObjectKey{Name: "pod-privileged"}, &clusterAdmissionPolicy))
+	assert.Equal(t, policiesv1.PolicyStatusActive, clusterAdmissionPolicy.Status.PolicyStatus)
+	assert.Equal(t, "default", clusterAdmissionPolicy.GetPolicyServer())
+	assert.True(t, clusterAdmissionPolicy.GetBackgroundAudit())
+
+	admissionPolicy := policiesv1.AdmissionPolicy{}
+	require.NoError(t, policyClient.Get(t.Context(), client.ObjectKey{Name: "no-background-audit", Namespace: "default"}, &admissionPolicy))
+	assert.False(t, admissionPolicy.GetBackgroundAudit())
+
+	for _, policyServerName := range []string{"default", "reserved"} {
+		require.NoError(t, policyClient.Get(t.Context(), client.ObjectKey{Name: policyServerName}, &policiesv1.PolicyServer{}))
+		require.NoError(t, policyClient.Get(t.Context(), client.ObjectKey{Name: "policy-server-" + policyServerName, Namespace: "kubewarden"}, &corev1.Service{}))
+	}
+
+	policiesClient := policies.NewClient(policyClient, nil, "kubewarden", "https://localhost:3000", nil, slog.Default())
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	auditablePolicies, err := policiesClient.GetPoliciesByNamespace(t.Context(), namespace)
+	require.NoError(t, err)
+	assert.Equal(t, 1, auditablePolicies.PolicyNum)
+	assert.Equal(t, 1, auditablePolicies.SkippedNum)
+	assert.Len(t, auditablePolicies.PoliciesByGVR[schema.GroupVersionResource{Version: "v1", Resource: "pods"}], 1)
+}
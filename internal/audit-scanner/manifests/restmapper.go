@@ -0,0 +1,193 @@
+package manifests
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// rootScopedKinds are the built-in and the Kubewarden kinds that are not
+// namespaced. A scheme doesn't tell the scope of its kinds.
+var rootScopedKinds = map[schema.GroupKind]struct{}{
+	{Group: "", Kind: "Namespace"}:                                                    {},
+	{Group: "", Kind: "Node"}:                                                         {},
+	{Group: "", Kind: "PersistentVolume"}:                                             {},
+	{Group: "", Kind: "ComponentStatus"}:                                              {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                         {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                  {},
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                   {},
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                      {},
+	{Group: "storage.k8s.io", Kind: "CSINode"}:                                        {},
+	{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                               {},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}:   {},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:     {},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:        {},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}: {},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingAdmissionPolicy"}:          {},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingAdmissionPolicyBinding"}:   {},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                               {},
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                      {},
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                                {},
+	{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:                 {},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                       {},
+	{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:       {},
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:                 {},
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                             {},
+	{Group: "policies.kubewarden.io", Kind: "ClusterAdmissionPolicy"}:                 {},
+	{Group: "policies.kubewarden.io", Kind: "ClusterAdmissionPolicyGroup"}:            {},
+	{Group: "policies.kubewarden.io", Kind: "PolicyServer"}:                           {},
+	{Group: "wgpolicyk8s.io", Kind: "ClusterPolicyReport"}:                            {},
+	{Group: "openreports.io", Kind: "ClusterReport"}:                                  {},
+	{Group: "authentication.k8s.io", Kind: "TokenReview"}:                             {},
+	{Group: "authorization.k8s.io", Kind: "SelfSubjectAccessReview"}:                  {},
+	{Group: "authorization.k8s.io", Kind: "SubjectAccessReview"}:                      {},
+	{Group: "authorization.k8s.io", Kind: "SelfSubjectRulesReview"}:                   {},
+	{Group: "internal.apiserver.k8s.io", Kind: "StorageVersion"}:                      {},
+	{Group: "networking.k8s.io", Kind: "IPAddress"}:                                   {},
+	{Group: "networking.k8s.io", Kind: "ServiceCIDR"}:                                 {},
+	{Group: "resource.k8s.io", Kind: "DeviceClass"}:                                   {},
+	{Group: "resource.k8s.io", Kind: "ResourceSlice"}:                                 {},
+	{Group: "storagemigration.k8s.io", Kind: "StorageVersionMigration"}:               {},
+}
+
+// irregularPlurals are the built-in kinds whose resource name cannot be
+// guessed from the kind.
+var irregularPlurals = map[schema.GroupKind]string{
+	{Group: "", Kind: "Endpoints"}: "endpoints",
+}
+
+// NewRESTMapper returns a RESTMapper knowing the kinds used by the manifests,
+// so the resources can be resolved without discovering the API server.
+//
+// The kinds are resolved by the base RESTMapper first, then by the
+// CustomResourceDefinitions defined by the manifests. When base is nil, the
+// kinds of the given scheme are used instead of the API server ones. The
+// resource names and the scopes of the kinds unknown to all of them are
+// guessed: objects with a namespace are considered namespaced.
+func (m *Manifests) NewRESTMapper(base meta.RESTMapper, scheme *runtime.Scheme) meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	crdKinds := map[schema.GroupKind]struct{}{}
+	for _, object := range m.objects {
+		for _, crd := range crdMappings(object) {
+			addMapping(mapper, crd.gvk, crd.plural, crd.scope)
+			crdKinds[crd.gvk.GroupKind()] = struct{}{}
+		}
+	}
+
+	if base == nil {
+		for gvk := range scheme.AllKnownTypes() {
+			if !isResourceKind(gvk) {
+				continue
+			}
+			if _, ok := crdKinds[gvk.GroupKind()]; ok {
+				continue
+			}
+			addMapping(mapper, gvk, irregularPlurals[gvk.GroupKind()], kindScope(gvk.GroupKind()))
+		}
+	}
+
+	var known meta.RESTMapper = mapper
+	if base != nil {
+		known = meta.MultiRESTMapper{base, mapper}
+	}
+	for _, object := range m.objects {
+		gvk := object.GroupVersionKind()
+		if _, err := known.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			continue
+		}
+		scope := meta.RESTScopeRoot
+		if object.GetNamespace() != "" {
+			scope = meta.RESTScopeNamespace
+		}
+		addMapping(mapper, gvk, "", scope)
+	}
+
+	return known
+}
+
+type crdMapping struct {
+	gvk    schema.GroupVersionKind
+	plural string
+	scope  meta.RESTScope
+}
+
+// crdMappings returns the mappings of the versions defined by the given
+// object, when it's a CustomResourceDefinition.
+func crdMappings(object unstructured.Unstructured) []crdMapping {
+	if object.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}) {
+		return nil
+	}
+
+	group, _, _ := unstructured.NestedString(object.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(object.Object, "spec", "names", "kind")
+	plural, _, _ := unstructured.NestedString(object.Object, "spec", "names", "plural")
+	scopeName, _, _ := unstructured.NestedString(object.Object, "spec", "scope")
+	versions, _, _ := unstructured.NestedSlice(object.Object, "spec", "versions")
+
+	scope := meta.RESTScopeNamespace
+	if scopeName == "Cluster" {
+		scope = meta.RESTScopeRoot
+	}
+
+	mappings := []crdMapping{}
+	for _, version := range versions {
+		versionMap, ok := version.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(versionMap, "name")
+		if name == "" || kind == "" {
+			continue
+		}
+		mappings = append(mappings, crdMapping{
+			gvk:    schema.GroupVersionKind{Group: group, Version: name, Kind: kind},
+			plural: plural,
+			scope:  scope,
+		})
+	}
+	return mappings
+}
+
+// addMapping adds the given kind to the mapper. The resource name is guessed
+// from the kind when plural is empty.
+func addMapping(mapper *meta.DefaultRESTMapper, gvk schema.GroupVersionKind, plural string, scope meta.RESTScope) {
+	if plural == "" {
+		mapper.Add(gvk, scope)
+		return
+	}
+	mapper.AddSpecific(
+		gvk,
+		gvk.GroupVersion().WithResource(plural),
+		gvk.GroupVersion().WithResource(strings.ToLower(gvk.Kind)),
+		scope,
+	)
+}
+
+// isResourceKind filters out the kinds of a scheme that are not stored by the
+// API server, like the lists and the options.
+func isResourceKind(gvk schema.GroupVersionKind) bool {
+	if gvk.Version == runtime.APIVersionInternal {
+		return false
+	}
+	switch {
+	case strings.HasSuffix(gvk.Kind, "List"),
+		strings.HasSuffix(gvk.Kind, "Options"),
+		gvk.Kind == "WatchEvent",
+		gvk.Kind == "Status",
+		gvk.Kind == "APIGroup",
+		gvk.Kind == "APIVersions",
+		gvk.Kind == "APIResourceList":
+		return false
+	}
+	return true
+}
+
+func kindScope(groupKind schema.GroupKind) meta.RESTScope {
+	if _, ok := rootScopedKinds[groupKind]; ok {
+		return meta.RESTScopeRoot
+	}
+	return meta.RESTScopeNamespace
+}
@@ -0,0 +1,154 @@
+package manifests
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// uidNamespace is used to build the UIDs of the objects missing one. The
+// reports are named after the UID of the audited resources, so the UIDs must
+// be stable across the runs.
+var uidNamespace = uuid.MustParse("1e2b0c8e-4c3a-4a59-9a3e-5f1d7b2a8c64")
+
+// Source is the k8s.ResourceSource of the objects read from the manifest files.
+type Source struct {
+	// resources holds the objects by GVR
+	resources map[schema.GroupVersionResource][]unstructured.Unstructured
+	// namespaces holds the namespaces defined or referenced by the
+	// manifests, by name
+	namespaces map[string]*corev1.Namespace
+}
+
+// NewSource returns the source of the objects of the manifests. The given
+// RESTMapper resolves their resources and scope. The namespaced objects
+// without a namespace are placed in defaultNamespace, like kubectl does.
+func (m *Manifests) NewSource(restMapper meta.RESTMapper, defaultNamespace string) (*Source, error) {
+	source := &Source{
+		resources:  map[schema.GroupVersionResource][]unstructured.Unstructured{},
+		namespaces: map[string]*corev1.Namespace{},
+	}
+
+	for _, object := range m.objects {
+		object = *object.DeepCopy()
+		gvk := object.GroupVersionKind()
+		mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find the resource of %s %s: %w", gvk.String(), object.GetName(), err)
+		}
+
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if object.GetNamespace() == "" {
+				object.SetNamespace(defaultNamespace)
+			}
+			source.addNamespace(object.GetNamespace(), nil)
+		} else {
+			object.SetNamespace("")
+		}
+		if object.GetUID() == "" {
+			object.SetUID(objectUID(object))
+		}
+
+		if gvk.GroupKind() == (schema.GroupKind{Kind: "Namespace"}) {
+			namespace := &corev1.Namespace{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, namespace); err != nil {
+				return nil, fmt.Errorf("invalid namespace %s: %w", object.GetName(), err)
+			}
+			source.addNamespace(namespace.GetName(), namespace)
+		}
+
+		source.resources[mapping.Resource] = append(source.resources[mapping.Resource], object)
+	}
+
+	return source, nil
+}
+
+// addNamespace adds the given namespace. The namespaces only referenced by
+// the objects are built from their name.
+func (s *Source) addNamespace(name string, namespace *corev1.Namespace) {
+	if namespace == nil {
+		if _, ok := s.namespaces[name]; ok {
+			return
+		}
+		namespace = &corev1.Namespace{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				UID:  namespaceUID(name),
+			},
+		}
+	}
+	s.namespaces[name] = namespace
+}
+
+// ListResources returns all the objects of the given GVR in the given
+// namespace, or in all the namespaces when it's empty. The objects are
+// returned in a single page.
+func (s *Source) ListResources(_ context.Context, gvr schema.GroupVersionResource, nsName string, _ metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	for _, object := range s.resources[gvr] {
+		if nsName != "" && object.GetNamespace() != nsName {
+			continue
+		}
+		list.Items = append(list.Items, *object.DeepCopy())
+	}
+	return list, nil
+}
+
+// ListNamespaces returns the namespaces matching the field and label
+// selectors of the given options, sorted by name.
+func (s *Source) ListNamespaces(_ context.Context, opts metav1.ListOptions) (*corev1.NamespaceList, error) {
+	fieldSelector, err := fields.ParseSelector(opts.FieldSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field selector %s: %w", opts.FieldSelector, err)
+	}
+	labelSelector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector %s: %w", opts.LabelSelector, err)
+	}
+
+	names := make([]string, 0, len(s.namespaces))
+	for name := range s.namespaces {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	list := &corev1.NamespaceList{}
+	for _, name := range names {
+		namespace := s.namespaces[name]
+		if fieldSelector.Matches(fields.Set{"metadata.name": name}) && labelSelector.Matches(labels.Set(namespace.GetLabels())) {
+			list.Items = append(list.Items, *namespace.DeepCopy())
+		}
+	}
+	return list, nil
+}
+
+// GetNamespace returns the namespace with the given name.
+func (s *Source) GetNamespace(_ context.Context, nsName string) (*corev1.Namespace, error) {
+	namespace, ok := s.namespaces[nsName]
+	if !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("namespaces"), nsName)
+	}
+	return namespace.DeepCopy(), nil
+}
+
+func objectUID(object unstructured.Unstructured) types.UID {
+	key := fmt.Sprintf("%s/%s/%s", object.GroupVersionKind().GroupKind().String(), object.GetNamespace(), object.GetName())
+	return types.UID(uuid.NewSHA1(uidNamespace, []byte(key)).String())
+}
+
+func namespaceUID(name string) types.UID {
+	return types.UID(uuid.NewSHA1(uidNamespace, []byte("Namespace//"+name)).String())
+}
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+)
+
+var csvHeader = []string{
+	"runUID",
+	"policy",
+	"apiVersion",
+	"kind",
+	"namespace",
+	"name",
+	"uid",
+	"result",
+	"severity",
+	"category",
+	"message",
+	"timestamp",
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func newCSVWriter(out io.Writer) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(out)}
+}
+
+func (w *csvWriter) Write(entries []report.ResultEntry) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		w.headerWritten = true
+	}
+
+	for _, entry := range entries {
+		if err := w.writer.Write([]string{
+			entry.RunUID,
+			entry.Policy,
+			entry.Resource.APIVersion,
+			entry.Resource.Kind,
+			entry.Resource.Namespace,
+			entry.Resource.Name,
+			entry.Resource.UID,
+			entry.Result,
+			entry.Severity,
+			entry.Category,
+			entry.Message,
+			entry.Timestamp.Format(time.RFC3339),
+		}); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+	}
+	w.writer.Flush()
+
+	if err := w.writer.Error(); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	// write the header even when there are no results
+	return w.Write(nil)
+}
@@ -0,0 +1,109 @@
+package output
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+)
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// junitWriter writes a test suite per policy, with a test case per audited
+// resource. The report is written when the writer is closed.
+type junitWriter struct {
+	out     io.Writer
+	entries []report.ResultEntry
+}
+
+func newJUnitWriter(out io.Writer) *junitWriter {
+	return &junitWriter{out: out}
+}
+
+func (w *junitWriter) Write(entries []report.ResultEntry) error {
+	w.entries = append(w.entries, entries...)
+	return nil
+}
+
+func (w *junitWriter) Close() error {
+	suites := junitTestSuites{Name: toolName}
+	suitesByPolicy := map[string]*junitTestSuite{}
+	for _, entry := range w.entries {
+		suite, found := suitesByPolicy[entry.Policy]
+		if !found {
+			suite = &junitTestSuite{Name: entry.Policy}
+			suitesByPolicy[entry.Policy] = suite
+		}
+
+		testCase := junitTestCase{
+			Name:      resourceName(entry.Resource),
+			ClassName: entry.Policy,
+		}
+		switch entry.Result {
+		case report.ResultFail:
+			testCase.Failure = &junitFailure{Message: entry.Message, Type: entry.Severity}
+			suite.Failures++
+		case report.ResultError:
+			testCase.Error = &junitFailure{Message: entry.Message}
+			suite.Errors++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	for _, suite := range suitesByPolicy {
+		slices.SortFunc(suite.TestCases, func(a, b junitTestCase) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.TestSuites = append(suites.TestSuites, *suite)
+	}
+	slices.SortFunc(suites.TestSuites, func(a, b junitTestSuite) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	if _, err := io.WriteString(w.out, xml.Header); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	encoder := xml.NewEncoder(w.out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	if _, err := io.WriteString(w.out, "\n"); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
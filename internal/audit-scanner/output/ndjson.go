@@ -0,0 +1,31 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+)
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(out io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{encoder: json.NewEncoder(out)}
+}
+
+func (w *ndjsonWriter) Write(entries []report.ResultEntry) error {
+	for _, entry := range entries {
+		// the encoder terminates each value with a newline
+		if err := w.encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package output writes the results of the audit in formats meant to be
+// consumed by other tools, like CI pipelines and code scanning dashboards.
+package output
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+)
+
+// Format is the format of the audit results.
+type Format string
+
+const (
+	// FormatNDJSON writes a JSON object per line for each result
+	FormatNDJSON Format = "ndjson"
+	// FormatSARIF writes a SARIF 2.1.0 log with the fail and error results
+	FormatSARIF Format = "sarif"
+	// FormatJUnit writes a JUnit XML report, with a test suite per policy
+	FormatJUnit Format = "junit"
+	// FormatCSV writes a CSV row for each result, after a header row
+	FormatCSV Format = "csv"
+)
+
+// SupportedFormats returns the formats supported by NewWriter.
+func SupportedFormats() []Format {
+	return []Format{FormatNDJSON, FormatSARIF, FormatJUnit, FormatCSV}
+}
+
+// IsStreamed returns true when the results are written as soon as they are
+// available. Otherwise, they are written only when the Writer is closed.
+func (f Format) IsStreamed() bool {
+	return f == FormatNDJSON || f == FormatCSV
+}
+
+// Writer writes the audit results. It's safe for concurrent use.
+type Writer interface {
+	// Write writes the given results, or keeps them until the Writer is
+	// closed when the format is not streamed.
+	Write(entries []report.ResultEntry) error
+	// Close writes the results not written yet. It does not close the
+	// underlying io.Writer.
+	Close() error
+}
+
+// NewWriter returns a Writer of the given format.
+func NewWriter(format Format, out io.Writer) (Writer, error) {
+	var writer Writer
+	switch format {
+	case FormatNDJSON:
+		writer = newNDJSONWriter(out)
+	case FormatSARIF:
+		writer = newSARIFWriter(out)
+	case FormatJUnit:
+		writer = newJUnitWriter(out)
+	case FormatCSV:
+		writer = newCSVWriter(out)
+	default:
+		return nil, fmt.Errorf("unsupported output format %q: supported values are %v", format, SupportedFormats())
+	}
+
+	return &syncWriter{writer: writer}, nil
+}
+
+// syncWriter serializes the calls to a Writer, the results of the
+// resources are written by concurrent workers.
+type syncWriter struct {
+	mutex  sync.Mutex
+	writer Writer
+}
+
+func (w *syncWriter) Write(entries []report.ResultEntry) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.writer.Write(entries)
+}
+
+func (w *syncWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.writer.Close()
+}
+
+// resourceName returns a human-readable reference to the resource of a result,
+// e.g. "apps/v1 Deployment default/my-deployment".
+func resourceName(resource report.ResourceReference) string {
+	name := resource.Name
+	if resource.Namespace != "" {
+		name = resource.Namespace + "/" + resource.Name
+	}
+
+	return fmt.Sprintf("%s %s %s", resource.APIVersion, resource.Kind, name)
+}
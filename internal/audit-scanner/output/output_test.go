@@ -0,0 +1,165 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntries() []report.ResultEntry {
+	timestamp := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	deployment := report.ResourceReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Namespace:  "default",
+		Name:       "deployment1",
+		UID:        "deployment1-uid",
+	}
+	namespace := report.ResourceReference{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       "default",
+		UID:        "default-uid",
+	}
+
+	return []report.ResultEntry{
+		{
+			RunUID:    "runUID",
+			Policy:    "clusterwide-safe-labels",
+			Resource:  deployment,
+			Result:    "fail",
+			Severity:  "high",
+			Category:  "Resource validation",
+			Message:   "The following mandatory labels are missing: cost-center",
+			Timestamp: timestamp,
+		},
+		{
+			RunUID:    "runUID",
+			Policy:    "clusterwide-safe-labels",
+			Resource:  namespace,
+			Result:    "pass",
+			Timestamp: timestamp,
+		},
+		{
+			RunUID:    "runUID",
+			Policy:    "namespaced-default-no-privileged",
+			Resource:  deployment,
+			Result:    "error",
+			Message:   "policy server unavailable",
+			Timestamp: timestamp,
+		},
+	}
+}
+
+func writeEntries(t *testing.T, format Format) string {
+	t.Helper()
+
+	out := bytes.Buffer{}
+	writer, err := NewWriter(format, &out)
+	require.NoError(t, err)
+	entries := testEntries()
+	require.NoError(t, writer.Write(entries[:1]))
+	require.NoError(t, writer.Write(entries[1:]))
+	require.NoError(t, writer.Close())
+
+	return out.String()
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(writeEntries(t, FormatNDJSON)), "\n")
+	require.Len(t, lines, 3)
+
+	entry := report.ResultEntry{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, testEntries()[0], entry)
+}
+
+func TestCSVWriter(t *testing.T) {
+	records, err := csv.NewReader(strings.NewReader(writeEntries(t, FormatCSV))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, []string{
+		"runUID",
+		"clusterwide-safe-labels",
+		"apps/v1",
+		"Deployment",
+		"default",
+		"deployment1",
+		"deployment1-uid",
+		"fail",
+		"high",
+		"Resource validation",
+		"The following mandatory labels are missing: cost-center",
+		"2024-03-01T12:00:00Z",
+	}, records[1])
+}
+
+func TestCSVWriterWithoutResults(t *testing.T) {
+	out := bytes.Buffer{}
+	writer, err := NewWriter(FormatCSV, &out)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{csvHeader}, records)
+}
+
+func TestSARIFWriter(t *testing.T) {
+	log := sarifLog{}
+	require.NoError(t, json.Unmarshal([]byte(writeEntries(t, FormatSARIF)), &log))
+
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	assert.Equal(t, []sarifRule{{ID: "clusterwide-safe-labels"}, {ID: "namespaced-default-no-privileged"}}, log.Runs[0].Tool.Driver.Rules)
+
+	// the passing result is not a finding
+	results := log.Runs[0].Results
+	require.Len(t, results, 2)
+	assert.Equal(t, "clusterwide-safe-labels", results[0].RuleID)
+	assert.Equal(t, "error", results[0].Level)
+	assert.Equal(t, "The following mandatory labels are missing: cost-center", results[0].Message.Text)
+	assert.Equal(t, "apps/v1 Deployment default/deployment1", results[0].Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, "namespaced-default-no-privileged", results[1].RuleID)
+	assert.Equal(t, "error", results[1].Level)
+	assert.Equal(t, "error", results[1].Properties["result"])
+}
+
+func TestJUnitWriter(t *testing.T) {
+	suites := junitTestSuites{}
+	require.NoError(t, xml.Unmarshal([]byte(writeEntries(t, FormatJUnit)), &suites))
+
+	assert.Equal(t, 3, suites.Tests)
+	assert.Equal(t, 1, suites.Failures)
+	assert.Equal(t, 1, suites.Errors)
+	require.Len(t, suites.TestSuites, 2)
+
+	safeLabels := suites.TestSuites[0]
+	assert.Equal(t, "clusterwide-safe-labels", safeLabels.Name)
+	assert.Equal(t, 2, safeLabels.Tests)
+	require.Len(t, safeLabels.TestCases, 2)
+	assert.Equal(t, "apps/v1 Deployment default/deployment1", safeLabels.TestCases[0].Name)
+	require.NotNil(t, safeLabels.TestCases[0].Failure)
+	assert.Equal(t, "The following mandatory labels are missing: cost-center", safeLabels.TestCases[0].Failure.Message)
+	assert.Equal(t, "v1 Namespace default", safeLabels.TestCases[1].Name)
+	assert.Nil(t, safeLabels.TestCases[1].Failure)
+
+	noPrivileged := suites.TestSuites[1]
+	assert.Equal(t, 1, noPrivileged.Errors)
+	require.NotNil(t, noPrivileged.TestCases[0].Error)
+}
+
+func TestNewWriterWithUnsupportedFormat(t *testing.T) {
+	_, err := NewWriter("yaml", &bytes.Buffer{})
+	require.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolName     = "kubewarden-audit-scanner"
+	toolURI      = "https://docs.kubewarden.io/explanations/audit-scanner"
+)
+
+// The subset of the SARIF 2.1.0 format used by the audit scanner.
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifWriter keeps the fail and error results, the passing resources are not
+// findings. The SARIF log is written when the writer is closed.
+type sarifWriter struct {
+	out     io.Writer
+	rules   []string
+	results []sarifResult
+}
+
+func newSARIFWriter(out io.Writer) *sarifWriter {
+	return &sarifWriter{out: out}
+}
+
+func (w *sarifWriter) Write(entries []report.ResultEntry) error {
+	for _, entry := range entries {
+		if entry.Result == report.ResultPass {
+			continue
+		}
+		if !slices.Contains(w.rules, entry.Policy) {
+			w.rules = append(w.rules, entry.Policy)
+		}
+
+		message := entry.Message
+		if message == "" {
+			message = fmt.Sprintf("%s: %s", entry.Policy, entry.Result)
+		}
+		w.results = append(w.results, sarifResult{
+			RuleID:  entry.Policy,
+			Level:   sarifLevel(entry),
+			Message: sarifMessage{Text: message},
+			Locations: []sarifLocation{{
+				LogicalLocations: []sarifLogicalLocation{{
+					FullyQualifiedName: resourceName(entry.Resource),
+					Kind:               "resource",
+				}},
+			}},
+			Properties: map[string]string{
+				"result":   entry.Result,
+				"severity": entry.Severity,
+				"category": entry.Category,
+				"uid":      entry.Resource.UID,
+				"runUID":   entry.RunUID,
+			},
+		})
+	}
+	return nil
+}
+
+func (w *sarifWriter) Close() error {
+	slices.Sort(w.rules)
+	rules := make([]sarifRule, 0, len(w.rules))
+	for _, rule := range w.rules {
+		rules = append(rules, sarifRule{ID: rule})
+	}
+	results := w.results
+	if results == nil {
+		results = []sarifResult{}
+	}
+
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           toolName,
+				InformationURI: toolURI,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+
+	encoder := json.NewEncoder(w.out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(log); err != nil {
+		return fmt.Errorf("failed to write SARIF log: %w", err)
+	}
+	return nil
+}
+
+// sarifLevel maps the policy result and severity to a SARIF level. Errored
+// evaluations are always reported as errors.
+func sarifLevel(entry report.ResultEntry) string {
+	if entry.Result == report.ResultError {
+		return "error"
+	}
+
+	switch entry.Severity {
+	case "critical", "high":
+		return "error"
+	case "info", "low":
+		return "note"
+	default:
+		return "warning"
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/url"
 	"slices"
+	"strings"
 
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
@@ -16,6 +17,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/client-go/discovery"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -23,16 +26,60 @@ import (
 type Client struct {
 	// client is a controller-runtime client extended with the Kubewarden CRDs
 	client client.Client
+	// discoveryClient is used to expand the wildcards in the policy rules.
+	// When nil, the rules with wildcards are skipped
+	discoveryClient discovery.DiscoveryInterface
+	// wildcardDenylist is the list of resources never targeted when expanding
+	// the wildcards in the policy rules
+	wildcardDenylist []schema.GroupResource
 	// Namespace where the Kubewarden components (e.g. policy server) are installed
 	// This is the namespace used to get the policy server resources
 	kubewardenNamespace string
 	// FQDN of the policy server to query. If not empty, it will query on port 3000.
 	// Useful for out-of-cluster debugging
 	policyServerURL string
+	// filter restricts the audited policies and resources
+	filter Filter
+	// matchConditions caches the compiled matchConditions of the policies
+	matchConditions matchConditionsCache
+	// endpoints caches the ready replicas of the PolicyServers
+	endpoints *endpointsCache
 	// logger is used to log the messages
 	logger *slog.Logger
 }
 
+// Filter restricts an audit to a subset of the policies and resources.
+// The zero value audits everything.
+type Filter struct {
+	// Policies are the unique names of the audited policies. When empty, all
+	// the policies are audited
+	Policies []string
+	// IncludeResources are the only audited resources. When empty, all the
+	// resources targeted by the policies are audited
+	IncludeResources []schema.GroupResource
+	// ExcludeResources are never audited, even when included
+	ExcludeResources []schema.GroupResource
+}
+
+// IsEmpty returns true when the filter audits everything.
+func (f Filter) IsEmpty() bool {
+	return len(f.Policies) == 0 && len(f.IncludeResources) == 0 && len(f.ExcludeResources) == 0
+}
+
+// matchesPolicy returns true when the policy with the given unique name is audited.
+func (f Filter) matchesPolicy(uniqueName string) bool {
+	return len(f.Policies) == 0 || slices.Contains(f.Policies, uniqueName)
+}
+
+// matchesResource returns true when the given resource is audited.
+func (f Filter) matchesResource(gvr schema.GroupVersionResource) bool {
+	groupResource := gvr.GroupResource()
+	if len(f.IncludeResources) > 0 && !slices.Contains(f.IncludeResources, groupResource) {
+		return false
+	}
+	return !slices.Contains(f.ExcludeResources, groupResource)
+}
+
 // Policies represents a collection of auditable policies.
 type Policies struct {
 	// PoliciesByGVR a map of policies grouped by GVR
@@ -43,28 +90,72 @@ type Policies struct {
 	SkippedNum int
 	// ErroredNum represents the number of errored policies. These policies may be misconfigured
 	ErroredNum int
+	// SkippedPolicies holds the sorted unique names of the skipped policies
+	SkippedPolicies []string
+	// ErroredPolicies holds the sorted unique names of the errored policies
+	ErroredPolicies []string
 }
 
 // Policy represents a policy and the URL of the policy server where it is running.
 type Policy struct {
 	policiesv1.Policy
 	PolicyServer *url.URL
+	// PolicyServerService is the Service of the PolicyServer, whose ready
+	// replicas are resolved with GetPolicyServerEndpoints when the requests
+	// are sent. It's nil when the URL of the PolicyServers is overridden
+	PolicyServerService *PolicyServerService
+	// Operation is the operation of the admission request sent to the policy
+	// server. It is CREATE, unless the policy targets the resource only on UPDATE
+	Operation admissionregistrationv1.OperationType
+	// MatchConditions are the compiled matchConditions of the policy, nil
+	// when it has none
+	MatchConditions plugincel.ConditionEvaluator
 }
 
 // NewClient returns a policy Client.
-func NewClient(client client.Client, kubewardenNamespace string, policyServerURL string, logger *slog.Logger) *Client {
+func NewClient(client client.Client, discoveryClient discovery.DiscoveryInterface, kubewardenNamespace string, policyServerURL string, wildcardDenylist []schema.GroupResource, logger *slog.Logger) *Client {
 	if policyServerURL != "" {
 		logger.Info(fmt.Sprintf("querying PolicyServers at %s for debugging purposes. Don't forget to start `kubectl port-forward` if needed", policyServerURL))
 	}
 
 	return &Client{
 		client:              client,
+		discoveryClient:     discoveryClient,
+		wildcardDenylist:    wildcardDenylist,
 		kubewardenNamespace: kubewardenNamespace,
 		policyServerURL:     policyServerURL,
+		endpoints:           newEndpointsCache(),
 		logger:              logger.With("client", "policyclient"),
 	}
 }
 
+// InvalidateDiscovery drops the resources discovered so far, when the
+// discovery client caches them. The next wildcard rules are expanded with
+// the resources served by the API server at that time.
+func (f *Client) InvalidateDiscovery() {
+	if cachedDiscoveryClient, ok := f.discoveryClient.(discovery.CachedDiscoveryInterface); ok {
+		cachedDiscoveryClient.Invalidate()
+	}
+}
+
+// SetFilter restricts the policies returned by the client, and the resources
+// they are grouped by, to the ones matching the given filter.
+func (f *Client) SetFilter(filter Filter) {
+	f.filter = filter
+}
+
+// Filtered returns true when the client does not return all the auditable
+// policies and resources.
+func (f *Client) Filtered() bool {
+	return !f.filter.IsEmpty()
+}
+
+// FilteredPolicies returns the unique names of the audited policies, or nil
+// when the audit is not restricted to some policies.
+func (f *Client) FilteredPolicies() []string {
+	return f.filter.Policies
+}
+
 // GetPoliciesByNamespace gets all the auditable policies for a given namespace.
 func (f *Client) GetPoliciesByNamespace(ctx context.Context, namespace *corev1.Namespace) (*Policies, error) {
 	var policies []policiesv1.Policy
@@ -244,7 +335,20 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 	erroredPolicies := map[string]struct{}{}
 
 	for _, policy := range policies {
-		rules := filterWildcardRules(policy.GetRules())
+		// the policies not selected by the filter are not part of the audit,
+		// they are neither counted as skipped
+		if !f.filter.matchesPolicy(policy.GetUniqueName()) {
+			continue
+		}
+
+		rules, err := f.expandWildcardRules(ctx, policy.GetRules())
+		if err != nil {
+			erroredPolicies[policy.GetUniqueName()] = struct{}{}
+			f.logger.ErrorContext(ctx, "failed to expand the wildcards of the policy rules, skipping as error...",
+				slog.String("error", err.Error()),
+				slog.String("policy", policy.GetUniqueName()))
+			continue
+		}
 		if len(rules) == 0 {
 			skippedPolicies[policy.GetUniqueName()] = struct{}{}
 			f.logger.DebugContext(ctx, "the policy targets only wildcard resources, skipping...", slog.String("policy", policy.GetUniqueName()))
@@ -252,10 +356,10 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 			continue
 		}
 
-		rules = filterNonCreateOperations(rules)
+		rules = filterNonAuditableOperations(rules)
 		if len(rules) == 0 {
 			skippedPolicies[policy.GetUniqueName()] = struct{}{}
-			f.logger.DebugContext(ctx, "the policy does not have rules with a CREATE operation, skipping...", slog.String("policy", policy.GetUniqueName()))
+			f.logger.DebugContext(ctx, "the policy does not have rules with a CREATE or UPDATE operation, skipping...", slog.String("policy", policy.GetUniqueName()))
 
 			continue
 		}
@@ -269,6 +373,9 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 			continue
 		}
 
+		groupVersionResources = slices.DeleteFunc(groupVersionResources, func(gvr schema.GroupVersionResource) bool {
+			return !f.filter.matchesResource(gvr)
+		})
 		if len(groupVersionResources) == 0 {
 			f.logger.DebugContext(ctx, "the policy does not target resources within the selected scope",
 				slog.String("policy", policy.GetUniqueName()),
@@ -292,7 +399,16 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 			continue
 		}
 
-		url, err := f.getPolicyServerURLRunningPolicy(ctx, policy)
+		matchConditions, usesAuthorizer := f.matchConditions.compile(policy)
+		if usesAuthorizer {
+			skippedPolicies[policy.GetUniqueName()] = struct{}{}
+			f.logger.InfoContext(ctx, "the policy matchConditions use the authorizer, which is not available when auditing, skipping...",
+				slog.String("policy", policy.GetUniqueName()))
+
+			continue
+		}
+
+		url, service, err := f.getPolicyServerURLRunningPolicy(ctx, policy)
 		if err != nil {
 			erroredPolicies[policy.GetUniqueName()] = struct{}{}
 			f.logger.ErrorContext(ctx, "failed to obtain matching policy-server URL, skipping as error...",
@@ -306,24 +422,37 @@ func (f *Client) groupPoliciesByGVR(ctx context.Context, policies []policiesv1.P
 		setTypeMeta(policy)
 
 		auditablePolicies[policy.GetUniqueName()] = struct{}{}
-		policy := &Policy{
-			Policy:       policy,
-			PolicyServer: url,
-		}
-
 		for _, gvr := range groupVersionResources {
-			addPolicyToMap(policiesByGVR, gvr, policy)
+			addPolicyToMap(policiesByGVR, gvr, &Policy{
+				Policy:              policy,
+				PolicyServer:        url,
+				PolicyServerService: service,
+				Operation:           getAuditOperation(rules, gvr),
+				MatchConditions:     matchConditions,
+			})
 		}
 	}
 
 	return &Policies{
-		PoliciesByGVR: policiesByGVR,
-		PolicyNum:     len(auditablePolicies),
-		SkippedNum:    len(skippedPolicies),
-		ErroredNum:    len(erroredPolicies),
+		PoliciesByGVR:   policiesByGVR,
+		PolicyNum:       len(auditablePolicies),
+		SkippedNum:      len(skippedPolicies),
+		ErroredNum:      len(erroredPolicies),
+		SkippedPolicies: sortedKeys(skippedPolicies),
+		ErroredPolicies: sortedKeys(erroredPolicies),
 	}, nil
 }
 
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
 func addPolicyToMap(policiesByGVR map[schema.GroupVersionResource][]*Policy, gvr schema.GroupVersionResource, policy *Policy) {
 	value, found := policiesByGVR[gvr]
 	if !found {
@@ -353,10 +482,18 @@ func getRuleGVRs(rule admissionregistrationv1.RuleWithOperations) []schema.Group
 // if namespaced is true, it will skip cluster-wide resources, otherwise it will skip namespaced resources.
 func (f *Client) getGroupVersionResources(rules []admissionregistrationv1.RuleWithOperations, namespaced bool) ([]schema.GroupVersionResource, error) {
 	var groupVersionResources []schema.GroupVersionResource
+	// the same resource can be targeted by multiple rules, for example
+	// when a wildcard rule is expanded
+	seen := map[schema.GroupVersionResource]struct{}{}
 
 	for _, rule := range rules {
 		gvrs := getRuleGVRs(rule)
 		for _, gvr := range gvrs {
+			if _, found := seen[gvr]; found {
+				continue
+			}
+			seen[gvr] = struct{}{}
+
 			isNamespaced, err := f.isNamespacedResource(gvr)
 			if err != nil {
 				return nil, err
@@ -392,33 +529,55 @@ func (f *Client) isNamespacedResource(gvr schema.GroupVersionResource) (bool, er
 	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
 }
 
-func (f *Client) getPolicyServerURLRunningPolicy(ctx context.Context, policy policiesv1.Policy) (*url.URL, error) {
+// getPolicyServerURLRunningPolicy returns the URL of the policy on its
+// PolicyServer, and the Service of the PolicyServer. The Service is not
+// returned when the URL of the PolicyServers is overridden, the requests are
+// not sent to its replicas then.
+func (f *Client) getPolicyServerURLRunningPolicy(ctx context.Context, policy policiesv1.Policy) (*url.URL, *PolicyServerService, error) {
 	policyServer, err := f.getPolicyServerByName(ctx, policy.GetPolicyServer())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	service, err := f.getServiceByInstanceLabel(ctx, policyServer.NameWithPrefix(), f.kubewardenNamespace)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if len(service.Spec.Ports) < 1 {
-		return nil, errors.New("policy server service does not have a port")
+		return nil, nil, errors.New("policy server service does not have a port")
 	}
 	var urlStr string
+	var policyServerService *PolicyServerService
 	if f.policyServerURL != "" {
 		parsedURL, parseErr := url.Parse(f.policyServerURL)
 		if parseErr != nil {
-			return nil, fmt.Errorf("failed to parse policy server URL %q: %w", f.policyServerURL, parseErr)
+			return nil, nil, fmt.Errorf("failed to parse policy server URL %q: %w", f.policyServerURL, parseErr)
 		}
 		urlStr = fmt.Sprintf("%s/audit/%s", parsedURL, policy.GetUniqueName())
 	} else {
 		urlStr = fmt.Sprintf("https://%s.%s.svc:%d/audit/%s", service.Name, f.kubewardenNamespace, service.Spec.Ports[0].Port, policy.GetUniqueName())
+		policyServerService = newPolicyServerService(service)
 	}
 	url, err := url.Parse(urlStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse policy server URL %q: %w", urlStr, err)
+		return nil, nil, fmt.Errorf("failed to parse policy server URL %q: %w", urlStr, err)
 	}
-	return url, nil
+	return url, policyServerService, nil
+}
+
+// GetPolicyServerEndpoints returns the addresses, in the host:port format, of
+// the ready replicas of the PolicyServer of the given Service. They are
+// cached for a short time, so the replicas replaced by a rollout are seen
+// while the scan is running.
+func (f *Client) GetPolicyServerEndpoints(ctx context.Context, service *PolicyServerService) ([]string, error) {
+	return f.endpoints.get(ctx, f.client, service)
+}
+
+// InvalidatePolicyServerEndpoints drops the cached replicas of the
+// PolicyServer of the given Service when the replica of the given address,
+// which cannot be reached anymore, is one of them. They are resolved again by
+// the next request.
+func (f *Client) InvalidatePolicyServerEndpoints(service *PolicyServerService, address string) {
+	f.endpoints.invalidate(service, address)
 }
 
 func (f *Client) getPolicyServerByName(ctx context.Context, policyServerName string) (*policiesv1.PolicyServer, error) {
@@ -450,9 +609,7 @@ func (f *Client) getServiceByInstanceLabel(ctx context.Context, instanceName str
 func filterWildcardRules(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
 	filteredRules := []admissionregistrationv1.RuleWithOperations{}
 	for _, rule := range rules {
-		if slices.Contains(rule.APIGroups, "*") ||
-			slices.Contains(rule.APIVersions, "*") ||
-			slices.Contains(rule.Resources, "*") {
+		if hasWildcard(rule) {
 			continue
 		}
 		filteredRules = append(filteredRules, rule)
@@ -461,11 +618,115 @@ func filterWildcardRules(rules []admissionregistrationv1.RuleWithOperations) []a
 	return filteredRules
 }
 
-// filterNonCreateOperations filters out rules that do not contain a CREATE operation.
-func filterNonCreateOperations(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
+// expandWildcardRules replaces the rules containing a wildcard in the APIGroups,
+// APIVersions or Resources fields with rules targeting the concrete resources
+// served by the API server. Only the resources that can be listed and watched
+// are considered, subresources and the resources in the denylist are ignored.
+// When the wildcard is in the APIVersions field, only the preferred version of
+// each group is used, to avoid auditing the same resources multiple times.
+// If there's no discovery client, the rules with wildcards are filtered out.
+func (f *Client) expandWildcardRules(ctx context.Context, rules []admissionregistrationv1.RuleWithOperations) ([]admissionregistrationv1.RuleWithOperations, error) {
+	if f.discoveryClient == nil {
+		return filterWildcardRules(rules), nil
+	}
+
+	expandedRules := []admissionregistrationv1.RuleWithOperations{}
+	var apiResourceLists []*metav1.APIResourceList
+	var preferredVersions map[string]string
+
+	for _, rule := range rules {
+		if !hasWildcard(rule) {
+			expandedRules = append(expandedRules, rule)
+			continue
+		}
+
+		if apiResourceLists == nil {
+			apiGroups, resourceLists, err := f.discoveryClient.ServerGroupsAndResources()
+			if err != nil {
+				if !discovery.IsGroupDiscoveryFailedError(err) {
+					return nil, fmt.Errorf("failed to discover the resources served by the API server: %w", err)
+				}
+				// some groups could not be discovered, continue with the ones
+				// that have been discovered
+				f.logger.WarnContext(ctx, "failed to discover some API groups, their resources are not audited by wildcard rules",
+					slog.String("error", err.Error()))
+			}
+
+			apiResourceLists = resourceLists
+			preferredVersions = make(map[string]string, len(apiGroups))
+			for _, apiGroup := range apiGroups {
+				preferredVersions[apiGroup.Name] = apiGroup.PreferredVersion.Version
+			}
+		}
+
+		for _, apiResourceList := range apiResourceLists {
+			groupVersion, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse group version %q: %w", apiResourceList.GroupVersion, err)
+			}
+			if !slices.Contains(rule.APIGroups, "*") && !slices.Contains(rule.APIGroups, groupVersion.Group) {
+				continue
+			}
+			if slices.Contains(rule.APIVersions, "*") {
+				if preferredVersions[groupVersion.Group] != groupVersion.Version {
+					continue
+				}
+			} else if !slices.Contains(rule.APIVersions, groupVersion.Version) {
+				continue
+			}
+
+			for _, apiResource := range apiResourceList.APIResources {
+				if !f.isAuditableWildcardResource(rule, groupVersion.Group, apiResource) {
+					continue
+				}
+
+				expandedRules = append(expandedRules, admissionregistrationv1.RuleWithOperations{
+					Operations: rule.Operations,
+					Rule: admissionregistrationv1.Rule{
+						APIGroups:   []string{groupVersion.Group},
+						APIVersions: []string{groupVersion.Version},
+						Resources:   []string{apiResource.Name},
+						Scope:       rule.Scope,
+					},
+				})
+			}
+		}
+	}
+
+	return expandedRules, nil
+}
+
+// isAuditableWildcardResource checks if the resource discovered from the API
+// server has to be audited by the given wildcard rule.
+func (f *Client) isAuditableWildcardResource(rule admissionregistrationv1.RuleWithOperations, group string, apiResource metav1.APIResource) bool {
+	// skip subresources, they cannot be listed
+	if strings.Contains(apiResource.Name, "/") {
+		return false
+	}
+	if !slices.Contains(rule.Resources, "*") && !slices.Contains(rule.Resources, "*/*") && !slices.Contains(rule.Resources, apiResource.Name) {
+		return false
+	}
+	if !slices.Contains(apiResource.Verbs, "list") || !slices.Contains(apiResource.Verbs, "watch") {
+		return false
+	}
+
+	return !slices.Contains(f.wildcardDenylist, schema.GroupResource{Group: group, Resource: apiResource.Name})
+}
+
+// hasWildcard checks if the rule contains a wildcard in the APIGroups, APIVersions or Resources fields.
+func hasWildcard(rule admissionregistrationv1.RuleWithOperations) bool {
+	return slices.Contains(rule.APIGroups, "*") ||
+		slices.Contains(rule.APIVersions, "*") ||
+		slices.Contains(rule.Resources, "*") ||
+		slices.Contains(rule.Resources, "*/*")
+}
+
+// filterNonAuditableOperations filters out rules that contain neither a CREATE
+// nor an UPDATE operation.
+func filterNonAuditableOperations(rules []admissionregistrationv1.RuleWithOperations) []admissionregistrationv1.RuleWithOperations {
 	filteredRules := []admissionregistrationv1.RuleWithOperations{}
 	for _, rule := range rules {
-		if slices.Contains(rule.Operations, admissionregistrationv1.Create) {
+		if hasOperation(rule, admissionregistrationv1.Create) || hasOperation(rule, admissionregistrationv1.Update) {
 			filteredRules = append(filteredRules, rule)
 		}
 	}
@@ -473,6 +734,24 @@ func filterNonCreateOperations(rules []admissionregistrationv1.RuleWithOperation
 	return filteredRules
 }
 
+// getAuditOperation returns the operation to simulate when auditing the given
+// resource. CREATE is preferred, UPDATE is used only when none of the rules
+// targeting the resource has a CREATE operation.
+func getAuditOperation(rules []admissionregistrationv1.RuleWithOperations, gvr schema.GroupVersionResource) admissionregistrationv1.OperationType {
+	for _, rule := range rules {
+		if hasOperation(rule, admissionregistrationv1.Create) && slices.Contains(getRuleGVRs(rule), gvr) {
+			return admissionregistrationv1.Create
+		}
+	}
+
+	return admissionregistrationv1.Update
+}
+
+// hasOperation checks if the rule contains the given operation, either explicitly or with a wildcard.
+func hasOperation(rule admissionregistrationv1.RuleWithOperations, operation admissionregistrationv1.OperationType) bool {
+	return slices.Contains(rule.Operations, operation) || slices.Contains(rule.Operations, admissionregistrationv1.OperationAll)
+}
+
 // setTypeMeta sets TypeMeta.Kind and APIVersion fields.
 func setTypeMeta(policy policiesv1.Policy) {
 	switch p := policy.(type) {
@@ -4,18 +4,28 @@ import (
 	"log/slog"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
 
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
 )
 
+// defaultPolicyServerService is the Service of the default PolicyServer of
+// the tests.
+var defaultPolicyServerService = &PolicyServerService{Namespace: "kubewarden", Name: "policy-server-default", PortName: "http"}
+
 func TestGetPoliciesByNamespace(t *testing.T) {
 	namespace := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
@@ -259,7 +269,7 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	policiesClient := NewClient(client, "kubewarden", "", logger)
+	policiesClient := NewClient(client, nil, "kubewarden", "", nil, logger)
 
 	policies, err := policiesClient.GetPoliciesByNamespace(t.Context(), namespace)
 	require.NoError(t, err)
@@ -272,16 +282,22 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 				Resource: "pods",
 			}: {
 				{
-					Policy:       clusterAdmissionPolicy1,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Policy:              clusterAdmissionPolicy1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 				{
-					Policy:       admissionPolicy1,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					Policy:              admissionPolicy1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 				{
-					Policy:       admissionPolicyGroup1,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-group-test-admissionPolicyGroup1"},
+					Policy:              admissionPolicyGroup1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-group-test-admissionPolicyGroup1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 			},
 			{
@@ -290,22 +306,34 @@ func TestGetPoliciesByNamespace(t *testing.T) {
 				Resource: "deployments",
 			}: {
 				{
-					Policy:       clusterAdmissionPolicy1,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Policy:              clusterAdmissionPolicy1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 				{
-					Policy:       clusterAdmissionPolicyGroup1,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-group-clusterAdmissionPolicyGroup1"},
+					Policy:              clusterAdmissionPolicyGroup1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-group-clusterAdmissionPolicyGroup1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 				{
-					Policy:       admissionPolicy1,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					Policy:              admissionPolicy1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 			},
 		},
 		PolicyNum:  4,
 		SkippedNum: 3,
 		ErroredNum: 1,
+		SkippedPolicies: []string{
+			"clusterwide-clusterAdmissionPolicy3",
+			"namespaced-test-admissionPolicy2",
+			"namespaced-test-admissionPolicy4",
+		},
+		ErroredPolicies: []string{"namespaced-test-admissionPolicy5"},
 	}
 
 	assert.Equal(t, expectedPolicies, policies)
@@ -400,10 +428,21 @@ func TestGetClusterWidePolicies(t *testing.T) {
 		}).
 		Build()
 
-	// a ClusterAdmissionPolicy with no CREATE operation, it should be skipped
+	// a ClusterAdmissionPolicy with neither a CREATE nor an UPDATE operation, it should be skipped
 	clusterAdmissionPolicy6 := testutils.
 		NewClusterAdmissionPolicyFactory().
 		Name("clusterAdmissionPolicy6").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"namespaces"},
+		}, admissionregistrationv1.Delete, admissionregistrationv1.Connect).
+		Build()
+
+	// a ClusterAdmissionPolicy targeting only the UPDATE operation, it should be audited with UPDATE requests
+	clusterAdmissionPolicy8 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy8").
 		Rule(admissionregistrationv1.Rule{
 			APIGroups:   []string{""},
 			APIVersions: []string{"v1"},
@@ -462,6 +501,7 @@ func TestGetClusterWidePolicies(t *testing.T) {
 		clusterAdmissionPolicy5,
 		clusterAdmissionPolicy6,
 		clusterAdmissionPolicy7,
+		clusterAdmissionPolicy8,
 		clusterAdmissionPolicyGroup1,
 		clusterAdmissionPolicyGroup2,
 		admissionPolicy1,
@@ -469,7 +509,7 @@ func TestGetClusterWidePolicies(t *testing.T) {
 	require.NoError(t, err)
 
 	logger := slog.Default()
-	policiesClient := NewClient(client, "kubewarden", "", logger)
+	policiesClient := NewClient(client, nil, "kubewarden", "", nil, logger)
 
 	policies, err := policiesClient.GetClusterWidePolicies(t.Context())
 	require.NoError(t, err)
@@ -482,27 +522,596 @@ func TestGetClusterWidePolicies(t *testing.T) {
 				Resource: "namespaces",
 			}: {
 				{
-					Policy:       clusterAdmissionPolicy1,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					Policy:              clusterAdmissionPolicy1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
+				},
+				{
+					Policy:              clusterAdmissionPolicy2,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy2"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 				{
-					Policy:       clusterAdmissionPolicy2,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy2"},
+					Policy:              clusterAdmissionPolicy3,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy3"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 				{
-					Policy:       clusterAdmissionPolicy3,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy3"},
+					Policy:              clusterAdmissionPolicy8,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-clusterAdmissionPolicy8"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Update,
 				},
 				{
-					Policy:       clusterAdmissionPolicyGroup1,
-					PolicyServer: &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-group-clusterAdmissionPolicyGroup1"},
+					Policy:              clusterAdmissionPolicyGroup1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/clusterwide-group-clusterAdmissionPolicyGroup1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
 				},
 			},
 		},
-		PolicyNum:  4,
+		PolicyNum:  5,
 		SkippedNum: 2,
 		ErroredNum: 1,
+		SkippedPolicies: []string{
+			"clusterwide-clusterAdmissionPolicy4",
+			"clusterwide-clusterAdmissionPolicy6",
+		},
+		ErroredPolicies: []string{"clusterwide-policy8"},
+	}
+
+	assert.Equal(t, expectedPolicies, policies)
+}
+
+func TestGetPoliciesByNamespaceWithWildcardRules(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	// an AdmissionPolicy targeting all the resources
+	admissionPolicy1 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy1").
+		Namespace("test").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{"*"},
+			APIVersions: []string{"*"},
+			Resources:   []string{"*"},
+		}).
+		Build()
+
+	// an AdmissionPolicy targeting all the resources of the core group
+	admissionPolicy2 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy2").
+		Namespace("test").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"*/*"},
+		}).
+		Build()
+
+	// an AdmissionPolicy targeting only denylisted resources, it should be skipped
+	admissionPolicy3 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy3").
+		Namespace("test").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"*"},
+			Resources:   []string{"events"},
+		}).
+		Build()
+
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		admissionPolicy1,
+		admissionPolicy2,
+		admissionPolicy3,
+	)
+	require.NoError(t, err)
+
+	listAndWatch := metav1.Verbs{"get", "list", "watch", "create", "update", "delete"}
+	discoveryClient := &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: listAndWatch},
+						// subresources cannot be listed, they should be ignored
+						{Name: "pods/log", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get"}},
+						// denylisted, it should be ignored
+						{Name: "events", Namespaced: true, Kind: "Event", Verbs: listAndWatch},
+						// cannot be listed, it should be ignored
+						{Name: "bindings", Namespaced: true, Kind: "Binding", Verbs: metav1.Verbs{"create"}},
+						{Name: "namespaces", Namespaced: false, Kind: "Namespace", Verbs: listAndWatch},
+					},
+				},
+				{
+					GroupVersion: "apps/v1",
+					APIResources: []metav1.APIResource{
+						{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: listAndWatch},
+					},
+				},
+				{
+					// not the preferred version of the group, it should be ignored
+					GroupVersion: "apps/v1beta2",
+					APIResources: []metav1.APIResource{
+						{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: listAndWatch},
+					},
+				},
+			},
+		},
+	}
+
+	logger := slog.Default()
+	policiesClient := NewClient(client, discoveryClient, "kubewarden", "", []schema.GroupResource{{Group: "", Resource: "events"}}, logger)
+
+	policies, err := policiesClient.GetPoliciesByNamespace(t.Context(), namespace)
+	require.NoError(t, err)
+
+	expectedPolicies := &Policies{
+		PoliciesByGVR: map[schema.GroupVersionResource][]*Policy{
+			{
+				Group:    "",
+				Version:  "v1",
+				Resource: "pods",
+			}: {
+				{
+					Policy:              admissionPolicy1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
+				},
+				{
+					Policy:              admissionPolicy2,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy2"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
+				},
+			},
+			{
+				Group:    "apps",
+				Version:  "v1",
+				Resource: "deployments",
+			}: {
+				{
+					Policy:              admissionPolicy1,
+					PolicyServer:        &url.URL{Scheme: "https", Host: "policy-server-default.kubewarden.svc:443", Path: "/audit/namespaced-test-admissionPolicy1"},
+					PolicyServerService: defaultPolicyServerService,
+					Operation:           admissionregistrationv1.Create,
+				},
+			},
+		},
+		PolicyNum:       2,
+		SkippedNum:      1,
+		ErroredNum:      0,
+		SkippedPolicies: []string{"namespaced-test-admissionPolicy3"},
+		ErroredPolicies: []string{},
 	}
 
 	assert.Equal(t, expectedPolicies, policies)
 }
+
+func TestGetPolicyServerEndpoints(t *testing.T) {
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "policy-server",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	endpointSlice := func(name string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "kubewarden",
+				Labels: map[string]string{
+					discoveryv1.LabelServiceName: "policy-server-default",
+				},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   endpoints,
+			Ports: []discoveryv1.EndpointPort{
+				{
+					Name: ptr.To("policy-server"),
+					Port: ptr.To(int32(8443)),
+				},
+			},
+		}
+	}
+	// the endpoints of two replicas, one of them not ready yet, and of a
+	// third replica in another EndpointSlice
+	endpointSlice1 := endpointSlice("policy-server-default-1",
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)}},
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(false)}},
+	)
+	endpointSlice2 := endpointSlice("policy-server-default-2",
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}},
+	)
+
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	client, err := testutils.NewFakeClient(
+		policyServer,
+		policyServerService,
+		endpointSlice1,
+		endpointSlice2,
+		clusterAdmissionPolicy,
+	)
+	require.NoError(t, err)
+
+	policiesClient := NewClient(client, nil, "kubewarden", "", nil, slog.Default())
+	policies, err := policiesClient.GetClusterWidePolicies(t.Context())
+	require.NoError(t, err)
+
+	namespacesPolicies := policies.PoliciesByGVR[schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}]
+	require.Len(t, namespacesPolicies, 1)
+	service := namespacesPolicies[0].PolicyServerService
+	require.NotNil(t, service)
+	assert.Equal(t, &PolicyServerService{Namespace: "kubewarden", Name: "policy-server-default", PortName: "policy-server"}, service)
+
+	now := time.Now()
+	policiesClient.endpoints.now = func() time.Time { return now }
+	endpoints, err := policiesClient.GetPolicyServerEndpoints(t.Context(), service)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:8443", "10.0.0.2:8443"}, endpoints)
+
+	// the endpoints are cached until they expire, the replica becoming
+	// ready is seen after the TTL
+	endpointSlice1.Endpoints[1].Conditions.Ready = ptr.To(true)
+	require.NoError(t, client.Update(t.Context(), endpointSlice1))
+
+	endpoints, err = policiesClient.GetPolicyServerEndpoints(t.Context(), service)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:8443", "10.0.0.2:8443"}, endpoints)
+
+	now = now.Add(endpointsTTL)
+	endpoints, err = policiesClient.GetPolicyServerEndpoints(t.Context(), service)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:8443", "10.0.0.2:8443", "10.0.0.3:8443"}, endpoints)
+
+	// a replica which cannot be reached drops the cached endpoints, but
+	// only when it's one of them
+	require.NoError(t, client.Delete(t.Context(), endpointSlice2))
+	policiesClient.InvalidatePolicyServerEndpoints(service, "10.0.0.4:8443")
+	endpoints, err = policiesClient.GetPolicyServerEndpoints(t.Context(), service)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:8443", "10.0.0.2:8443", "10.0.0.3:8443"}, endpoints)
+
+	policiesClient.InvalidatePolicyServerEndpoints(service, "10.0.0.1:8443")
+	endpoints, err = policiesClient.GetPolicyServerEndpoints(t.Context(), service)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:8443", "10.0.0.3:8443"}, endpoints)
+
+	// the endpoints are not resolved when the PolicyServers URL is overridden
+	policiesClient = NewClient(client, nil, "kubewarden", "https://localhost:3000", nil, slog.Default())
+	policies, err = policiesClient.GetClusterWidePolicies(t.Context())
+	require.NoError(t, err)
+
+	namespacesPolicies = policies.PoliciesByGVR[schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}]
+	require.Len(t, namespacesPolicies, 1)
+	assert.Nil(t, namespacesPolicies[0].PolicyServerService)
+}
+
+func TestGetPoliciesByNamespaceWithFilter(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	// a policy targeting pods and deployments
+	clusterAdmissionPolicy1 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{"apps"},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"deployments"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// a policy targeting only pods
+	clusterAdmissionPolicy2 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy2").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	client, err := testutils.NewFakeClient(
+		namespace,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy1,
+		clusterAdmissionPolicy2,
+	)
+	require.NoError(t, err)
+
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	deploymentsGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	tests := []struct {
+		name             string
+		filter           Filter
+		expectedPolicies map[schema.GroupVersionResource][]string
+	}{
+		{
+			name:   "no filter",
+			filter: Filter{},
+			expectedPolicies: map[schema.GroupVersionResource][]string{
+				podsGVR:        {"clusterwide-clusterAdmissionPolicy1", "clusterwide-clusterAdmissionPolicy2"},
+				deploymentsGVR: {"clusterwide-clusterAdmissionPolicy1"},
+			},
+		},
+		{
+			name:   "policy filter",
+			filter: Filter{Policies: []string{"clusterwide-clusterAdmissionPolicy2"}},
+			expectedPolicies: map[schema.GroupVersionResource][]string{
+				podsGVR: {"clusterwide-clusterAdmissionPolicy2"},
+			},
+		},
+		{
+			name:   "included resources",
+			filter: Filter{IncludeResources: []schema.GroupResource{{Group: "apps", Resource: "deployments"}}},
+			expectedPolicies: map[schema.GroupVersionResource][]string{
+				deploymentsGVR: {"clusterwide-clusterAdmissionPolicy1"},
+			},
+		},
+		{
+			name: "excluded resources",
+			filter: Filter{
+				Policies:         []string{"clusterwide-clusterAdmissionPolicy1"},
+				ExcludeResources: []schema.GroupResource{{Resource: "pods"}},
+			},
+			expectedPolicies: map[schema.GroupVersionResource][]string{
+				deploymentsGVR: {"clusterwide-clusterAdmissionPolicy1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policiesClient := NewClient(client, nil, "kubewarden", "", nil, slog.Default())
+			policiesClient.SetFilter(test.filter)
+			assert.Equal(t, !test.filter.IsEmpty(), policiesClient.Filtered())
+
+			policies, err := policiesClient.GetPoliciesByNamespace(t.Context(), namespace)
+			require.NoError(t, err)
+
+			policiesByGVR := map[schema.GroupVersionResource][]string{}
+			for gvr, gvrPolicies := range policies.PoliciesByGVR {
+				for _, policy := range gvrPolicies {
+					policiesByGVR[gvr] = append(policiesByGVR[gvr], policy.GetUniqueName())
+				}
+			}
+			assert.Equal(t, test.expectedPolicies, policiesByGVR)
+			// the policies not selected are not counted as skipped
+			assert.Zero(t, policies.SkippedNum)
+		})
+	}
+}
+
+func TestGetClusterWidePoliciesWithAuthorizerMatchConditions(t *testing.T) {
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespacesRule := admissionregistrationv1.Rule{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"namespaces"},
+	}
+
+	// the matchConditions don't use the authorizer, the policy is audited
+	clusterAdmissionPolicy1 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(namespacesRule).
+		MatchCondition("not-kube-system", "object.metadata.name != 'kube-system'").
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// the matchConditions use the authorizer, the policy is skipped
+	clusterAdmissionPolicy2 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy2").
+		Rule(namespacesRule).
+		MatchCondition("not-kube-system", "object.metadata.name != 'kube-system'").
+		MatchCondition("not-admin", "!authorizer.group('').resource('namespaces').check('delete').allowed()").
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// the matchConditions don't compile, the error is reported by the scanner
+	clusterAdmissionPolicy3 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy3").
+		Rule(namespacesRule).
+		MatchCondition("not-boolean", "object.metadata.name").
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	client, err := testutils.NewFakeClient(
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy1,
+		clusterAdmissionPolicy2,
+		clusterAdmissionPolicy3,
+	)
+	require.NoError(t, err)
+
+	policiesClient := NewClient(client, nil, "kubewarden", "", nil, slog.Default())
+	policies, err := policiesClient.GetClusterWidePolicies(t.Context())
+	require.NoError(t, err)
+
+	auditedPolicies := []string{}
+	for _, policy := range policies.PoliciesByGVR[schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}] {
+		auditedPolicies = append(auditedPolicies, policy.GetUniqueName())
+	}
+	assert.ElementsMatch(t, []string{"clusterwide-clusterAdmissionPolicy1", "clusterwide-clusterAdmissionPolicy3"}, auditedPolicies)
+	assert.Equal(t, []string{"clusterwide-clusterAdmissionPolicy2"}, policies.SkippedPolicies)
+}
+
+func TestInvalidateDiscovery(t *testing.T) {
+	listAndWatch := metav1.Verbs{"get", "list", "watch"}
+	fakeDiscovery := &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: listAndWatch},
+					},
+				},
+			},
+		},
+	}
+	policiesClient := NewClient(nil, memory.NewMemCacheClient(fakeDiscovery), "kubewarden", "", nil, slog.Default())
+
+	wildcardRules := []admissionregistrationv1.RuleWithOperations{
+		{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"*"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"*"},
+			},
+		},
+	}
+	expandedResources := func() []string {
+		rules, err := policiesClient.expandWildcardRules(t.Context(), wildcardRules)
+		require.NoError(t, err)
+		resources := []string{}
+		for _, rule := range rules {
+			resources = append(resources, rule.Resources...)
+		}
+		return resources
+	}
+	assert.Equal(t, []string{"pods"}, expandedResources())
+
+	// a CRD is created, its resources are discovered once the cache is invalidated
+	fakeDiscovery.Resources = append(fakeDiscovery.Resources, &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "widgets", Namespaced: true, Kind: "Widget", Verbs: listAndWatch},
+		},
+	})
+	assert.Equal(t, []string{"pods"}, expandedResources())
+
+	policiesClient.InvalidateDiscovery()
+	assert.ElementsMatch(t, []string{"pods", "widgets"}, expandedResources())
+}
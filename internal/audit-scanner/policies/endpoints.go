@@ -0,0 +1,144 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// endpointsTTL is how long the resolved endpoints of a PolicyServer Service
+// are reused before its EndpointSlices are listed again.
+const endpointsTTL = 30 * time.Second
+
+// PolicyServerService identifies the Service of a PolicyServer, whose ready
+// replicas the requests are sent to.
+type PolicyServerService struct {
+	Namespace string
+	Name      string
+	// PortName is the name of the Service port the requests are sent to
+	PortName string
+}
+
+func newPolicyServerService(service *corev1.Service) *PolicyServerService {
+	return &PolicyServerService{
+		Namespace: service.GetNamespace(),
+		Name:      service.GetName(),
+		PortName:  service.Spec.Ports[0].Name,
+	}
+}
+
+type cachedEndpoints struct {
+	endpoints []string
+	expiresAt time.Time
+}
+
+// endpointsCache caches the ready endpoints of the PolicyServer Services, so
+// the EndpointSlices are listed once per Service and TTL instead of once per
+// request. The failed lookups are cached without endpoints, so the requests
+// are sent to the Service until they expire instead of listing the
+// EndpointSlices again for each request.
+type endpointsCache struct {
+	mu       sync.Mutex
+	services map[types.NamespacedName]cachedEndpoints
+	// now returns the current time, it is replaced by the tests
+	now func() time.Time
+}
+
+func newEndpointsCache() *endpointsCache {
+	return &endpointsCache{
+		services: make(map[types.NamespacedName]cachedEndpoints),
+		now:      time.Now,
+	}
+}
+
+// get returns the ready endpoints of the given Service, listing its
+// EndpointSlices when they are not cached or when they expired. The error of
+// a failed lookup is only returned by the call listing them.
+func (c *endpointsCache) get(ctx context.Context, k8sClient client.Client, service *PolicyServerService) ([]string, error) {
+	key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+
+	c.mu.Lock()
+	cached, found := c.services[key]
+	c.mu.Unlock()
+	if found && c.now().Before(cached.expiresAt) {
+		return cached.endpoints, nil
+	}
+
+	// the EndpointSlices are listed outside the lock, so the lookups of the
+	// other Services are not blocked by the API server round trip
+	endpoints, err := getReadyEndpoints(ctx, k8sClient, service)
+
+	c.mu.Lock()
+	c.services[key] = cachedEndpoints{
+		endpoints: endpoints,
+		expiresAt: c.now().Add(endpointsTTL),
+	}
+	c.mu.Unlock()
+
+	return endpoints, err
+}
+
+// invalidate drops the cached endpoints of the given Service when they hold
+// the given address, so the concurrent requests failing to reach the same
+// replica list the EndpointSlices once.
+func (c *endpointsCache) invalidate(service *PolicyServerService, address string) {
+	key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if slices.Contains(c.services[key].endpoints, address) {
+		delete(c.services, key)
+	}
+}
+
+// getReadyEndpoints returns the addresses, in the host:port format, of the
+// ready endpoints backing the port of the given Service. They are
+// sorted, so they can be compared.
+func getReadyEndpoints(ctx context.Context, k8sClient client.Client, service *PolicyServerService) ([]string, error) {
+	endpointSlices := discoveryv1.EndpointSliceList{}
+	err := k8sClient.List(ctx, &endpointSlices, &client.ListOptions{Namespace: service.Namespace}, &client.MatchingLabels{discoveryv1.LabelServiceName: service.Name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list EndpointSlices of service %q: %w", service.Name, err)
+	}
+
+	var endpoints []string
+	for _, endpointSlice := range endpointSlices.Items {
+		port := endpointSlicePort(endpointSlice, service.PortName)
+		if port == nil {
+			continue
+		}
+		for _, endpoint := range endpointSlice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				endpoints = append(endpoints, net.JoinHostPort(address, strconv.Itoa(int(*port))))
+			}
+		}
+	}
+	slices.Sort(endpoints)
+
+	return slices.Compact(endpoints), nil
+}
+
+// endpointSlicePort returns the port of the EndpointSlice matching the name
+// of the Service port.
+func endpointSlicePort(endpointSlice discoveryv1.EndpointSlice, name string) *int32 {
+	for _, port := range endpointSlice.Ports {
+		if port.Port != nil && ptr.Deref(port.Name, "") == name {
+			return port.Port
+		}
+	}
+	return nil
+}
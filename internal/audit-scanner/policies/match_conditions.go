@@ -0,0 +1,84 @@
+package policies
+
+import (
+	"sync"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+type matchConditionsKey struct {
+	uniqueName      string
+	uid             string
+	resourceVersion string
+}
+
+type compiledMatchConditions struct {
+	evaluator      plugincel.ConditionEvaluator
+	usesAuthorizer bool
+}
+
+// matchConditionsCache caches the compiled matchConditions of the policies,
+// by policy and resourceVersion, so they are compiled once whatever the
+// number of resources they are evaluated for.
+type matchConditionsCache struct {
+	compiled sync.Map
+}
+
+// compile returns the compiled matchConditions of the policy, nil when it has
+// none, and true when they use the authorizer variable. The API server
+// evaluates these against the permissions of the user performing the request,
+// which the audit scanner cannot reproduce. The matchConditions which don't
+// compile for other reasons are left to the scanner, which reports them as
+// errors.
+func (c *matchConditionsCache) compile(policy policiesv1.Policy) (plugincel.ConditionEvaluator, bool) {
+	if len(policy.GetMatchConditions()) == 0 {
+		return nil, false
+	}
+
+	key := matchConditionsKey{
+		uniqueName:      policy.GetUniqueName(),
+		uid:             string(policy.GetUID()),
+		resourceVersion: policy.GetResourceVersion(),
+	}
+	if compiled, found := c.compiled.Load(key); found {
+		result := compiled.(compiledMatchConditions) //nolint:forcetypeassert // only compiledMatchConditions are stored
+		return result.evaluator, result.usesAuthorizer
+	}
+
+	evaluator := CompileMatchConditions(policy)
+	// the only difference between the two environments is the authorizer
+	// variable, the matchConditions use it when they compile only with it
+	usesAuthorizer := len(evaluator.CompilationErrors()) > 0 &&
+		len(compileMatchConditions(policy, true).CompilationErrors()) == 0
+	c.compiled.Store(key, compiledMatchConditions{evaluator: evaluator, usesAuthorizer: usesAuthorizer})
+
+	return evaluator, usesAuthorizer
+}
+
+// CompileMatchConditions compiles the matchConditions of the policy to be
+// evaluated by the audit scanner, which has no authorizer to offer. The
+// compilation errors are returned by the CompilationErrors method of the
+// evaluator.
+func CompileMatchConditions(policy policiesv1.Policy) plugincel.ConditionEvaluator {
+	return compileMatchConditions(policy, false)
+}
+
+func compileMatchConditions(policy policiesv1.Policy, hasAuthorizer bool) plugincel.ConditionEvaluator {
+	matchConditions := policy.GetMatchConditions()
+	expressions := make([]plugincel.ExpressionAccessor, len(matchConditions))
+	for i, matchCondition := range matchConditions {
+		expressions[i] = &matchconditions.MatchCondition{
+			Name:       matchCondition.Name,
+			Expression: matchCondition.Expression,
+		}
+	}
+	// Policies are already stored in the cluster, so the StoredExpressions
+	// environment is used, like the API server does for webhook configurations.
+	return policiesv1.MatchConditionsCompiler().CompileCondition(expressions, plugincel.OptionalVariableDeclarations{
+		HasParams:     false,
+		HasAuthorizer: hasAuthorizer,
+	}, environment.StoredExpressions)
+}
@@ -0,0 +1,272 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+// Granularity is the number of resources whose results are stored in a report.
+type Granularity string
+
+const (
+	// GranularityResource stores a report per audited resource.
+	GranularityResource Granularity = "resource"
+	// GranularityNamespace stores a report per namespace, and a cluster
+	// report for all the cluster-wide resources, with results keyed by
+	// resource.
+	GranularityNamespace Granularity = "namespace"
+)
+
+// SupportedGranularities returns the supported report granularities.
+func SupportedGranularities() []Granularity {
+	return []Granularity{GranularityResource, GranularityNamespace}
+}
+
+// AggregatedReportName is the prefix of the name of the reports aggregating
+// the results of the resources of a namespace, or of the cluster-wide
+// resources. It's followed by the UID of the run, and the name of each part of
+// the report is suffixed with its number.
+const AggregatedReportName = "kubewarden-audit"
+
+// maxAggregatedReportSize is the size of the results of a part of an
+// aggregated report above which the part is stored and a new one is started.
+// It's below the size limit of the objects stored in etcd, 1.5MiB by default,
+// to leave room for the results of the last resource added and for the rest
+// of the report.
+const maxAggregatedReportSize = 1024 * 1024
+
+// aggregatable is implemented by the reports that can aggregate the results
+// of several resources.
+type aggregatable interface {
+	Report
+	// aggregate appends the results of the given report of a single
+	// resource, keyed by the resource, and returns their encoded size.
+	aggregate(resourceReport Report) (int, error)
+}
+
+// AggregatedReport stores the results of the audited resources of a
+// namespace, or of the cluster-wide resources, in reports keyed by resource
+// instead of a report per resource. The results are split in numbered parts,
+// a new part is started when the results of the current one approach the size
+// limit of the objects. The parts are kept until the report is flushed, so an
+// interrupted scan, which doesn't flush its reports, stores none of them. It's
+// safe for concurrent use.
+type AggregatedReport struct {
+	mutex  sync.Mutex
+	store  Store
+	kind   CrdKind
+	runUID string
+	// namespace of the audited resources, empty for the cluster-wide ones
+	namespace string
+	name      string
+	// labels are added to the labels of the parts of the report
+	labels  map[string]string
+	maxSize int
+	// part is the number of the part being filled
+	part int
+	// filled are the parts filled, not stored yet
+	filled []aggregatable
+	// current is the part being filled, nil when it has no results yet
+	current aggregatable
+	size    int
+}
+
+// NewAggregatedReport returns the report aggregating the results of the
+// audited resources of the given namespace, or of the cluster-wide resources
+// when empty. The parts of the report are named after the given name.
+func NewAggregatedReport(store Store, kind CrdKind, runUID, namespace, name string) *AggregatedReport {
+	return &AggregatedReport{
+		store:     store,
+		kind:      kind,
+		runUID:    runUID,
+		namespace: namespace,
+		name:      name,
+		maxSize:   maxAggregatedReportSize,
+	}
+}
+
+// Add adds the results of the given report of a single resource. The next
+// part is started once the results of the part being filled exceed the
+// maximum size.
+func (r *AggregatedReport) Add(resourceReport Report) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.current == nil {
+		r.current = r.newPart()
+	}
+	size, err := r.current.aggregate(resourceReport)
+	if err != nil {
+		return err
+	}
+	r.size += size
+	if r.size >= r.maxSize {
+		r.filled = append(r.filled, r.current)
+		r.current = nil
+		r.size = 0
+		r.part++
+	}
+	return nil
+}
+
+// Flush stores the parts of the report, if any. The parts left by a failed
+// store are stored by the next flush.
+func (r *AggregatedReport) Flush(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.current != nil {
+		r.filled = append(r.filled, r.current)
+		r.current = nil
+		r.size = 0
+		r.part++
+	}
+	for len(r.filled) > 0 {
+		// the parts are stored in order, the first one left is numbered
+		// after the ones already stored
+		number := r.part - len(r.filled)
+		var err error
+		if r.namespace == "" {
+			err = r.store.CreateOrPatchClusterReport(ctx, r.filled[0])
+		} else {
+			err = r.store.CreateOrPatchReport(ctx, r.filled[0])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to store part %d of report %s: %w", number, r.name, err)
+		}
+		r.filled = r.filled[1:]
+	}
+	return nil
+}
+
+// newPart returns the next empty part of the report.
+func (r *AggregatedReport) newPart() aggregatable {
+	objMeta := metav1.ObjectMeta{
+		Name:      fmt.Sprintf("%s-%d", r.name, r.part),
+		Namespace: r.namespace,
+		Labels: map[string]string{
+			labelAppManagedBy:                 labelApp,
+			labelPolicyReportVersion:          labelPolicyReportVersionValue,
+			constants.AuditScannerRunUIDLabel: r.runUID,
+		},
+	}
+	maps.Copy(objMeta.Labels, r.labels)
+	switch {
+	case r.kind == ReportKindPolicyReport && r.namespace == "":
+		return &ClusterPolicyReport{report: &wgpolicy.ClusterPolicyReport{ObjectMeta: objMeta}}
+	case r.kind == ReportKindPolicyReport:
+		return &PolicyReport{report: &wgpolicy.PolicyReport{ObjectMeta: objMeta}}
+	case r.namespace == "":
+		return &OpenClusterReport{report: &openreports.ClusterReport{ObjectMeta: objMeta}}
+	default:
+		return &OpenReport{report: &openreports.Report{ObjectMeta: objMeta}}
+	}
+}
+
+func (r *PolicyReport) aggregate(resourceReport Report) (int, error) {
+	from, ok := resourceReport.(*PolicyReport)
+	if !ok {
+		return 0, fmt.Errorf("expected *PolicyReport, got %T", resourceReport)
+	}
+	results := aggregatePolicyReportResults(from.report.Scope, from.report.Results, &r.report.Summary)
+	r.report.Summary.Skip = from.report.Summary.Skip
+	r.report.Results = append(r.report.Results, results...)
+	return encodedSize(results)
+}
+
+func (r *ClusterPolicyReport) aggregate(resourceReport Report) (int, error) {
+	from, ok := resourceReport.(*ClusterPolicyReport)
+	if !ok {
+		return 0, fmt.Errorf("expected *ClusterPolicyReport, got %T", resourceReport)
+	}
+	results := aggregatePolicyReportResults(from.report.Scope, from.report.Results, &r.report.Summary)
+	r.report.Summary.Skip = from.report.Summary.Skip
+	r.report.Results = append(r.report.Results, results...)
+	return encodedSize(results)
+}
+
+func (r *OpenReport) aggregate(resourceReport Report) (int, error) {
+	from, ok := resourceReport.(*OpenReport)
+	if !ok {
+		return 0, fmt.Errorf("expected *OpenReport, got %T", resourceReport)
+	}
+	results := aggregateReportResults(from.report.Scope, from.report.Results, &r.report.Summary)
+	r.report.Summary.Skip = from.report.Summary.Skip
+	r.report.Results = append(r.report.Results, results...)
+	return encodedSize(results)
+}
+
+func (r *OpenClusterReport) aggregate(resourceReport Report) (int, error) {
+	from, ok := resourceReport.(*OpenClusterReport)
+	if !ok {
+		return 0, fmt.Errorf("expected *OpenClusterReport, got %T", resourceReport)
+	}
+	results := aggregateReportResults(from.report.Scope, from.report.Results, &r.report.Summary)
+	r.report.Summary.Skip = from.report.Summary.Skip
+	r.report.Results = append(r.report.Results, results...)
+	return encodedSize(results)
+}
+
+// aggregatePolicyReportResults returns a copy of the results of a resource,
+// with the resource as subject, and counts them in the given summary.
+func aggregatePolicyReportResults(scope *corev1.ObjectReference, results []*wgpolicy.PolicyReportResult, summary *wgpolicy.PolicyReportSummary) []*wgpolicy.PolicyReportResult {
+	aggregated := make([]*wgpolicy.PolicyReportResult, 0, len(results))
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		result = result.DeepCopy()
+		if scope != nil {
+			result.Subjects = []*corev1.ObjectReference{scope.DeepCopy()}
+		}
+		switch result.Result {
+		case statusFail:
+			summary.Fail++
+		case statusError:
+			summary.Error++
+		case statusPass:
+			summary.Pass++
+		}
+		aggregated = append(aggregated, result)
+	}
+	return aggregated
+}
+
+// aggregateReportResults returns a copy of the results of a resource, with
+// the resource as subject, and counts them in the given summary.
+func aggregateReportResults(scope *corev1.ObjectReference, results []openreports.ReportResult, summary *openreports.ReportSummary) []openreports.ReportResult {
+	aggregated := make([]openreports.ReportResult, 0, len(results))
+	for _, result := range results {
+		result = *result.DeepCopy()
+		if scope != nil {
+			result.Subjects = []corev1.ObjectReference{*scope}
+		}
+		switch result.Result {
+		case statusFail:
+			summary.Fail++
+		case statusError:
+			summary.Error++
+		case statusPass:
+			summary.Pass++
+		}
+		aggregated = append(aggregated, result)
+	}
+	return aggregated
+}
+
+func encodedSize(results any) (int, error) {
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode the results: %w", err)
+	}
+	return len(encoded), nil
+}
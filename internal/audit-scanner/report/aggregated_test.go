@@ -0,0 +1,204 @@
+package report
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+)
+
+func newAggregatedTestResource(name, namespace string) unstructured.Unstructured {
+	resource := unstructured.Unstructured{}
+	resource.SetUID(types.UID(name + "-uid"))
+	resource.SetName(name)
+	resource.SetNamespace(namespace)
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetResourceVersion("1")
+	return resource
+}
+
+func TestAggregatedReport(t *testing.T) {
+	policy := &policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy-name",
+			Namespace: "namespace",
+		},
+	}
+	rejectedReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "rejected"},
+		},
+	}
+
+	kinds := map[string]CrdKind{
+		"PolicyReport": ReportKindPolicyReport,
+		"OpenReport":   ReportKindOpenReport,
+	}
+	for name, kind := range kinds {
+		t.Run(name, func(t *testing.T) {
+			fakeClient, err := testutils.NewFakeClient()
+			require.NoError(t, err)
+			store := NewReportStoreOfKind(kind, fakeClient, WriterConfig{}, slog.Default())
+
+			aggregatedReport := NewAggregatedReport(store, kind, "runUID", "namespace", AggregatedReportName)
+			resources := []unstructured.Unstructured{
+				newAggregatedTestResource("pod1", "namespace"),
+				newAggregatedTestResource("pod2", "namespace"),
+				newAggregatedTestResource("pod3", "namespace"),
+			}
+			for i, resource := range resources {
+				resourceReport := NewReportOfKind(kind, "runUID", resource)
+				resourceReport.AddResult(policy, rejectedReview, false)
+				if i == 0 {
+					// the results of the first resource fill the first part
+					aggregatedReport.maxSize = 1
+				} else {
+					aggregatedReport.maxSize = maxAggregatedReportSize
+				}
+				require.NoError(t, aggregatedReport.Add(resourceReport))
+			}
+			require.NoError(t, aggregatedReport.Flush(t.Context()))
+
+			var entries []ResultEntry
+			for part, expectedResources := range [][]string{{"pod1"}, {"pod2", "pod3"}} {
+				name := types.NamespacedName{Name: fmt.Sprintf("kubewarden-audit-%d", part), Namespace: "namespace"}
+				var stored Report
+				var fails int
+				if kind == ReportKindPolicyReport {
+					policyReport := &wgpolicy.PolicyReport{}
+					require.NoError(t, fakeClient.Get(t.Context(), name, policyReport))
+					assert.Nil(t, policyReport.Scope)
+					assert.Equal(t, "runUID", policyReport.Labels[auditConstants.AuditScannerRunUIDLabel])
+					fails = policyReport.Summary.Fail
+					stored = &PolicyReport{report: policyReport}
+				} else {
+					openReport := &openreports.Report{}
+					require.NoError(t, fakeClient.Get(t.Context(), name, openReport))
+					assert.Nil(t, openReport.Scope)
+					assert.Equal(t, "runUID", openReport.Labels[auditConstants.AuditScannerRunUIDLabel])
+					fails = openReport.Summary.Fail
+					stored = &OpenReport{report: openReport}
+				}
+				assert.Equal(t, len(expectedResources), fails)
+
+				partEntries := stored.Entries()
+				require.Len(t, partEntries, len(expectedResources))
+				for i, entry := range partEntries {
+					assert.Equal(t, expectedResources[i], entry.Resource.Name)
+					assert.Equal(t, expectedResources[i]+"-uid", entry.Resource.UID)
+				}
+				entries = append(entries, partEntries...)
+			}
+			assert.Len(t, entries, len(resources))
+		})
+	}
+}
+
+func TestAggregatedClusterReport(t *testing.T) {
+	policy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "policy-name",
+		},
+	}
+	allowedReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: true,
+		},
+	}
+
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, slog.Default())
+
+	aggregatedReport := NewAggregatedReport(store, ReportKindOpenReport, "runUID", "", AggregatedReportName)
+	for _, name := range []string{"namespace1", "namespace2"} {
+		resource := newAggregatedTestResource(name, "")
+		resource.SetKind("Namespace")
+		resourceReport := NewClusterReportOfKind(ReportKindOpenReport, "runUID", resource)
+		resourceReport.AddResult(policy, allowedReview, false)
+		require.NoError(t, aggregatedReport.Add(resourceReport))
+	}
+	// nothing is stored until the report is flushed
+	clusterReports := &openreports.ClusterReportList{}
+	require.NoError(t, fakeClient.List(t.Context(), clusterReports))
+	assert.Empty(t, clusterReports.Items)
+
+	require.NoError(t, aggregatedReport.Flush(t.Context()))
+
+	clusterReport := &openreports.ClusterReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "kubewarden-audit-0"}, clusterReport))
+	assert.Equal(t, 2, clusterReport.Summary.Pass)
+	require.Len(t, clusterReport.Results, 2)
+	assert.Equal(t, "namespace1", clusterReport.Results[0].Subjects[0].Name)
+	assert.Equal(t, "namespace2", clusterReport.Results[1].Subjects[0].Name)
+}
+
+func TestAggregatedReportInterrupted(t *testing.T) {
+	policy := &policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy-name",
+			Namespace: "namespace",
+		},
+	}
+	rejectedReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "rejected"},
+		},
+	}
+	// the report of the namespace stored by the previous run
+	previousReport := testutils.NewPolicyReportFactory().
+		Name("kubewarden-audit-previous-run-0").
+		Namespace("namespace").
+		WithAppLabel().
+		RunUID("previous-run").
+		Build()
+
+	fakeClient, err := testutils.NewFakeClient(previousReport)
+	require.NoError(t, err)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, slog.Default())
+
+	aggregatedReport := NewAggregatedReport(store, ReportKindPolicyReport, "run", "namespace", "kubewarden-audit-run")
+	// the results of each resource fill a part
+	aggregatedReport.maxSize = 1
+	for _, name := range []string{"pod1", "pod2", "pod3"} {
+		resourceReport := NewReportOfKind(ReportKindPolicyReport, "run", newAggregatedTestResource(name, "namespace"))
+		resourceReport.AddResult(policy, rejectedReview, false)
+		require.NoError(t, aggregatedReport.Add(resourceReport))
+	}
+
+	// the scan is interrupted before the report is flushed: none of the
+	// parts filled is stored, the report of the previous run is left
+	policyReports := &wgpolicy.PolicyReportList{}
+	require.NoError(t, fakeClient.List(t.Context(), policyReports))
+	require.Len(t, policyReports.Items, 1)
+	assert.Equal(t, "kubewarden-audit-previous-run-0", policyReports.Items[0].Name)
+
+	require.NoError(t, aggregatedReport.Flush(t.Context()))
+
+	require.NoError(t, fakeClient.List(t.Context(), policyReports))
+	names := make([]string, 0, len(policyReports.Items))
+	for _, policyReport := range policyReports.Items {
+		names = append(names, policyReport.Name)
+	}
+	assert.ElementsMatch(t, []string{
+		"kubewarden-audit-previous-run-0",
+		"kubewarden-audit-run-0",
+		"kubewarden-audit-run-1",
+		"kubewarden-audit-run-2",
+	}, names)
+}
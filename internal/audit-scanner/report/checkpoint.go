@@ -0,0 +1,147 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// CheckpointDataKey is the key of the ConfigMap data holding the JSON encoded checkpoint
+	CheckpointDataKey = "checkpoint.json"
+	// LabelCheckpoint identifies the ConfigMaps holding a checkpoint
+	LabelCheckpoint      = "kubewarden.io/audit-scanner-checkpoint"
+	checkpointPrefix     = "audit-scanner-checkpoint-"
+	labelValueCheckpoint = "true"
+)
+
+// Checkpoint holds the progress of a scan run, so the run can be resumed
+// when the scanner is restarted.
+type Checkpoint struct {
+	// Name of the ConfigMap holding the checkpoint
+	Name   string `json:"-"`
+	RunUID string `json:"runUID"`
+	// StartTime is the time the run was started, the checkpoints older than
+	// the maximum age are discarded
+	StartTime metav1.Time `json:"startTime"`
+	// ClusterWide is the progress of the cluster-wide resources scan
+	ClusterWide ResourcesProgress `json:"clusterWide"`
+	// Namespaces holds the progress of the namespaces scans, by namespace name
+	Namespaces map[string]*ResourcesProgress `json:"namespaces,omitempty"`
+}
+
+// ResourcesProgress is the progress of the scan of the cluster-wide resources
+// or of the resources of a namespace.
+type ResourcesProgress struct {
+	// Completed is true once all the resources are audited
+	Completed bool `json:"completed,omitempty"`
+	// CompletedResources are the GVRs whose resources are all audited
+	CompletedResources []string `json:"completedResources,omitempty"`
+	// Continue holds the continue token of the next page of resources to
+	// audit, by GVR
+	Continue map[string]string `json:"continue,omitempty"`
+}
+
+// DeepCopy returns a copy of the checkpoint sharing no memory with it.
+func (c *Checkpoint) DeepCopy() *Checkpoint {
+	checkpoint := *c
+	checkpoint.ClusterWide = c.ClusterWide.deepCopy()
+	if c.Namespaces != nil {
+		checkpoint.Namespaces = make(map[string]*ResourcesProgress, len(c.Namespaces))
+		for nsName, progress := range c.Namespaces {
+			progressCopy := progress.deepCopy()
+			checkpoint.Namespaces[nsName] = &progressCopy
+		}
+	}
+	return &checkpoint
+}
+
+func (p *ResourcesProgress) deepCopy() ResourcesProgress {
+	progress := *p
+	progress.CompletedResources = slices.Clone(p.CompletedResources)
+	progress.Continue = maps.Clone(p.Continue)
+	return progress
+}
+
+// CheckpointName returns the name of the ConfigMap holding the checkpoint of
+// the scan runs storing the given kind of reports.
+func CheckpointName(reportKind string) string {
+	return checkpointPrefix + reportKind
+}
+
+// ShardCheckpointName returns the name of the ConfigMap holding the checkpoint
+// of the given shard of a sharded audit, storing the given kind of reports.
+func ShardCheckpointName(reportKind string, shardIndex int) string {
+	return fmt.Sprintf("%s-shard-%d", CheckpointName(reportKind), shardIndex)
+}
+
+// getCheckpoint returns the checkpoint stored in the given namespace, or
+// constants.ErrResourceNotFound when there's none.
+func getCheckpoint(ctx context.Context, c client.Client, namespace, name string) (*Checkpoint, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, auditConstants.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get checkpoint %s: %w", name, err)
+	}
+
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal([]byte(configMap.Data[CheckpointDataKey]), checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %s: %w", name, err)
+	}
+	checkpoint.Name = name
+	return checkpoint, nil
+}
+
+// createOrPatchCheckpoint stores the checkpoint in a ConfigMap of the given namespace.
+func createOrPatchCheckpoint(ctx context.Context, c client.Client, namespace string, checkpoint *Checkpoint) (controllerutil.OperationResult, error) {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return controllerutil.OperationResultNone, fmt.Errorf("failed to encode the checkpoint: %w", err)
+	}
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      checkpoint.Name,
+		Namespace: namespace,
+	}}
+	operation, err := controllerutil.CreateOrPatch(ctx, c, configMap, func() error {
+		configMap.Labels = map[string]string{
+			labelAppManagedBy:                      labelApp,
+			LabelCheckpoint:                        labelValueCheckpoint,
+			auditConstants.AuditScannerRunUIDLabel: checkpoint.RunUID,
+		}
+		configMap.Data = map[string]string{
+			CheckpointDataKey: string(data),
+		}
+
+		return nil
+	})
+	if err != nil {
+		return operation, fmt.Errorf("failed to create or patch checkpoint %s: %w", configMap.GetName(), err)
+	}
+
+	return operation, nil
+}
+
+// deleteCheckpoint deletes the checkpoint stored in the given namespace, if any.
+func deleteCheckpoint(ctx context.Context, c client.Client, namespace, name string) error {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+	}}
+	if err := c.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete checkpoint %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigMapStore stores the summaries and the checkpoints of the scan runs in
+// ConfigMaps. It's independent of the kind of reports stored by the runs.
+type ConfigMapStore struct {
+	// client is a controller-runtime client
+	client client.Client
+	// logger is used to log the messages
+	logger *slog.Logger
+}
+
+// NewConfigMapStore creates a new ConfigMapStore.
+func NewConfigMapStore(client client.Client, logger *slog.Logger) *ConfigMapStore {
+	return &ConfigMapStore{
+		client: client,
+		logger: logger.With("component", "configmapstore"),
+	}
+}
+
+// CreateOrPatchRunSummary stores the summary of a scan run in a ConfigMap of the given namespace.
+func (s *ConfigMapStore) CreateOrPatchRunSummary(ctx context.Context, namespace string, summary *RunSummary) error {
+	operation, err := createOrPatchRunSummary(ctx, s.client, namespace, summary)
+	if err != nil {
+		return err
+	}
+
+	s.logger.DebugContext(ctx, fmt.Sprintf("Run summary %s", operation),
+		slog.String("name", summary.Name()),
+		slog.String("namespace", namespace))
+
+	return nil
+}
+
+// GetRunSummaries returns the summaries of the given scan run stored in the given namespace.
+func (s *ConfigMapStore) GetRunSummaries(ctx context.Context, scanRunID, namespace string) ([]RunSummary, error) {
+	return getRunSummaries(ctx, s.client, scanRunID, namespace)
+}
+
+// DeleteOldRunSummaries deletes the summaries of the scan runs other than the given one.
+func (s *ConfigMapStore) DeleteOldRunSummaries(ctx context.Context, scanRunID, namespace string) error {
+	s.logger.DebugContext(ctx, "Deleting old run summaries", slog.String("namespace", namespace))
+
+	return deleteOldRunSummaries(ctx, s.client, scanRunID, namespace)
+}
+
+// GetCheckpoint returns the checkpoint of the given name stored in the given namespace.
+func (s *ConfigMapStore) GetCheckpoint(ctx context.Context, namespace, name string) (*Checkpoint, error) {
+	return getCheckpoint(ctx, s.client, namespace, name)
+}
+
+// CreateOrPatchCheckpoint stores the checkpoint of a scan run in a ConfigMap of the given namespace.
+func (s *ConfigMapStore) CreateOrPatchCheckpoint(ctx context.Context, namespace string, checkpoint *Checkpoint) error {
+	operation, err := createOrPatchCheckpoint(ctx, s.client, namespace, checkpoint)
+	if err != nil {
+		return err
+	}
+
+	s.logger.DebugContext(ctx, fmt.Sprintf("Checkpoint %s", operation),
+		slog.String("name", checkpoint.Name),
+		slog.String("namespace", namespace))
+
+	return nil
+}
+
+// DeleteCheckpoint deletes the checkpoint of the given name stored in the given namespace.
+func (s *ConfigMapStore) DeleteCheckpoint(ctx context.Context, namespace, name string) error {
+	s.logger.DebugContext(ctx, "Deleting checkpoint", slog.String("name", name), slog.String("namespace", namespace))
+
+	return deleteCheckpoint(ctx, s.client, namespace, name)
+}
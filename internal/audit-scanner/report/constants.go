@@ -6,6 +6,17 @@ const (
 	propertyPolicyUID             = "policy-uid"
 	propertyPolicyName            = "policy-name"
 	propertyPolicyNamespace       = "policy-namespace"
+	propertyOperation             = "operation"
+	// properties of the results of the mutating policies returning a patch
+	propertyWouldMutate     = "would-mutate"
+	propertyPatch           = "patch"
+	propertyPatchOperations = "patch-operations"
+	propertyPatchTruncated  = "patch-truncated"
+	// properties of the results of the policy groups
+	propertyGroupExpression   = "group-expression"
+	propertyGroupMembers      = "group-members"
+	propertyGroupMemberPrefix = "group-member."
+	propertyGroupMemberSuffix = ".message"
 )
 
 const (
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DualWriteStore is a store for OpenReports Report and ClusterReport that
+// also writes a wgpolicyk8s.io PolicyReport or ClusterPolicyReport copy of
+// each report, so the consumers of the legacy reports can switch over
+// gradually. The reads are served by the OpenReports.
+type DualWriteStore struct {
+	*OpenReportStore
+	// legacy writes the wgpolicyk8s.io copies of the reports
+	legacy *PolicyReportStore
+}
+
+// NewDualWriteStore creates a new DualWriteStore. Both kinds of reports are
+// written through the same rate limited writer.
+func NewDualWriteStore(client client.Client, writerConfig WriterConfig, logger *slog.Logger) *DualWriteStore {
+	writer := newReportWriter(client, writerConfig)
+
+	return &DualWriteStore{
+		OpenReportStore: newOpenReportStore(client, writer, logger),
+		legacy:          newPolicyReportStore(client, writer, logger),
+	}
+}
+
+// CreateOrPatchReport creates or patches a OpenReports Report, and its
+// PolicyReport copy.
+func (s *DualWriteStore) CreateOrPatchReport(ctx context.Context, obj any) error {
+	openReport, ok := obj.(*OpenReport)
+	if !ok {
+		return fmt.Errorf("expected *OpenReport, got %T", obj)
+	}
+	return errors.Join(
+		s.OpenReportStore.CreateOrPatchReport(ctx, openReport),
+		s.legacy.CreateOrPatchReport(ctx, &PolicyReport{report: toPolicyReport(openReport.report)}),
+	)
+}
+
+// DeleteOldReports deletes the OpenReports Reports and the PolicyReports that
+// do not belong to the current scan run.
+func (s *DualWriteStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
+	return errors.Join(
+		s.OpenReportStore.DeleteOldReports(ctx, scanRunID, namespace),
+		s.legacy.DeleteOldReports(ctx, scanRunID, namespace),
+	)
+}
+
+// CreateOrPatchClusterReport creates or patches a OpenReports ClusterReport,
+// and its ClusterPolicyReport copy.
+func (s *DualWriteStore) CreateOrPatchClusterReport(ctx context.Context, obj any) error {
+	openClusterReport, ok := obj.(*OpenClusterReport)
+	if !ok {
+		return fmt.Errorf("expected *OpenClusterReport, got %T", obj)
+	}
+	return errors.Join(
+		s.OpenReportStore.CreateOrPatchClusterReport(ctx, openClusterReport),
+		s.legacy.CreateOrPatchClusterReport(ctx, &ClusterPolicyReport{report: toClusterPolicyReport(openClusterReport.report)}),
+	)
+}
+
+// DeleteOldClusterReports deletes the OpenReports ClusterReports and the
+// ClusterPolicyReports that do not belong to the current scan run.
+func (s *DualWriteStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
+	return errors.Join(
+		s.OpenReportStore.DeleteOldClusterReports(ctx, scanRunID),
+		s.legacy.DeleteOldClusterReports(ctx, scanRunID),
+	)
+}
+
+// DeleteOldPolicyResultsReports deletes the OpenReports reports and the
+// wgpolicyk8s.io reports of the policies that do not belong to the current
+// scan run.
+func (s *DualWriteStore) DeleteOldPolicyResultsReports(ctx context.Context, scanRunID string) error {
+	return errors.Join(
+		s.OpenReportStore.DeleteOldPolicyResultsReports(ctx, scanRunID),
+		s.legacy.DeleteOldPolicyResultsReports(ctx, scanRunID),
+	)
+}
@@ -0,0 +1,67 @@
+package report
+
+import (
+	"log/slog"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+func TestDualWriteStore(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewDualWriteStore(fakeClient, WriterConfig{}, slog.Default())
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-pod")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetResourceVersion("12345")
+
+	policy := &policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "namespace", UID: "policy-uid", ResourceVersion: "1"},
+	}
+	admissionReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: false, Result: &metav1.Status{Message: "rejected"}},
+	}
+	openReport := NewOpenReport("runUID", resource)
+	openReport.AddResult(policy, admissionReview, false)
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), openReport))
+
+	// the OpenReport is stored, and read back
+	storedReport := &openreports.Report{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "uid", Namespace: "namespace"}, storedReport))
+	assert.Equal(t, openReport.report.Results, storedReport.Results)
+	report, err := store.GetReport(t.Context(), resource)
+	require.NoError(t, err)
+	assert.Len(t, report.Entries(), 1)
+
+	// along with its legacy copy
+	storedPolicyReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "uid", Namespace: "namespace"}, storedPolicyReport))
+	assert.Equal(t, openReport.report.Labels, storedPolicyReport.Labels)
+	assert.Equal(t, openReport.report.Scope, storedPolicyReport.Scope)
+	assert.Equal(t, wgpolicy.PolicyReportSummary{Fail: 1}, storedPolicyReport.Summary)
+	require.Len(t, storedPolicyReport.Results, 1)
+	assert.Equal(t, "rejected", storedPolicyReport.Results[0].Description)
+	assert.Equal(t, openReport.report.Results[0].Properties, storedPolicyReport.Results[0].Properties)
+
+	// both are deleted by the next run
+	require.NoError(t, store.DeleteOldReports(t.Context(), "nextRunUID", "namespace"))
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "uid", Namespace: "namespace"}, storedReport)
+	assert.True(t, apierrors.IsNotFound(err))
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "uid", Namespace: "namespace"}, storedPolicyReport)
+	assert.True(t, apierrors.IsNotFound(err))
+}
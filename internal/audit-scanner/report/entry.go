@@ -0,0 +1,117 @@
+package report
+
+import (
+	"time"
+
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+// The results of a ResultEntry.
+const (
+	ResultPass  = statusPass
+	ResultFail  = statusFail
+	ResultError = statusError
+)
+
+// ResultEntry is a flat view of a policy result of a report. Its fields are
+// stable, whatever the kind of report, so tools can consume it.
+type ResultEntry struct {
+	RunUID string `json:"runUID"`
+	// Policy is the unique name of the policy
+	Policy   string            `json:"policy"`
+	Resource ResourceReference `json:"resource"`
+	// Result is pass, fail or error
+	Result     string            `json:"result"`
+	Severity   string            `json:"severity,omitempty"`
+	Category   string            `json:"category,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// ResourceReference identifies the audited resource of a ResultEntry.
+type ResourceReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+}
+
+func newResourceReference(scope *corev1.ObjectReference) ResourceReference {
+	if scope == nil {
+		return ResourceReference{}
+	}
+
+	return ResourceReference{
+		APIVersion: scope.APIVersion,
+		Kind:       scope.Kind,
+		Namespace:  scope.Namespace,
+		Name:       scope.Name,
+		UID:        string(scope.UID),
+	}
+}
+
+func policyReportEntries(runUID string, scope *corev1.ObjectReference, results []*wgpolicy.PolicyReportResult) []ResultEntry {
+	entries := make([]ResultEntry, 0, len(results))
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		entries = append(entries, ResultEntry{
+			RunUID:     runUID,
+			Policy:     result.Policy,
+			Resource:   newResourceReference(resultSubject(scope, result.Subjects)),
+			Result:     string(result.Result),
+			Severity:   string(result.Severity),
+			Category:   result.Category,
+			Message:    result.Description,
+			Properties: result.Properties,
+			Timestamp:  timestampToTime(result.Timestamp),
+		})
+	}
+	return entries
+}
+
+func openReportEntries(runUID string, scope *corev1.ObjectReference, results []openreports.ReportResult) []ResultEntry {
+	entries := make([]ResultEntry, 0, len(results))
+	for _, result := range results {
+		entries = append(entries, ResultEntry{
+			RunUID:     runUID,
+			Policy:     result.Policy,
+			Resource:   newResourceReference(resultOpenReportSubject(scope, result.Subjects)),
+			Result:     string(result.Result),
+			Severity:   string(result.Severity),
+			Category:   result.Category,
+			Message:    result.Description,
+			Properties: result.Properties,
+			Timestamp:  timestampToTime(result.Timestamp),
+		})
+	}
+	return entries
+}
+
+// resultSubject returns the resource of a result: the scope of the report, or
+// the subject of the result for the aggregated reports.
+func resultSubject(scope *corev1.ObjectReference, subjects []*corev1.ObjectReference) *corev1.ObjectReference {
+	if scope == nil && len(subjects) > 0 {
+		return subjects[0]
+	}
+	return scope
+}
+
+// resultOpenReportSubject returns the resource of a result: the scope of the
+// report, or the subject of the result for the aggregated reports.
+func resultOpenReportSubject(scope *corev1.ObjectReference, subjects []corev1.ObjectReference) *corev1.ObjectReference {
+	if scope == nil && len(subjects) > 0 {
+		return &subjects[0]
+	}
+	return scope
+}
+
+func timestampToTime(timestamp metav1.Timestamp) time.Time {
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC()
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Gate decides whether a scan run is acceptable, by counting the policy
+// results considered violations. It's meant to be used in CI pipelines.
+type Gate struct {
+	// FailOn holds the policy results counted as violations, "fail" and/or "error"
+	FailOn []string
+	// MaxViolations is the number of violations tolerated
+	MaxViolations int
+	// Policies restricts the policies considered by the gate, by unique name.
+	// All the policies are considered when empty
+	Policies []string
+}
+
+// GateResult is the outcome of a Gate, it's printed in JSON format so it can be
+// consumed by the CI pipelines.
+type GateResult struct {
+	RunUID string `json:"runUID"`
+	// Passed is false when there are more violations than tolerated, or
+	// when the run is incomplete
+	Passed bool `json:"passed"`
+	// Incomplete is true when the run did not audit all the resources, the
+	// violations of the resources not audited are not counted
+	Incomplete    bool     `json:"incomplete"`
+	FailOn        []string `json:"failOn"`
+	MaxViolations int      `json:"maxViolations"`
+	Violations    int      `json:"violations"`
+	// PolicyViolations holds the violations of each policy with at least one
+	// violation, by policy unique name
+	PolicyViolations map[string]int `json:"policyViolations"`
+	// ErroredPolicies are the gated policies that could not be used, they
+	// are counted as violations when failing on errors
+	ErroredPolicies []string `json:"erroredPolicies"`
+}
+
+// ValidateGateFailOn returns an error when the given results cannot be used
+// with Gate.FailOn.
+func ValidateGateFailOn(failOn []string) error {
+	for _, result := range failOn {
+		if result != statusFail && result != statusError {
+			return fmt.Errorf("invalid result %q: supported values are %q and %q", result, statusFail, statusError)
+		}
+	}
+	return nil
+}
+
+// Evaluate counts the violations of the given run.
+func (g Gate) Evaluate(summary *RunSummary) GateResult {
+	result := GateResult{
+		RunUID:           summary.RunUID,
+		Incomplete:       summary.Incomplete,
+		FailOn:           g.FailOn,
+		MaxViolations:    g.MaxViolations,
+		PolicyViolations: map[string]int{},
+		ErroredPolicies:  []string{},
+	}
+
+	for name, policySummary := range summary.Policies {
+		if !g.gates(name) {
+			continue
+		}
+
+		violations := 0
+		if slices.Contains(g.FailOn, statusFail) {
+			violations += policySummary.Fail
+		}
+		if slices.Contains(g.FailOn, statusError) {
+			violations += policySummary.Error
+		}
+		if violations > 0 {
+			result.PolicyViolations[name] = violations
+			result.Violations += violations
+		}
+	}
+
+	if slices.Contains(g.FailOn, statusError) {
+		for _, name := range summary.ErroredPolicies {
+			if g.gates(name) {
+				result.ErroredPolicies = append(result.ErroredPolicies, name)
+				result.Violations++
+			}
+		}
+	}
+
+	// the resources not audited by an incomplete run may violate the
+	// policies too
+	result.Passed = result.Violations <= g.MaxViolations && !summary.Incomplete
+
+	return result
+}
+
+// UnknownPolicies returns the gated policies that are not part of the given
+// run, their names are likely misspelled.
+func (g Gate) UnknownPolicies(summary *RunSummary) []string {
+	unknown := []string{}
+	for _, name := range g.Policies {
+		_, found := summary.Policies[name]
+		if !found && !slices.Contains(summary.SkippedPolicies, name) && !slices.Contains(summary.ErroredPolicies, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+func (g Gate) gates(policyName string) bool {
+	return len(g.Policies) == 0 || slices.Contains(g.Policies, policyName)
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateEvaluate(t *testing.T) {
+	summary := &RunSummary{
+		RunUID: "runUID",
+		Policies: map[string]*PolicySummary{
+			"clusterwide-policy1":       {Pass: 10, Fail: 2, Error: 1},
+			"clusterwide-policy2":       {Pass: 5, Fail: 1},
+			"namespaced-default-policy": {Pass: 3, Skip: 4},
+		},
+		ErroredPolicies: []string{"clusterwide-misconfigured"},
+	}
+
+	tests := []struct {
+		name                     string
+		gate                     Gate
+		expectedPassed           bool
+		expectedViolations       int
+		expectedPolicyViolations map[string]int
+		expectedErroredPolicies  []string
+	}{
+		{
+			name:               "fail on fail results",
+			gate:               Gate{FailOn: []string{"fail"}},
+			expectedPassed:     false,
+			expectedViolations: 3,
+			expectedPolicyViolations: map[string]int{
+				"clusterwide-policy1": 2,
+				"clusterwide-policy2": 1,
+			},
+			expectedErroredPolicies: []string{},
+		},
+		{
+			name:               "fail on error results, including the errored policies",
+			gate:               Gate{FailOn: []string{"error"}},
+			expectedPassed:     false,
+			expectedViolations: 2,
+			expectedPolicyViolations: map[string]int{
+				"clusterwide-policy1": 1,
+			},
+			expectedErroredPolicies: []string{"clusterwide-misconfigured"},
+		},
+		{
+			name:               "violations within the threshold",
+			gate:               Gate{FailOn: []string{"fail", "error"}, MaxViolations: 5},
+			expectedPassed:     true,
+			expectedViolations: 5,
+			expectedPolicyViolations: map[string]int{
+				"clusterwide-policy1": 3,
+				"clusterwide-policy2": 1,
+			},
+			expectedErroredPolicies: []string{"clusterwide-misconfigured"},
+		},
+		{
+			name:               "only the selected policies",
+			gate:               Gate{FailOn: []string{"fail", "error"}, Policies: []string{"clusterwide-policy2", "namespaced-default-policy"}},
+			expectedPassed:     false,
+			expectedViolations: 1,
+			expectedPolicyViolations: map[string]int{
+				"clusterwide-policy2": 1,
+			},
+			expectedErroredPolicies: []string{},
+		},
+		{
+			name:                     "policies without violations",
+			gate:                     Gate{FailOn: []string{"fail"}, Policies: []string{"namespaced-default-policy"}},
+			expectedPassed:           true,
+			expectedViolations:       0,
+			expectedPolicyViolations: map[string]int{},
+			expectedErroredPolicies:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.gate.Evaluate(summary)
+
+			assert.Equal(t, "runUID", result.RunUID)
+			assert.Equal(t, test.expectedPassed, result.Passed)
+			assert.Equal(t, test.expectedViolations, result.Violations)
+			assert.Equal(t, test.expectedPolicyViolations, result.PolicyViolations)
+			assert.Equal(t, test.expectedErroredPolicies, result.ErroredPolicies)
+		})
+	}
+}
+
+func TestGateEvaluateIncompleteRun(t *testing.T) {
+	summary := &RunSummary{
+		RunUID:     "runUID",
+		Incomplete: true,
+		Policies: map[string]*PolicySummary{
+			"clusterwide-policy1": {Pass: 10, Fail: 2},
+		},
+	}
+
+	// the violations are within the threshold, but the resources not
+	// audited may violate the policies too
+	result := Gate{FailOn: []string{"fail"}, MaxViolations: 5}.Evaluate(summary)
+	assert.False(t, result.Passed)
+	assert.True(t, result.Incomplete)
+	assert.Equal(t, 2, result.Violations)
+}
+
+func TestValidateGateFailOn(t *testing.T) {
+	require.NoError(t, ValidateGateFailOn([]string{"fail", "error"}))
+	require.Error(t, ValidateGateFailOn([]string{"warn"}))
+}
+
+func TestGateUnknownPolicies(t *testing.T) {
+	summary := &RunSummary{
+		Policies: map[string]*PolicySummary{
+			"clusterwide-policy1": {Pass: 1},
+			"clusterwide-policy2": {},
+		},
+		SkippedPolicies: []string{"clusterwide-skipped"},
+		ErroredPolicies: []string{"clusterwide-misconfigured"},
+	}
+
+	gate := Gate{
+		FailOn:   []string{"fail"},
+		Policies: []string{"clusterwide-policy1", "clusterwide-policy2", "clusterwide-skipped", "clusterwide-misconfigured", "clusterwide-typo"},
+	}
+	assert.Equal(t, []string{"clusterwide-typo"}, gate.UnknownPolicies(summary))
+	assert.Empty(t, Gate{FailOn: []string{"fail"}}.UnknownPolicies(summary))
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"slices"
+	"strings"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// groupMemberCauseFieldPrefix prefixes the field of the causes of the
+// rejection of a policy group. The PolicyServer reports a cause for each
+// member rejecting the resource, with the field spec.policies.<member name>.
+const groupMemberCauseFieldPrefix = "spec.policies."
+
+// computeGroupProperties returns the properties explaining the result of a
+// policy group: its expression, its members and, when the PolicyServer
+// reports them, the outcomes of the members rejecting the resource. A member
+// rejecting the resource has the property group-member.<name> set to fail,
+// and group-member.<name>.message set to the reason of the rejection.
+func computeGroupProperties(policyGroup policiesv1.PolicyGroup, admissionReview *admissionv1.AdmissionReview) map[string]string {
+	members := policyGroup.GetPolicyGroupMembersWithContext()
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	properties := map[string]string{
+		propertyGroupExpression: policyGroup.GetExpression(),
+		propertyGroupMembers:    strings.Join(names, ","),
+	}
+
+	if admissionReview == nil ||
+		admissionReview.Response == nil ||
+		admissionReview.Response.Result == nil ||
+		admissionReview.Response.Result.Details == nil {
+		return properties
+	}
+	for _, cause := range admissionReview.Response.Result.Details.Causes {
+		name, found := strings.CutPrefix(cause.Field, groupMemberCauseFieldPrefix)
+		if !found {
+			continue
+		}
+		if _, member := members[name]; !member {
+			continue
+		}
+		properties[propertyGroupMemberPrefix+name] = statusFail
+		if cause.Message != "" {
+			properties[propertyGroupMemberPrefix+name+propertyGroupMemberSuffix] = cause.Message
+		}
+	}
+	return properties
+}
@@ -0,0 +1,77 @@
+package report
+
+import (
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAddPolicyGroupResult(t *testing.T) {
+	policy := &policiesv1.ClusterAdmissionPolicyGroup{
+		Spec: policiesv1.ClusterAdmissionPolicyGroupSpec{
+			ClusterPolicyGroupSpec: policiesv1.ClusterPolicyGroupSpec{
+				GroupSpec: policiesv1.GroupSpec{
+					Expression: "signed_by_alice() || signed_by_bob()",
+					Message:    "the image is not signed by Alice or Bob",
+				},
+				Policies: policiesv1.PolicyGroupMembersWithContext{
+					"signed_by_alice": {},
+					"signed_by_bob":   {},
+				},
+			},
+		},
+	}
+	rejectedReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: "the image is not signed by Alice or Bob",
+				Details: &metav1.StatusDetails{
+					Causes: []metav1.StatusCause{
+						{Field: "spec.policies.signed_by_alice", Message: "not signed by Alice"},
+						{Field: "spec.policies.signed_by_bob", Message: "not signed by Bob"},
+						// not a member of the group
+						{Field: "spec.policies.signed_by_eve", Message: "not signed by Eve"},
+					},
+				},
+			},
+		},
+	}
+	allowedReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: true,
+		},
+	}
+
+	reports := map[string]Report{
+		"PolicyReport": NewPolicyReport("runUID", unstructured.Unstructured{}),
+		"OpenReport":   NewOpenReport("runUID", unstructured.Unstructured{}),
+	}
+	for name, report := range reports {
+		t.Run(name, func(t *testing.T) {
+			report.AddResult(policy, rejectedReview, false)
+			report.AddResult(policy, allowedReview, false)
+
+			entries := report.Entries()
+			require.Len(t, entries, 2)
+
+			assert.Equal(t, ResultFail, entries[0].Result)
+			assert.Equal(t, "signed_by_alice() || signed_by_bob()", entries[0].Properties["group-expression"])
+			assert.Equal(t, "signed_by_alice,signed_by_bob", entries[0].Properties["group-members"])
+			assert.Equal(t, "fail", entries[0].Properties["group-member.signed_by_alice"])
+			assert.Equal(t, "not signed by Alice", entries[0].Properties["group-member.signed_by_alice.message"])
+			assert.Equal(t, "fail", entries[0].Properties["group-member.signed_by_bob"])
+			assert.Equal(t, "not signed by Bob", entries[0].Properties["group-member.signed_by_bob.message"])
+			assert.NotContains(t, entries[0].Properties, "group-member.signed_by_eve")
+
+			assert.Equal(t, ResultPass, entries[1].Result)
+			assert.Equal(t, "signed_by_alice() || signed_by_bob()", entries[1].Properties["group-expression"])
+			assert.NotContains(t, entries[1].Properties, "group-member.signed_by_alice")
+		})
+	}
+}
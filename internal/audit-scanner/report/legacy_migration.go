@@ -4,19 +4,128 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 
 	"github.com/google/uuid"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
 )
 
+// migrationPageSize is the number of legacy reports listed at once by the
+// migration.
+const migrationPageSize = 100
+
+// MigrateLegacyPolicyReports converts the wgpolicyk8s.io PolicyReports and
+// ClusterPolicyReports labelled app.kubernetes.io/managed-by=kubewarden into
+// OpenReports Reports and ClusterReports, then deletes them. The results of
+// the previous scans are kept until the next scan replaces them.
+//
+// The legacy reports are not deleted when the conversion fails, the migration
+// is attempted again by the next scan. The reports are written and deleted
+// through the rate limited writer of the store.
+func (s *OpenReportStore) MigrateLegacyPolicyReports(ctx context.Context) error {
+	if err := s.ConvertLegacyPolicyReports(ctx); err != nil {
+		return err
+	}
+	return s.DeleteAllLegacyPolicyReports(ctx)
+}
+
+// ConvertLegacyPolicyReports creates an OpenReports Report or ClusterReport
+// for each wgpolicyk8s.io PolicyReport and ClusterPolicyReport labelled
+// app.kubernetes.io/managed-by=kubewarden that has no OpenReports
+// counterpart yet. The legacy reports are left untouched.
+//
+// The existing OpenReports are never overwritten: they come from a scan more
+// recent than the legacy reports.
+func (s *OpenReportStore) ConvertLegacyPolicyReports(ctx context.Context) error {
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s=%s", labelAppManagedBy, labelApp))
+	if err != nil {
+		return fmt.Errorf("failed to parse label selector: %w", err)
+	}
+
+	converted := 0
+	var existing map[types.NamespacedName]struct{}
+	err = forEachLegacyReportPage(ctx, s.client, &wgpolicy.ClusterPolicyReportList{}, labelSelector, func(list client.ObjectList) error {
+		clusterReports := list.(*wgpolicy.ClusterPolicyReportList) //nolint:forcetypeassert // the list is the one given above
+		if len(clusterReports.Items) == 0 {
+			return nil
+		}
+		// the OpenReports are listed once, with the first legacy reports
+		if existing == nil {
+			var listErr error
+			if existing, listErr = listReportNames(ctx, s.client, openreports.SchemeGroupVersion.WithKind("ClusterReportList")); listErr != nil {
+				return listErr
+			}
+		}
+		for i := range clusterReports.Items {
+			clusterReport := toClusterReport(&clusterReports.Items[i])
+			created, createErr := s.createMissingReport(ctx, existing, clusterReport)
+			if createErr != nil {
+				return createErr
+			}
+			if created {
+				converted++
+			}
+		}
+		return nil
+	})
+	switch {
+	case meta.IsNoMatchError(err):
+		s.logger.DebugContext(ctx, "wgpolicyk8s.io CRDs not installed, skipping legacy clusterreport conversion")
+	case err != nil:
+		return fmt.Errorf("failed to convert legacy ClusterPolicyReports: %w", err)
+	}
+
+	existing = nil
+	err = forEachLegacyReportPage(ctx, s.client, &wgpolicy.PolicyReportList{}, labelSelector, func(list client.ObjectList) error {
+		policyReports := list.(*wgpolicy.PolicyReportList) //nolint:forcetypeassert // the list is the one given above
+		if len(policyReports.Items) == 0 {
+			return nil
+		}
+		// the OpenReports are listed once, with the first legacy reports
+		if existing == nil {
+			var listErr error
+			if existing, listErr = listReportNames(ctx, s.client, openreports.SchemeGroupVersion.WithKind("ReportList")); listErr != nil {
+				return listErr
+			}
+		}
+		for i := range policyReports.Items {
+			report := toReport(&policyReports.Items[i])
+			created, createErr := s.createMissingReport(ctx, existing, report)
+			if createErr != nil {
+				return createErr
+			}
+			if created {
+				converted++
+			}
+		}
+		return nil
+	})
+	switch {
+	case meta.IsNoMatchError(err):
+		s.logger.DebugContext(ctx, "wgpolicyk8s.io CRDs not installed, skipping legacy report conversion")
+	case err != nil:
+		return fmt.Errorf("failed to convert legacy PolicyReports: %w", err)
+	}
+
+	if converted > 0 {
+		s.logger.InfoContext(ctx, "Converted legacy wgpolicyk8s.io reports to OpenReports", slog.Int("reports", converted))
+	}
+	return nil
+}
+
 // DeleteAllLegacyPolicyReports deletes all wgpolicyk8s.io PolicyReports and
 // ClusterPolicyReports labelled app.kubernetes.io/managed-by=kubewarden.
 //
-// This is called once per scan when scans save openreports.
+// This is called once per scan when scans save openreports, once the legacy
+// reports are converted by MigrateLegacyPolicyReports.
 //
 // The deletion is performed with existing functions that do performant
 // deletecollection api calls.
@@ -24,14 +133,14 @@ import (
 // For reducing cost in subsequent runs, it checks for the existence of legacy
 // wgpolicyk8s.io reports before attempting deletion: in clusters with many
 // namespaces the cost after the first migration is just two cheap List calls.
-func DeleteAllLegacyPolicyReports(ctx context.Context, c client.Client, logger *slog.Logger) error {
+func (s *OpenReportStore) DeleteAllLegacyPolicyReports(ctx context.Context) error {
 	// The store.DeleteOldReports() functions that we are reusing delete stale
 	// reports prior to saving a new scan. They do this by using a scanRunID.
 	// Here, a fresh UUID is used as the scanRunID: since no existing report will carry
 	// this UID, the "run-uid != <id>" selector matches all of them, effectively
 	// deleting every kubewarden-managed report.
 	ephemeralRunUID := uuid.New().String()
-	store := NewPolicyReportStore(c, logger)
+	store := newPolicyReportStore(s.client, s.writer, s.logger)
 
 	labelSelector, err := labels.Parse(fmt.Sprintf("%s=%s", labelAppManagedBy, labelApp))
 	if err != nil {
@@ -40,41 +149,228 @@ func DeleteAllLegacyPolicyReports(ctx context.Context, c client.Client, logger *
 	// After first migration, we will perform 2 list calls, one for
 	// ClusterPolicyReport, another for PolicyReport, both with limit=1.
 	listOpts := &client.ListOptions{LabelSelector: labelSelector, Limit: 1}
+	legacyReportsFound := false
 
 	clusterReportList := &wgpolicy.ClusterPolicyReportList{}
-	err = c.List(ctx, clusterReportList, listOpts)
+	err = s.client.List(ctx, clusterReportList, listOpts)
 	switch {
 	case meta.IsNoMatchError(err):
-		logger.DebugContext(ctx, "wgpolicyk8s.io CRDs not installed, skipping legacy clusterreport cleanup")
+		s.logger.DebugContext(ctx, "wgpolicyk8s.io CRDs not installed, skipping legacy clusterreport cleanup")
 	case err != nil:
 		return fmt.Errorf("failed to list legacy ClusterPolicyReports: %w", err)
 	case len(clusterReportList.Items) > 0:
-		logger.InfoContext(ctx, "Deleting legacy wgpolicyk8s.io ClusterPolicyReports")
+		s.logger.InfoContext(ctx, "Deleting legacy wgpolicyk8s.io ClusterPolicyReports")
 		if err = store.DeleteOldClusterReports(ctx, ephemeralRunUID); err != nil {
 			return err
 		}
+		legacyReportsFound = true
 	}
 
 	policyReportList := &wgpolicy.PolicyReportList{}
-	err = c.List(ctx, policyReportList, listOpts)
+	err = s.client.List(ctx, policyReportList, listOpts)
 	switch {
 	case meta.IsNoMatchError(err):
-		logger.DebugContext(ctx, "wgpolicyk8s.io CRDs not installed, skipping legacy report cleanup")
+		s.logger.DebugContext(ctx, "wgpolicyk8s.io CRDs not installed, skipping legacy report cleanup")
 	case err != nil:
 		return fmt.Errorf("failed to list legacy PolicyReports: %w", err)
 	case len(policyReportList.Items) > 0:
 		namespaceList := &corev1.NamespaceList{}
-		if err = c.List(ctx, namespaceList); err != nil {
+		if err = s.client.List(ctx, namespaceList); err != nil {
 			return fmt.Errorf("failed to list namespaces: %w", err)
 		}
 		for _, ns := range namespaceList.Items {
-			logger.InfoContext(ctx, "Deleting legacy wgpolicyk8s.io PolicyReports",
+			s.logger.InfoContext(ctx, "Deleting legacy wgpolicyk8s.io PolicyReports",
 				slog.String("namespace", ns.Name))
 			if err = store.DeleteOldReports(ctx, ephemeralRunUID, ns.Name); err != nil {
 				return err
 			}
 		}
+		legacyReportsFound = true
+	}
+
+	// the reports of the policies are not deleted with the reports of the
+	// resources
+	if legacyReportsFound {
+		s.logger.InfoContext(ctx, "Deleting legacy wgpolicyk8s.io reports of the policies")
+		if err = store.DeleteOldPolicyResultsReports(ctx, ephemeralRunUID); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// forEachLegacyReportPage lists the legacy reports matching the given
+// selector a page at a time, calling fn with each page.
+func forEachLegacyReportPage(ctx context.Context, c client.Client, list client.ObjectList, labelSelector labels.Selector, fn func(list client.ObjectList) error) error {
+	listOpts := &client.ListOptions{LabelSelector: labelSelector, Limit: migrationPageSize}
+	for {
+		if err := c.List(ctx, list, listOpts); err != nil {
+			return fmt.Errorf("failed to list legacy reports: %w", err)
+		}
+		if err := fn(list); err != nil {
+			return err
+		}
+		listOpts.Continue = list.GetContinue()
+		if listOpts.Continue == "" {
+			return nil
+		}
+	}
+}
+
+// listReportNames returns the namespaced names of all the OpenReports of the
+// given list kind. Only their metadata is listed, a page at a time.
+func listReportNames(ctx context.Context, c client.Client, listGVK schema.GroupVersionKind) (map[types.NamespacedName]struct{}, error) {
+	names := map[types.NamespacedName]struct{}{}
+	listOpts := &client.ListOptions{Limit: migrationPageSize}
+	for {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(listGVK)
+		if err := c.List(ctx, list, listOpts); err != nil {
+			return nil, fmt.Errorf("failed to list the %s: %w", listGVK.Kind, err)
+		}
+		for _, item := range list.Items {
+			names[types.NamespacedName{Name: item.Name, Namespace: item.Namespace}] = struct{}{}
+		}
+		listOpts.Continue = list.GetContinue()
+		if listOpts.Continue == "" {
+			return names, nil
+		}
+	}
+}
+
+// createMissingReport creates the given OpenReport, unless a report of the
+// same name already exists. It returns true when the report was created.
+func (s *OpenReportStore) createMissingReport(ctx context.Context, existing map[types.NamespacedName]struct{}, report client.Object) (bool, error) {
+	if _, found := existing[client.ObjectKeyFromObject(report)]; found {
+		return false, nil
+	}
+	return s.writer.create(ctx, report)
+}
+
+// legacyObjectMeta returns the metadata of the converted copy of a report.
+// The hash of the results is left out, it's computed from the fields of the
+// kind of the report.
+func legacyObjectMeta(objMeta *metav1.ObjectMeta) metav1.ObjectMeta {
+	annotations := maps.Clone(objMeta.Annotations)
+	delete(annotations, annotationResultsHash)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	return metav1.ObjectMeta{
+		Name:            objMeta.Name,
+		Namespace:       objMeta.Namespace,
+		Labels:          maps.Clone(objMeta.Labels),
+		Annotations:     annotations,
+		OwnerReferences: append([]metav1.OwnerReference(nil), objMeta.OwnerReferences...),
+	}
+}
+
+// toReport converts a wgpolicyk8s.io PolicyReport into an OpenReports Report.
+func toReport(policyReport *wgpolicy.PolicyReport) *openreports.Report {
+	return &openreports.Report{
+		ObjectMeta:    legacyObjectMeta(&policyReport.ObjectMeta),
+		Scope:         policyReport.Scope.DeepCopy(),
+		ScopeSelector: policyReport.ScopeSelector.DeepCopy(),
+		Summary:       openreports.ReportSummary(policyReport.Summary),
+		Results:       toReportResults(policyReport.Results),
+	}
+}
+
+// toClusterReport converts a wgpolicyk8s.io ClusterPolicyReport into an
+// OpenReports ClusterReport.
+func toClusterReport(clusterPolicyReport *wgpolicy.ClusterPolicyReport) *openreports.ClusterReport {
+	return &openreports.ClusterReport{
+		ObjectMeta:    legacyObjectMeta(&clusterPolicyReport.ObjectMeta),
+		Scope:         clusterPolicyReport.Scope.DeepCopy(),
+		ScopeSelector: clusterPolicyReport.ScopeSelector.DeepCopy(),
+		Summary:       openreports.ReportSummary(clusterPolicyReport.Summary),
+		Results:       toReportResults(clusterPolicyReport.Results),
+	}
+}
+
+// toPolicyReport converts an OpenReports Report into a wgpolicyk8s.io
+// PolicyReport.
+func toPolicyReport(report *openreports.Report) *wgpolicy.PolicyReport {
+	return &wgpolicy.PolicyReport{
+		ObjectMeta:    legacyObjectMeta(&report.ObjectMeta),
+		Scope:         report.Scope.DeepCopy(),
+		ScopeSelector: report.ScopeSelector.DeepCopy(),
+		Summary:       wgpolicy.PolicyReportSummary(report.Summary),
+		Results:       toPolicyReportResults(report.Results),
+	}
+}
+
+// toClusterPolicyReport converts an OpenReports ClusterReport into a
+// wgpolicyk8s.io ClusterPolicyReport.
+func toClusterPolicyReport(clusterReport *openreports.ClusterReport) *wgpolicy.ClusterPolicyReport {
+	return &wgpolicy.ClusterPolicyReport{
+		ObjectMeta:    legacyObjectMeta(&clusterReport.ObjectMeta),
+		Scope:         clusterReport.Scope.DeepCopy(),
+		ScopeSelector: clusterReport.ScopeSelector.DeepCopy(),
+		Summary:       wgpolicy.PolicyReportSummary(clusterReport.Summary),
+		Results:       toPolicyReportResults(clusterReport.Results),
+	}
+}
+
+func toReportResults(policyReportResults []*wgpolicy.PolicyReportResult) []openreports.ReportResult {
+	if policyReportResults == nil {
+		return nil
+	}
+	results := make([]openreports.ReportResult, 0, len(policyReportResults))
+	for _, policyReportResult := range policyReportResults {
+		if policyReportResult == nil {
+			continue
+		}
+		var subjects []corev1.ObjectReference
+		for _, subject := range policyReportResult.Subjects {
+			if subject != nil {
+				subjects = append(subjects, *subject)
+			}
+		}
+		results = append(results, openreports.ReportResult{
+			Source:           policyReportResult.Source,
+			Policy:           policyReportResult.Policy,
+			Rule:             policyReportResult.Rule,
+			Category:         policyReportResult.Category,
+			Severity:         openreports.ResultSeverity(policyReportResult.Severity),
+			Timestamp:        policyReportResult.Timestamp,
+			Result:           openreports.Result(policyReportResult.Result),
+			Scored:           policyReportResult.Scored,
+			Subjects:         subjects,
+			ResourceSelector: policyReportResult.SubjectSelector.DeepCopy(),
+			Description:      policyReportResult.Description,
+			Properties:       maps.Clone(policyReportResult.Properties),
+		})
+	}
+	return results
+}
+
+func toPolicyReportResults(reportResults []openreports.ReportResult) []*wgpolicy.PolicyReportResult {
+	if reportResults == nil {
+		return nil
+	}
+	results := make([]*wgpolicy.PolicyReportResult, 0, len(reportResults))
+	for i := range reportResults {
+		reportResult := &reportResults[i]
+		var subjects []*corev1.ObjectReference
+		for j := range reportResult.Subjects {
+			subjects = append(subjects, reportResult.Subjects[j].DeepCopy())
+		}
+		results = append(results, &wgpolicy.PolicyReportResult{
+			Source:          reportResult.Source,
+			Policy:          reportResult.Policy,
+			Rule:            reportResult.Rule,
+			Category:        reportResult.Category,
+			Severity:        wgpolicy.PolicyResultSeverity(reportResult.Severity),
+			Timestamp:       reportResult.Timestamp,
+			Result:          wgpolicy.PolicyResult(reportResult.Result),
+			Scored:          reportResult.Scored,
+			Subjects:        subjects,
+			SubjectSelector: reportResult.ResourceSelector.DeepCopy(),
+			Description:     reportResult.Description,
+			Properties:      maps.Clone(reportResult.Properties),
+		})
+	}
+	return results
+}
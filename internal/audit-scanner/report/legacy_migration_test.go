@@ -4,10 +4,13 @@ import (
 	"log/slog"
 	"testing"
 
+	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
 	testutils "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
 )
@@ -21,6 +24,9 @@ func TestDeleteAllLegacyPolicyReports(t *testing.T) {
 		Name("managed-default").Namespace("default").RunUID("old-uid").WithAppLabel().Build()
 	managedOther := testutils.NewPolicyReportFactory().
 		Name("managed-other").Namespace("other").RunUID("old-uid").WithAppLabel().Build()
+	// kubewarden-managed report of a policy should be deleted too
+	managedPolicy := testutils.NewPolicyReportFactory().
+		Name("managed-policy").Namespace("other").RunUID("old-uid").WithAppLabel().WithPolicyResultsLabel().Build()
 	// report without the managed-by label should be preserved
 	unmanagedDefault := testutils.NewPolicyReportFactory().
 		Name("unmanaged-default").Namespace("default").RunUID("old-uid").Build()
@@ -28,18 +34,20 @@ func TestDeleteAllLegacyPolicyReports(t *testing.T) {
 	// kubewarden-managed cluster report should be deleted
 	managedCluster := testutils.NewClusterPolicyReportFactory().
 		Name("managed-cluster").WithAppLabel().RunUID("old-uid").Build()
+	managedClusterPolicy := testutils.NewClusterPolicyReportFactory().
+		Name("managed-cluster-policy").WithAppLabel().WithPolicyResultsLabel().RunUID("old-uid").Build()
 	// cluster report without the managed-by label should be preserved
 	unmanagedCluster := testutils.NewClusterPolicyReportFactory().
 		Name("unmanaged-cluster").RunUID("old-uid").Build()
 
 	fakeClient, err := testutils.NewFakeClient(
 		nsDefault, nsOther,
-		managedDefault, managedOther, unmanagedDefault,
-		managedCluster, unmanagedCluster,
+		managedDefault, managedOther, managedPolicy, unmanagedDefault,
+		managedCluster, managedClusterPolicy, unmanagedCluster,
 	)
 	require.NoError(t, err)
 
-	err = DeleteAllLegacyPolicyReports(t.Context(), fakeClient, slog.Default())
+	err = NewOpenReportStore(fakeClient, WriterConfig{}, slog.Default()).DeleteAllLegacyPolicyReports(t.Context())
 	require.NoError(t, err)
 
 	// all namespaced kubewarden-managed reports are gone
@@ -56,3 +64,76 @@ func TestDeleteAllLegacyPolicyReports(t *testing.T) {
 	require.Len(t, clusterReportList.Items, 1)
 	require.Equal(t, "unmanaged-cluster", clusterReportList.Items[0].Name)
 }
+
+func TestMigrateLegacyPolicyReports(t *testing.T) {
+	nsDefault := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+
+	legacyReport := testutils.NewPolicyReportFactory().
+		Name("pod-uid").Namespace("default").RunUID("old-uid").WithAppLabel().Build()
+	legacyReport.Scope = &corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: "pod", Namespace: "default", UID: "pod-uid"}
+	legacyReport.Summary = wgpolicy.PolicyReportSummary{Fail: 1}
+	legacyReport.Results = []*wgpolicy.PolicyReportResult{{
+		Source:          policyReportSource,
+		Policy:          "namespaced-default-policy",
+		Category:        "Resource validation",
+		Severity:        severityInfo,
+		Timestamp:       metav1.Timestamp{Seconds: 1},
+		Result:          statusFail,
+		Scored:          true,
+		Subjects:        []*corev1.ObjectReference{{Kind: "Pod", Name: "pod"}},
+		SubjectSelector: &metav1.LabelSelector{},
+		Description:     "rejected",
+		Properties:      map[string]string{propertyPolicyUID: "policy-uid"},
+	}}
+	legacyClusterReport := testutils.NewClusterPolicyReportFactory().
+		Name("namespace-uid").WithAppLabel().RunUID("old-uid").Build()
+	// a report written by a scan more recent than the legacy report is kept
+	legacyNewerReport := testutils.NewPolicyReportFactory().
+		Name("newer-uid").Namespace("default").RunUID("old-uid").WithAppLabel().Build()
+	newerReport := testutils.NewPolicyReportFactory().
+		Name("newer-uid").Namespace("default").RunUID("new-uid").WithAppLabel().BuildOpenReports()
+
+	fakeClient, err := testutils.NewFakeClient(nsDefault, legacyReport, legacyClusterReport, legacyNewerReport, newerReport)
+	require.NoError(t, err)
+
+	err = NewOpenReportStore(fakeClient, WriterConfig{}, slog.Default()).MigrateLegacyPolicyReports(t.Context())
+	require.NoError(t, err)
+
+	report := &openreports.Report{}
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "pod-uid", Namespace: "default"}, report)
+	require.NoError(t, err)
+	require.Equal(t, legacyReport.Labels, report.Labels)
+	require.Equal(t, legacyReport.Scope, report.Scope)
+	require.Equal(t, openreports.ReportSummary{Fail: 1}, report.Summary)
+	require.Equal(t, []openreports.ReportResult{{
+		Source:           policyReportSource,
+		Policy:           "namespaced-default-policy",
+		Category:         "Resource validation",
+		Severity:         severityInfo,
+		Timestamp:        metav1.Timestamp{Seconds: 1},
+		Result:           statusFail,
+		Scored:           true,
+		Subjects:         []corev1.ObjectReference{{Kind: "Pod", Name: "pod"}},
+		ResourceSelector: &metav1.LabelSelector{},
+		Description:      "rejected",
+		Properties:       map[string]string{propertyPolicyUID: "policy-uid"},
+	}}, report.Results)
+
+	clusterReport := &openreports.ClusterReport{}
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "namespace-uid"}, clusterReport)
+	require.NoError(t, err)
+
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "newer-uid", Namespace: "default"}, report)
+	require.NoError(t, err)
+	require.Equal(t, "new-uid", report.Labels[auditConstants.AuditScannerRunUIDLabel])
+
+	// the legacy reports are deleted once converted
+	policyReportList := &wgpolicy.PolicyReportList{}
+	err = fakeClient.List(t.Context(), policyReportList)
+	require.NoError(t, err)
+	require.Empty(t, policyReportList.Items)
+	clusterReportList := &wgpolicy.ClusterPolicyReportList{}
+	err = fakeClient.List(t.Context(), clusterReportList)
+	require.NoError(t, err)
+	require.Empty(t, clusterReportList.Items)
+}
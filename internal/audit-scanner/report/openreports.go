@@ -4,6 +4,7 @@ import (
 	"time"
 
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
 	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -43,22 +44,40 @@ func (r *OpenReport) AddResult(
 	errored bool,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newReportResult(policy, admissionReview, errored, now)
-	switch result.Result {
-	case statusFail:
-		r.report.Summary.Fail++
-	case statusError:
-		r.report.Summary.Error++
-	case statusPass:
-		r.report.Summary.Pass++
+	r.results().add(newReportResult(policy, admissionReview, errored, now))
+}
+
+func (r *OpenReport) ReuseResult(previous Report, policy policiesv1.Policy, operation admissionv1.Operation) bool {
+	previousReport, ok := previous.(*OpenReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	return r.results().reuse(previousReport.results(), policy, operation)
+}
+
+func (r *OpenReport) KeepResults(previous Report, replacedPolicies []string) {
+	if previousReport, ok := previous.(*OpenReport); ok && previousReport != nil {
+		r.results().keep(previousReport.results(), replacedPolicies)
+	}
+}
+
+func (r *OpenReport) results() resultSet[openreports.ReportResult] {
+	return resultSet[openreports.ReportResult]{
+		scope:   r.report.Scope,
+		results: &r.report.Results,
+		summary: &r.report.Summary,
+		fields:  reportResultFields,
 	}
-	r.report.Results = append(r.report.Results, result)
 }
 
 func (r *OpenReport) SetSkipPolicies(skippedPoliciesNumber int) {
 	r.report.Summary.Skip = skippedPoliciesNumber
 }
 
+func (r *OpenReport) Entries() []ResultEntry {
+	return openReportEntries(r.report.Labels[constants.AuditScannerRunUIDLabel], r.report.Scope, r.report.Results)
+}
+
 func (r *OpenReport) SetErrorPolicies(erroredPoliciesNumber int) {
 	r.report.Summary.Error = erroredPoliciesNumber
 }
@@ -69,22 +88,40 @@ func (r *OpenClusterReport) AddResult(
 	errored bool,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newReportResult(policy, admissionReview, errored, now)
-	switch result.Result {
-	case statusFail:
-		r.report.Summary.Fail++
-	case statusError:
-		r.report.Summary.Error++
-	case statusPass:
-		r.report.Summary.Pass++
+	r.results().add(newReportResult(policy, admissionReview, errored, now))
+}
+
+func (r *OpenClusterReport) ReuseResult(previous Report, policy policiesv1.Policy, operation admissionv1.Operation) bool {
+	previousReport, ok := previous.(*OpenClusterReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	return r.results().reuse(previousReport.results(), policy, operation)
+}
+
+func (r *OpenClusterReport) KeepResults(previous Report, replacedPolicies []string) {
+	if previousReport, ok := previous.(*OpenClusterReport); ok && previousReport != nil {
+		r.results().keep(previousReport.results(), replacedPolicies)
+	}
+}
+
+func (r *OpenClusterReport) results() resultSet[openreports.ReportResult] {
+	return resultSet[openreports.ReportResult]{
+		scope:   r.report.Scope,
+		results: &r.report.Results,
+		summary: &r.report.Summary,
+		fields:  reportResultFields,
 	}
-	r.report.Results = append(r.report.Results, result)
 }
 
 func (r *OpenClusterReport) SetSkipPolicies(skippedPoliciesNumber int) {
 	r.report.Summary.Skip = skippedPoliciesNumber
 }
 
+func (r *OpenClusterReport) Entries() []ResultEntry {
+	return openReportEntries(r.report.Labels[constants.AuditScannerRunUIDLabel], r.report.Scope, r.report.Results)
+}
+
 func (r *OpenClusterReport) SetErrorPolicies(erroredPoliciesNumber int) {
 	r.report.Summary.Error = erroredPoliciesNumber
 }
@@ -120,6 +157,18 @@ func newReportResult(policy policiesv1.Policy, admissionReview *admissionv1.Admi
 		ResourceSelector: &metav1.LabelSelector{},
 		// This field is marshalled to `message`
 		Description: message,
-		Properties:  computeProperties(policy),
+		Properties:  computeProperties(policy, admissionReview),
 	}
 }
+
+// reportResultFields reads and copies the results of the OpenReports.
+var reportResultFields = resultFields[openreports.ReportResult]{
+	policy:     func(result openreports.ReportResult) string { return result.Policy },
+	status:     func(result openreports.ReportResult) string { return string(result.Result) },
+	properties: func(result openreports.ReportResult) map[string]string { return result.Properties },
+	deepCopy:   func(result openreports.ReportResult) openreports.ReportResult { return *result.DeepCopy() },
+	setTimestamp: func(result openreports.ReportResult, timestamp metav1.Timestamp) openreports.ReportResult {
+		result.Timestamp = timestamp
+		return result
+	},
+}
@@ -7,10 +7,11 @@ import (
 
 	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
 	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 // OpenReportStore is a store for OpenReports Report and ClusterReport.
@@ -19,14 +20,38 @@ type OpenReportStore struct {
 	client client.Client
 	// logger is used to log the messages
 	logger *slog.Logger
+	// writer applies the reports and deletes the old ones
+	writer *reportWriter
 }
 
-// NewOpenReportStore creates a new PolicyReportStore.
-func NewOpenReportStore(client client.Client, logger *slog.Logger) Store {
+// NewOpenReportStore creates a new OpenReportStore writing the reports as
+// configured.
+func NewOpenReportStore(client client.Client, writerConfig WriterConfig, logger *slog.Logger) *OpenReportStore {
+	return newOpenReportStore(client, newReportWriter(client, writerConfig), logger)
+}
+
+// newOpenReportStore creates a new OpenReportStore writing the reports
+// through the given writer, which may be shared with other stores.
+func newOpenReportStore(client client.Client, writer *reportWriter, logger *slog.Logger) *OpenReportStore {
 	return &OpenReportStore{
 		client: client,
 		logger: logger.With("component", "policyreportstore"),
+		writer: writer,
+	}
+}
+
+// GetReport returns the OpenReports Report of the given resource.
+func (s *OpenReportStore) GetReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	report := &openreports.Report{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: string(resource.GetUID()), Namespace: resource.GetNamespace()}, report)
+	if apierrors.IsNotFound(err) {
+		return nil, auditConstants.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OpenReports Report %s: %w", resource.GetUID(), err)
 	}
+
+	return &OpenReport{report: report}, nil
 }
 
 // CreateOrPatchReport creates or patches a OpenReports Report.
@@ -36,51 +61,49 @@ func (s *OpenReportStore) CreateOrPatchReport(ctx context.Context, obj any) erro
 		return fmt.Errorf("expected *OpenReport, got %T", obj)
 	}
 	policyReport := openReport.report
-	oldPolicyReport := &openreports.Report{ObjectMeta: metav1.ObjectMeta{
-		Name:      policyReport.GetName(),
-		Namespace: policyReport.GetNamespace(),
-	}}
-
-	operation, err := controllerutil.CreateOrPatch(ctx, s.client, oldPolicyReport, func() error {
-		oldPolicyReport.ObjectMeta.Labels = policyReport.ObjectMeta.Labels
-		oldPolicyReport.ObjectMeta.OwnerReferences = policyReport.ObjectMeta.OwnerReferences
-		oldPolicyReport.Scope = policyReport.Scope
-		oldPolicyReport.Summary = policyReport.Summary
-		oldPolicyReport.Results = policyReport.Results
-
-		return nil
-	})
+	operation, err := s.writer.apply(ctx, policyReport)
 	if err != nil {
-		return fmt.Errorf("failed to create or patch policy report %s: %w", policyReport.GetName(), err)
+		return fmt.Errorf("failed to apply policy report %s: %w", policyReport.GetName(), err)
 	}
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("PolicyReport %s", operation),
 		slog.String("report-name", policyReport.GetName()),
 		slog.String("report-version", policyReport.GetResourceVersion()),
-		slog.String("resource-name", policyReport.Scope.Name),
-		slog.String("resource-namespace", policyReport.Scope.Namespace),
-		slog.String("resource-version", policyReport.Scope.ResourceVersion))
+		slog.String("resource-name", scopeOrEmpty(policyReport.Scope).Name),
+		slog.String("resource-namespace", scopeOrEmpty(policyReport.Scope).Namespace),
+		slog.String("resource-version", scopeOrEmpty(policyReport.Scope).ResourceVersion))
 
 	return nil
 }
 
 // DeleteOldReports deletes all the OpenReports Reports that do not belong to the current scan run.
 func (s *OpenReportStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
-	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s,%s!=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp, LabelReportType, ReportTypePolicy))
 	if err != nil {
 		return fmt.Errorf("failed to parse label selector: %w", err)
 	}
 	s.logger.DebugContext(ctx, "Deleting old PolicyReports", slog.String("labelSelector", labelSelector.String()))
 
-	if deleteErr := s.client.DeleteAllOf(ctx, &openreports.Report{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
-		LabelSelector: labelSelector,
-		Namespace:     namespace,
-	}}); deleteErr != nil {
+	if deleteErr := s.writer.deleteOld(ctx, &openreports.Report{}, namespace, labelSelector); deleteErr != nil {
 		return fmt.Errorf("failed to delete PolicyReports: %w", deleteErr)
 	}
 	return nil
 }
 
+// GetClusterReport returns the OpenReports ClusterReport of the given resource.
+func (s *OpenReportStore) GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	report := &openreports.ClusterReport{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: string(resource.GetUID())}, report)
+	if apierrors.IsNotFound(err) {
+		return nil, auditConstants.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OpenReports ClusterReport %s: %w", resource.GetUID(), err)
+	}
+
+	return &OpenClusterReport{report: report}, nil
+}
+
 // CreateOrPatchClusterReport creates or patches a OpenReports ClusterReport.
 //
 //nolint:dupl // Temporary duplicated code with policyreports_store.go, it's planned to be the only implementation in the future.
@@ -90,45 +113,50 @@ func (s *OpenReportStore) CreateOrPatchClusterReport(ctx context.Context, obj an
 		return fmt.Errorf("expected *OpenReport, got %T", obj)
 	}
 	clusterPolicyReport := openReport.report
-	oldClusterPolicyReport := &openreports.ClusterReport{ObjectMeta: metav1.ObjectMeta{
-		Name: clusterPolicyReport.GetName(),
-	}}
-
-	operation, err := controllerutil.CreateOrPatch(ctx, s.client, oldClusterPolicyReport, func() error {
-		oldClusterPolicyReport.ObjectMeta.Labels = clusterPolicyReport.ObjectMeta.Labels
-		oldClusterPolicyReport.ObjectMeta.OwnerReferences = clusterPolicyReport.ObjectMeta.OwnerReferences
-		oldClusterPolicyReport.Scope = clusterPolicyReport.Scope
-		oldClusterPolicyReport.Summary = clusterPolicyReport.Summary
-		oldClusterPolicyReport.Results = clusterPolicyReport.Results
-
-		return nil
-	})
+	operation, err := s.writer.apply(ctx, clusterPolicyReport)
 	if err != nil {
-		return fmt.Errorf("failed to create or patch cluster policy report %s: %w", clusterPolicyReport.GetName(), err)
+		return fmt.Errorf("failed to apply cluster policy report %s: %w", clusterPolicyReport.GetName(), err)
 	}
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("ClusterPolicyReport %s", operation),
 		slog.String("report-name", clusterPolicyReport.GetName()),
 		slog.String("report-version", clusterPolicyReport.GetResourceVersion()),
-		slog.String("resource-name", clusterPolicyReport.Scope.Name),
-		slog.String("resource-namespace", clusterPolicyReport.Scope.Namespace),
-		slog.String("resource-version", clusterPolicyReport.Scope.ResourceVersion))
+		slog.String("resource-name", scopeOrEmpty(clusterPolicyReport.Scope).Name),
+		slog.String("resource-namespace", scopeOrEmpty(clusterPolicyReport.Scope).Namespace),
+		slog.String("resource-version", scopeOrEmpty(clusterPolicyReport.Scope).ResourceVersion))
 
 	return nil
 }
 
 // DeleteOldClusterReports deletes all the OpenReports ClusterReports that do not belong to the current scan run.
 func (s *OpenReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
-	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s,%s!=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp, LabelReportType, ReportTypePolicy))
 	if err != nil {
 		return fmt.Errorf("failed to parse label selector: %w", err)
 	}
 	s.logger.DebugContext(ctx, "Deleting old ClusterPolicyReports", slog.String("labelSelector", labelSelector.String()))
 
-	if deleteErr := s.client.DeleteAllOf(ctx, &openreports.ClusterReport{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
-		LabelSelector: labelSelector,
-	}}); deleteErr != nil {
+	if deleteErr := s.writer.deleteOld(ctx, &openreports.ClusterReport{}, "", labelSelector); deleteErr != nil {
 		return fmt.Errorf("failed to delete ClusterPolicyReports: %w", deleteErr)
 	}
 	return nil
 }
+
+// DeleteOldPolicyResultsReports deletes the OpenReports ClusterReports and the OpenReports Reports of
+// the policies that do not belong to the current scan run. The OpenReports Reports are
+// deleted in the namespaces where they are found.
+func (s *OpenReportStore) DeleteOldPolicyResultsReports(ctx context.Context, scanRunID string) error {
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp, LabelReportType, ReportTypePolicy))
+	if err != nil {
+		return fmt.Errorf("failed to parse label selector: %w", err)
+	}
+	s.logger.DebugContext(ctx, "Deleting old reports of the policies", slog.String("labelSelector", labelSelector.String()))
+
+	if deleteErr := s.writer.deleteOld(ctx, &openreports.ClusterReport{}, "", labelSelector); deleteErr != nil {
+		return fmt.Errorf("failed to delete the OpenReports ClusterReports of the policies: %w", deleteErr)
+	}
+	if deleteErr := s.writer.deleteOldInAllNamespaces(ctx, &openreports.Report{}, labelSelector); deleteErr != nil {
+		return fmt.Errorf("failed to delete the OpenReports Reports of the policies: %w", deleteErr)
+	}
+	return nil
+}
@@ -23,7 +23,7 @@ func TestCreateReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewOpenReportStore(fakeClient, logger)
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, logger)
 
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -52,7 +52,7 @@ func TestPatchReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewOpenReportStore(fakeClient, logger)
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, logger)
 
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -103,7 +103,7 @@ func TestCreateClusterReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewOpenReportStore(fakeClient, logger)
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, logger)
 
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -131,7 +131,7 @@ func TestPatchClusterReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewOpenReportStore(fakeClient, logger)
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, logger)
 
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -189,7 +189,7 @@ func TestDeleteReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient(oldPolicyReport, otherOldPolicyReport, newPolicyReport, oldPolicyReportOtheNamespace)
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewOpenReportStore(fakeClient, logger)
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, logger)
 
 	err = store.DeleteOldReports(t.Context(), "new-uid", "default")
 	require.NoError(t, err)
@@ -224,7 +224,7 @@ func TestDeleteClusterReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient(oldPolicyReport, otherOldPolicyReport, newPolicyReport)
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewOpenReportStore(fakeClient, logger)
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, logger)
 
 	err = store.DeleteOldClusterReports(t.Context(), "new-uid")
 	require.NoError(t, err)
@@ -246,3 +246,58 @@ func TestDeleteClusterReport(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, storedPolicyReportList.Items, 1)
 }
+
+func TestGetReport(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	logger := slog.Default()
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, logger)
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-pod")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetResourceVersion("12345")
+
+	_, err = store.GetReport(t.Context(), resource)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	openReport := NewOpenReport("runUID", resource)
+	err = store.CreateOrPatchReport(t.Context(), openReport)
+	require.NoError(t, err)
+
+	storedReport, err := store.GetReport(t.Context(), resource)
+	require.NoError(t, err)
+	storedOpenReport, ok := storedReport.(*OpenReport)
+	require.True(t, ok)
+	require.Equal(t, openReport.report.Scope, storedOpenReport.report.Scope)
+}
+
+func TestGetClusterReport(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	logger := slog.Default()
+	store := NewOpenReportStore(fakeClient, WriterConfig{}, logger)
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Namespace")
+	resource.SetResourceVersion("12345")
+
+	_, err = store.GetClusterReport(t.Context(), resource)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	clusterReport := NewClusterOpenReport("runUID", resource)
+	err = store.CreateOrPatchClusterReport(t.Context(), clusterReport)
+	require.NoError(t, err)
+
+	storedReport, err := store.GetClusterReport(t.Context(), resource)
+	require.NoError(t, err)
+	storedClusterReport, ok := storedReport.(*OpenClusterReport)
+	require.True(t, ok)
+	require.Equal(t, clusterReport.report.Scope, storedClusterReport.report.Scope)
+}
@@ -0,0 +1,61 @@
+package report
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// maxPatchPropertySize is the maximum size of the patch stored in the result
+// properties. The reports are stored in etcd, they must stay small.
+const maxPatchPropertySize = 1024
+
+// patchOperation is an operation of the JSONPatch returned by a mutating policy.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// computePatchProperties returns the properties of the result of a mutating
+// policy that would apply the given JSONPatch to the resource. The patch
+// operations are stored as a JSON array, truncated to maxPatchPropertySize:
+// the operations that don't fit are left out.
+func computePatchProperties(patch []byte) map[string]string {
+	properties := map[string]string{
+		propertyWouldMutate: valueTypeTrue,
+	}
+
+	var operations []patchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		// the patch cannot be decoded, it's not stored
+		return properties
+	}
+	properties[propertyPatchOperations] = strconv.Itoa(len(operations))
+
+	encoded := make([]json.RawMessage, 0, len(operations))
+	// the size of the brackets of the array
+	size := 2
+	for _, operation := range operations {
+		encodedOperation, err := json.Marshal(operation)
+		if err != nil {
+			break
+		}
+		// the size of the operation and of its separator
+		if size+len(encodedOperation)+len(encoded) > maxPatchPropertySize {
+			break
+		}
+		size += len(encodedOperation)
+		encoded = append(encoded, encodedOperation)
+	}
+	if len(encoded) < len(operations) {
+		properties[propertyPatchTruncated] = valueTypeTrue
+	}
+
+	encodedPatch, err := json.Marshal(encoded)
+	if err != nil {
+		return properties
+	}
+	properties[propertyPatch] = string(encodedPatch)
+	return properties
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestComputePatchProperties(t *testing.T) {
+	largeValue := strings.Repeat("a", maxPatchPropertySize/2)
+	tests := []struct {
+		name               string
+		patch              string
+		expectedOperations string
+		expectedPatch      string
+		expectedTruncated  bool
+	}{
+		{
+			name:               "patch",
+			patch:              `[{"op":"add","path":"/metadata/labels/owner","value":"team-a"},{"op":"remove","path":"/spec/hostNetwork"}]`,
+			expectedOperations: "2",
+			expectedPatch:      `[{"op":"add","path":"/metadata/labels/owner","value":"team-a"},{"op":"remove","path":"/spec/hostNetwork"}]`,
+		},
+		{
+			name:               "truncated patch",
+			patch:              fmt.Sprintf(`[{"op":"add","path":"/a","value":"%s"},{"op":"add","path":"/b","value":"%s"}]`, largeValue, largeValue),
+			expectedOperations: "2",
+			expectedPatch:      fmt.Sprintf(`[{"op":"add","path":"/a","value":"%s"}]`, largeValue),
+			expectedTruncated:  true,
+		},
+		{
+			name:  "invalid patch",
+			patch: `not a patch`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			properties := computePatchProperties([]byte(test.patch))
+
+			assert.Equal(t, "true", properties["would-mutate"])
+			assert.Equal(t, test.expectedOperations, properties["patch-operations"])
+			assert.Equal(t, test.expectedPatch, properties["patch"])
+			assert.LessOrEqual(t, len(properties["patch"]), maxPatchPropertySize)
+			if test.expectedTruncated {
+				assert.Equal(t, "true", properties["patch-truncated"])
+			} else {
+				assert.NotContains(t, properties, "patch-truncated")
+			}
+		})
+	}
+}
+
+func TestAddMutatingResult(t *testing.T) {
+	patch := []byte(`[{"op":"add","path":"/metadata/labels/owner","value":"team-a"}]`)
+	policy := &policiesv1.ClusterAdmissionPolicy{
+		Spec: policiesv1.ClusterAdmissionPolicySpec{
+			PolicySpec: policiesv1.PolicySpec{Mutating: true},
+		},
+	}
+	mutatingReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: true,
+			Patch:   patch,
+		},
+	}
+	allowedReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: true,
+		},
+	}
+
+	reports := map[string]Report{
+		"PolicyReport": NewPolicyReport("runUID", unstructured.Unstructured{}),
+		"OpenReport":   NewOpenReport("runUID", unstructured.Unstructured{}),
+	}
+	for name, report := range reports {
+		t.Run(name, func(t *testing.T) {
+			report.AddResult(policy, mutatingReview, false)
+			report.AddResult(policy, allowedReview, false)
+
+			entries := report.Entries()
+			require.Len(t, entries, 2)
+
+			// the resource drifted from what the policy enforces
+			assert.Equal(t, ResultPass, entries[0].Result)
+			assert.Equal(t, "true", entries[0].Properties["would-mutate"])
+			assert.JSONEq(t, string(patch), entries[0].Properties["patch"])
+			var operations []map[string]any
+			require.NoError(t, json.Unmarshal([]byte(entries[0].Properties["patch"]), &operations))
+			assert.Equal(t, "add", operations[0]["op"])
+
+			// the resource complies with what the policy enforces
+			assert.NotContains(t, entries[1].Properties, "would-mutate")
+			assert.NotContains(t, entries[1].Properties, "patch")
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// LabelReportType tells the reports of the policies apart from the
+	// reports of the resources
+	LabelReportType = "kubewarden.io/report-type"
+	// ReportTypePolicy is the type of the reports of the policies
+	ReportTypePolicy = "policy"
+	// LabelPolicyUID is the UID of the policy of a report of a policy
+	LabelPolicyUID            = "kubewarden.io/policy-uid"
+	policyResultsReportPrefix = "kubewarden-policy-"
+)
+
+// NewPolicyResultsReport returns the report aggregating the results of the
+// given policy, keyed by resource: the policy-centric view of a scan run. The
+// results of a cluster-wide policy are stored in cluster reports, the ones of
+// a namespaced policy in reports of the namespace of the policy. The parts of
+// the report are named after the unique name of the policy, followed by the
+// given suffix.
+func NewPolicyResultsReport(store Store, kind CrdKind, runUID string, policy policiesv1.Policy, nameSuffix string) *AggregatedReport {
+	policyResultsReport := NewAggregatedReport(store, kind, runUID, policy.GetNamespace(), policyResultsReportPrefix+policy.GetUniqueName()+nameSuffix)
+	policyResultsReport.labels = map[string]string{
+		LabelReportType: ReportTypePolicy,
+		LabelPolicyUID:  string(policy.GetUID()),
+	}
+	return policyResultsReport
+}
+
+// AddPolicyResult adds the result of the evaluation of the policy against
+// the given resource.
+func (r *AggregatedReport) AddPolicyResult(policy policiesv1.Policy, resource unstructured.Unstructured, admissionReview *admissionv1.AdmissionReview, errored bool) error {
+	var resourceReport Report
+	if r.namespace == "" {
+		resourceReport = NewClusterReportOfKind(r.kind, r.runUID, resource)
+	} else {
+		resourceReport = NewReportOfKind(r.kind, r.runUID, resource)
+	}
+	resourceReport.AddResult(policy, admissionReview, errored)
+	return r.Add(resourceReport)
+}
+
+// IsPolicyResultsReport returns true when the given report is the report of a
+// policy, listing the resources failing it, rather than the report of a
+// resource.
+func IsPolicyResultsReport(resourceReport Report) bool {
+	var labels map[string]string
+	switch typedReport := resourceReport.(type) {
+	case *OpenReport:
+		labels = typedReport.report.Labels
+	case *OpenClusterReport:
+		labels = typedReport.report.Labels
+	case *PolicyReport:
+		labels = typedReport.report.Labels
+	case *ClusterPolicyReport:
+		labels = typedReport.report.Labels
+	}
+	return labels[LabelReportType] == ReportTypePolicy
+}
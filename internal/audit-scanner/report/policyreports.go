@@ -4,6 +4,8 @@ import (
 	"time"
 
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -59,22 +61,41 @@ func (r *PolicyReport) AddResult(
 	errored bool,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newPolicyReportResult(policy, admissionReview, errored, now)
-	switch result.Result {
-	case statusFail:
-		r.report.Summary.Fail++
-	case statusError:
-		r.report.Summary.Error++
-	case statusPass:
-		r.report.Summary.Pass++
+	r.results().add(newPolicyReportResult(policy, admissionReview, errored, now))
+}
+
+func (r *PolicyReport) ReuseResult(previous Report, policy policiesv1.Policy, operation admissionv1.Operation) bool {
+	previousReport, ok := previous.(*PolicyReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	return r.results().reuse(previousReport.results(), policy, operation)
+}
+
+func (r *PolicyReport) KeepResults(previous Report, replacedPolicies []string) {
+	if previousReport, ok := previous.(*PolicyReport); ok && previousReport != nil {
+		r.results().keep(previousReport.results(), replacedPolicies)
+	}
+}
+
+func (r *PolicyReport) results() resultSet[*wgpolicy.PolicyReportResult] {
+	return resultSet[*wgpolicy.PolicyReportResult]{
+		scope:   r.report.Scope,
+		results: &r.report.Results,
+		// the summaries of both kinds of reports have the same fields
+		summary: (*openreports.ReportSummary)(&r.report.Summary),
+		fields:  policyReportResultFields,
 	}
-	r.report.Results = append(r.report.Results, result)
 }
 
 func (r *PolicyReport) SetSkipPolicies(skippedPoliciesNumber int) {
 	r.report.Summary.Skip = skippedPoliciesNumber
 }
 
+func (r *PolicyReport) Entries() []ResultEntry {
+	return policyReportEntries(r.report.Labels[constants.AuditScannerRunUIDLabel], r.report.Scope, r.report.Results)
+}
+
 func (r *PolicyReport) SetErrorPolicies(erroredPoliciesNumber int) {
 	r.report.Summary.Error = erroredPoliciesNumber
 }
@@ -104,22 +125,41 @@ func (r *ClusterPolicyReport) AddResult(
 	errored bool,
 ) {
 	now := metav1.Timestamp{Seconds: time.Now().Unix()}
-	result := newPolicyReportResult(policy, admissionReview, errored, now)
-	switch result.Result {
-	case statusFail:
-		r.report.Summary.Fail++
-	case statusError:
-		r.report.Summary.Error++
-	case statusPass:
-		r.report.Summary.Pass++
+	r.results().add(newPolicyReportResult(policy, admissionReview, errored, now))
+}
+
+func (r *ClusterPolicyReport) ReuseResult(previous Report, policy policiesv1.Policy, operation admissionv1.Operation) bool {
+	previousReport, ok := previous.(*ClusterPolicyReport)
+	if !ok || previousReport == nil {
+		return false
+	}
+	return r.results().reuse(previousReport.results(), policy, operation)
+}
+
+func (r *ClusterPolicyReport) KeepResults(previous Report, replacedPolicies []string) {
+	if previousReport, ok := previous.(*ClusterPolicyReport); ok && previousReport != nil {
+		r.results().keep(previousReport.results(), replacedPolicies)
+	}
+}
+
+func (r *ClusterPolicyReport) results() resultSet[*wgpolicy.PolicyReportResult] {
+	return resultSet[*wgpolicy.PolicyReportResult]{
+		scope:   r.report.Scope,
+		results: &r.report.Results,
+		// the summaries of both kinds of reports have the same fields
+		summary: (*openreports.ReportSummary)(&r.report.Summary),
+		fields:  policyReportResultFields,
 	}
-	r.report.Results = append(r.report.Results, result)
 }
 
 func (r *ClusterPolicyReport) SetSkipPolicies(skippedPoliciesNumber int) {
 	r.report.Summary.Skip = skippedPoliciesNumber
 }
 
+func (r *ClusterPolicyReport) Entries() []ResultEntry {
+	return policyReportEntries(r.report.Labels[constants.AuditScannerRunUIDLabel], r.report.Scope, r.report.Results)
+}
+
 func (r *ClusterPolicyReport) SetErrorPolicies(erroredPoliciesNumber int) {
 	r.report.Summary.Error = erroredPoliciesNumber
 }
@@ -138,6 +178,23 @@ func newPolicyReportResult(policy policiesv1.Policy, admissionReview *admissionv
 		SubjectSelector: &metav1.LabelSelector{},
 		// This field is marshalled to `message`
 		Description: message,
-		Properties:  computeProperties(policy),
+		Properties:  computeProperties(policy, admissionReview),
 	}
 }
+
+// policyReportResultFields reads and copies the results of the PolicyReports.
+var policyReportResultFields = resultFields[*wgpolicy.PolicyReportResult]{
+	policy: func(result *wgpolicy.PolicyReportResult) string {
+		if result == nil {
+			return ""
+		}
+		return result.Policy
+	},
+	status:     func(result *wgpolicy.PolicyReportResult) string { return string(result.Result) },
+	properties: func(result *wgpolicy.PolicyReportResult) map[string]string { return result.Properties },
+	deepCopy:   func(result *wgpolicy.PolicyReportResult) *wgpolicy.PolicyReportResult { return result.DeepCopy() },
+	setTimestamp: func(result *wgpolicy.PolicyReportResult, timestamp metav1.Timestamp) *wgpolicy.PolicyReportResult {
+		result.Timestamp = timestamp
+		return result
+	},
+}
@@ -6,10 +6,11 @@ import (
 	"log/slog"
 
 	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
 )
 
@@ -21,14 +22,38 @@ type PolicyReportStore struct {
 	client client.Client
 	// logger is used to log the messages
 	logger *slog.Logger
+	// writer applies the reports and deletes the old ones
+	writer *reportWriter
 }
 
-// NewPolicyReportStore creates a new PolicyReportStore.
-func NewPolicyReportStore(client client.Client, logger *slog.Logger) *PolicyReportStore {
+// NewPolicyReportStore creates a new PolicyReportStore writing the reports as
+// configured.
+func NewPolicyReportStore(client client.Client, writerConfig WriterConfig, logger *slog.Logger) *PolicyReportStore {
+	return newPolicyReportStore(client, newReportWriter(client, writerConfig), logger)
+}
+
+// newPolicyReportStore creates a new PolicyReportStore writing the reports
+// through the given writer, which may be shared with other stores.
+func newPolicyReportStore(client client.Client, writer *reportWriter, logger *slog.Logger) *PolicyReportStore {
 	return &PolicyReportStore{
 		client: client,
 		logger: logger.With("component", "policyreportstore"),
+		writer: writer,
+	}
+}
+
+// GetReport returns the PolicyReport of the given resource.
+func (s *PolicyReportStore) GetReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	report := &wgpolicy.PolicyReport{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: string(resource.GetUID()), Namespace: resource.GetNamespace()}, report)
+	if apierrors.IsNotFound(err) {
+		return nil, auditConstants.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PolicyReport %s: %w", resource.GetUID(), err)
 	}
+
+	return &PolicyReport{report: report}, nil
 }
 
 // CreateOrPatchReport creates or patches a PolicyReport.
@@ -38,51 +63,49 @@ func (s *PolicyReportStore) CreateOrPatchReport(ctx context.Context, obj any) er
 		return fmt.Errorf("expected *PolicyReport, got %T", obj)
 	}
 	policyReport := report.report
-	oldPolicyReport := &wgpolicy.PolicyReport{ObjectMeta: metav1.ObjectMeta{
-		Name:      policyReport.GetName(),
-		Namespace: policyReport.GetNamespace(),
-	}}
-
-	operation, err := controllerutil.CreateOrPatch(ctx, s.client, oldPolicyReport, func() error {
-		oldPolicyReport.ObjectMeta.Labels = policyReport.ObjectMeta.Labels
-		oldPolicyReport.ObjectMeta.OwnerReferences = policyReport.ObjectMeta.OwnerReferences
-		oldPolicyReport.Scope = policyReport.Scope
-		oldPolicyReport.Summary = policyReport.Summary
-		oldPolicyReport.Results = policyReport.Results
-
-		return nil
-	})
+	operation, err := s.writer.apply(ctx, policyReport)
 	if err != nil {
-		return fmt.Errorf("failed to create or patch policy report %s: %w", policyReport.GetName(), err)
+		return fmt.Errorf("failed to apply policy report %s: %w", policyReport.GetName(), err)
 	}
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("PolicyReport %s", operation),
 		slog.String("report-name", policyReport.GetName()),
 		slog.String("report-version", policyReport.GetResourceVersion()),
-		slog.String("resource-name", policyReport.Scope.Name),
-		slog.String("resource-namespace", policyReport.Scope.Namespace),
-		slog.String("resource-version", policyReport.Scope.ResourceVersion))
+		slog.String("resource-name", scopeOrEmpty(policyReport.Scope).Name),
+		slog.String("resource-namespace", scopeOrEmpty(policyReport.Scope).Namespace),
+		slog.String("resource-version", scopeOrEmpty(policyReport.Scope).ResourceVersion))
 
 	return nil
 }
 
 // DeleteOldReports deletes old PolicyReports that do not match the given scanRunID.
 func (s *PolicyReportStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
-	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s,%s!=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp, LabelReportType, ReportTypePolicy))
 	if err != nil {
 		return fmt.Errorf("failed to parse label selector: %w", err)
 	}
 	s.logger.DebugContext(ctx, "Deleting old PolicyReports", slog.String("labelSelector", labelSelector.String()))
 
-	if deleteErr := s.client.DeleteAllOf(ctx, &wgpolicy.PolicyReport{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
-		LabelSelector: labelSelector,
-		Namespace:     namespace,
-	}}); deleteErr != nil {
+	if deleteErr := s.writer.deleteOld(ctx, &wgpolicy.PolicyReport{}, namespace, labelSelector); deleteErr != nil {
 		return fmt.Errorf("failed to delete PolicyReports: %w", deleteErr)
 	}
 	return nil
 }
 
+// GetClusterReport returns the ClusterPolicyReport of the given resource.
+func (s *PolicyReportStore) GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (Report, error) {
+	report := &wgpolicy.ClusterPolicyReport{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: string(resource.GetUID())}, report)
+	if apierrors.IsNotFound(err) {
+		return nil, auditConstants.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ClusterPolicyReport %s: %w", resource.GetUID(), err)
+	}
+
+	return &ClusterPolicyReport{report: report}, nil
+}
+
 // CreateOrPatchClusterReport creates or patches a ClusterPolicyReport.
 //
 //nolint:dupl // Temporary duplicated code with openreports_store.go, it's planned to be removed in the near future.
@@ -92,45 +115,50 @@ func (s *PolicyReportStore) CreateOrPatchClusterReport(ctx context.Context, obj
 		return fmt.Errorf("expected *PolicyReport, got %T", obj)
 	}
 	clusterPolicyReport := report.report
-	oldClusterPolicyReport := &wgpolicy.ClusterPolicyReport{ObjectMeta: metav1.ObjectMeta{
-		Name: clusterPolicyReport.GetName(),
-	}}
-
-	operation, err := controllerutil.CreateOrPatch(ctx, s.client, oldClusterPolicyReport, func() error {
-		oldClusterPolicyReport.ObjectMeta.Labels = clusterPolicyReport.ObjectMeta.Labels
-		oldClusterPolicyReport.ObjectMeta.OwnerReferences = clusterPolicyReport.ObjectMeta.OwnerReferences
-		oldClusterPolicyReport.Scope = clusterPolicyReport.Scope
-		oldClusterPolicyReport.Summary = clusterPolicyReport.Summary
-		oldClusterPolicyReport.Results = clusterPolicyReport.Results
-
-		return nil
-	})
+	operation, err := s.writer.apply(ctx, clusterPolicyReport)
 	if err != nil {
-		return fmt.Errorf("failed to create or patch cluster policy report %s: %w", clusterPolicyReport.GetName(), err)
+		return fmt.Errorf("failed to apply cluster policy report %s: %w", clusterPolicyReport.GetName(), err)
 	}
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("ClusterPolicyReport %s", operation),
 		slog.String("report-name", clusterPolicyReport.GetName()),
 		slog.String("report-version", clusterPolicyReport.GetResourceVersion()),
-		slog.String("resource-name", clusterPolicyReport.Scope.Name),
-		slog.String("resource-namespace", clusterPolicyReport.Scope.Namespace),
-		slog.String("resource-version", clusterPolicyReport.Scope.ResourceVersion))
+		slog.String("resource-name", scopeOrEmpty(clusterPolicyReport.Scope).Name),
+		slog.String("resource-namespace", scopeOrEmpty(clusterPolicyReport.Scope).Namespace),
+		slog.String("resource-version", scopeOrEmpty(clusterPolicyReport.Scope).ResourceVersion))
 
 	return nil
 }
 
 // DeleteOldClusterReports deletes old ClusterPolicyReports that do not belong to the current scan run.
 func (s *PolicyReportStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
-	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp))
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s,%s!=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp, LabelReportType, ReportTypePolicy))
 	if err != nil {
 		return fmt.Errorf("failed to parse label selector: %w", err)
 	}
 	s.logger.DebugContext(ctx, "Deleting old ClusterPolicyReports", slog.String("labelSelector", labelSelector.String()))
 
-	if deleteErr := s.client.DeleteAllOf(ctx, &wgpolicy.ClusterPolicyReport{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
-		LabelSelector: labelSelector,
-	}}); deleteErr != nil {
+	if deleteErr := s.writer.deleteOld(ctx, &wgpolicy.ClusterPolicyReport{}, "", labelSelector); deleteErr != nil {
 		return fmt.Errorf("failed to delete ClusterPolicyReports: %w", deleteErr)
 	}
 	return nil
 }
+
+// DeleteOldPolicyResultsReports deletes the ClusterPolicyReports and the PolicyReports of
+// the policies that do not belong to the current scan run. The PolicyReports are
+// deleted in the namespaces where they are found.
+func (s *PolicyReportStore) DeleteOldPolicyResultsReports(ctx context.Context, scanRunID string) error {
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s,%s=%s", auditConstants.AuditScannerRunUIDLabel, scanRunID, labelAppManagedBy, labelApp, LabelReportType, ReportTypePolicy))
+	if err != nil {
+		return fmt.Errorf("failed to parse label selector: %w", err)
+	}
+	s.logger.DebugContext(ctx, "Deleting old reports of the policies", slog.String("labelSelector", labelSelector.String()))
+
+	if deleteErr := s.writer.deleteOld(ctx, &wgpolicy.ClusterPolicyReport{}, "", labelSelector); deleteErr != nil {
+		return fmt.Errorf("failed to delete the ClusterPolicyReports of the policies: %w", deleteErr)
+	}
+	if deleteErr := s.writer.deleteOldInAllNamespaces(ctx, &wgpolicy.PolicyReport{}, labelSelector); deleteErr != nil {
+		return fmt.Errorf("failed to delete the PolicyReports of the policies: %w", deleteErr)
+	}
+	return nil
+}
@@ -22,7 +22,7 @@ func TestCreatePolicyReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewPolicyReportStore(fakeClient, logger)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, logger)
 
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -51,7 +51,7 @@ func TestPatchPolicyReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewPolicyReportStore(fakeClient, logger)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, logger)
 
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -102,7 +102,7 @@ func TestCreateClusterPolicyReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewPolicyReportStore(fakeClient, logger)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, logger)
 
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -130,7 +130,7 @@ func TestPatchClusterPolicyReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient()
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewPolicyReportStore(fakeClient, logger)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, logger)
 
 	resource := unstructured.Unstructured{}
 	resource.SetUID("uid")
@@ -188,7 +188,7 @@ func TestDeletePolicyReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient(oldPolicyReport, otherOldPolicyReport, newPolicyReport, oldPolicyReportOtheNamespace)
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewPolicyReportStore(fakeClient, logger)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, logger)
 
 	err = store.DeleteOldReports(t.Context(), "new-uid", "default")
 	require.NoError(t, err)
@@ -223,7 +223,7 @@ func TestDeleteClusterPolicyReport(t *testing.T) {
 	fakeClient, err := testutils.NewFakeClient(oldPolicyReport, otherOldPolicyReport, newPolicyReport)
 	require.NoError(t, err)
 	logger := slog.Default()
-	store := NewPolicyReportStore(fakeClient, logger)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, logger)
 
 	err = store.DeleteOldClusterReports(t.Context(), "new-uid")
 	require.NoError(t, err)
@@ -245,3 +245,108 @@ func TestDeleteClusterPolicyReport(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, storedPolicyReportList.Items, 1)
 }
+
+func TestDeleteOldPolicyResultsReports(t *testing.T) {
+	oldClusterReport := testutils.NewClusterPolicyReportFactory().
+		Name("old-cluster-report").WithAppLabel().RunUID("old-uid").Build()
+	oldClusterPolicyReport := testutils.NewClusterPolicyReportFactory().
+		Name("old-cluster-policy-report").WithAppLabel().WithPolicyResultsLabel().RunUID("old-uid").Build()
+	newClusterPolicyReport := testutils.NewClusterPolicyReportFactory().
+		Name("new-cluster-policy-report").WithAppLabel().WithPolicyResultsLabel().RunUID("new-uid").Build()
+	oldReport := testutils.NewPolicyReportFactory().
+		Name("old-report").Namespace("default").WithAppLabel().RunUID("old-uid").Build()
+	oldPolicyReport := testutils.NewPolicyReportFactory().
+		Name("old-policy-report").Namespace("default").WithAppLabel().WithPolicyResultsLabel().RunUID("old-uid").Build()
+	oldPolicyReportOtherNamespace := testutils.NewPolicyReportFactory().
+		Name("old-policy-report").Namespace("other").WithAppLabel().WithPolicyResultsLabel().RunUID("old-uid").Build()
+
+	fakeClient, err := testutils.NewFakeClient(oldClusterReport, oldClusterPolicyReport, newClusterPolicyReport,
+		oldReport, oldPolicyReport, oldPolicyReportOtherNamespace)
+	require.NoError(t, err)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, slog.Default())
+
+	storedNames := func() []string {
+		clusterPolicyReports := &wgpolicy.ClusterPolicyReportList{}
+		require.NoError(t, fakeClient.List(t.Context(), clusterPolicyReports))
+		policyReports := &wgpolicy.PolicyReportList{}
+		require.NoError(t, fakeClient.List(t.Context(), policyReports))
+
+		names := []string{}
+		for _, clusterPolicyReport := range clusterPolicyReports.Items {
+			names = append(names, clusterPolicyReport.Name)
+		}
+		for _, policyReport := range policyReports.Items {
+			names = append(names, policyReport.Namespace+"/"+policyReport.Name)
+		}
+		return names
+	}
+
+	// the reports of the policies are not deleted with the reports of the
+	// resources
+	require.NoError(t, store.DeleteOldReports(t.Context(), "new-uid", "default"))
+	require.NoError(t, store.DeleteOldClusterReports(t.Context(), "new-uid"))
+	require.ElementsMatch(t, []string{
+		"old-cluster-policy-report",
+		"new-cluster-policy-report",
+		"default/old-policy-report",
+		"other/old-policy-report",
+	}, storedNames())
+
+	require.NoError(t, store.DeleteOldPolicyResultsReports(t.Context(), "new-uid"))
+	require.ElementsMatch(t, []string{"new-cluster-policy-report"}, storedNames())
+}
+
+func TestGetPolicyReport(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	logger := slog.Default()
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, logger)
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-pod")
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetResourceVersion("12345")
+
+	_, err = store.GetReport(t.Context(), resource)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	policyReport := NewPolicyReport("runUID", resource)
+	err = store.CreateOrPatchReport(t.Context(), policyReport)
+	require.NoError(t, err)
+
+	storedReport, err := store.GetReport(t.Context(), resource)
+	require.NoError(t, err)
+	storedPolicyReport, ok := storedReport.(*PolicyReport)
+	require.True(t, ok)
+	require.Equal(t, policyReport.report.Scope, storedPolicyReport.report.Scope)
+}
+
+func TestGetClusterPolicyReport(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	logger := slog.Default()
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, logger)
+
+	resource := unstructured.Unstructured{}
+	resource.SetUID("uid")
+	resource.SetName("test-namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Namespace")
+	resource.SetResourceVersion("12345")
+
+	_, err = store.GetClusterReport(t.Context(), resource)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+
+	clusterPolicyReport := NewClusterPolicyReport("runUID", resource)
+	err = store.CreateOrPatchClusterReport(t.Context(), clusterPolicyReport)
+	require.NoError(t, err)
+
+	storedReport, err := store.GetClusterReport(t.Context(), resource)
+	require.NoError(t, err)
+	storedClusterPolicyReport, ok := storedReport.(*ClusterPolicyReport)
+	require.True(t, ok)
+	require.Equal(t, clusterPolicyReport.report.Scope, storedClusterPolicyReport.report.Scope)
+}
@@ -7,6 +7,7 @@ import (
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -223,6 +224,42 @@ func TestNewPolicyReportResult(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Validating policy, UPDATE request",
+			policy: &policiesv1.ClusterAdmissionPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					UID:             "policy-uid",
+					ResourceVersion: "1",
+					Name:            "policy-name",
+				},
+			},
+			admissionReview: &admissionv1.AdmissionReview{
+				Request: &admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+				},
+				Response: &admissionv1.AdmissionResponse{
+					Allowed: true,
+					Result:  nil,
+				},
+			},
+			errored: false,
+			expectedResult: &wgpolicy.PolicyReportResult{
+				Source:          policyReportSource,
+				Policy:          "clusterwide-policy-name",
+				Result:          statusPass,
+				Timestamp:       now,
+				Scored:          true,
+				SubjectSelector: &metav1.LabelSelector{},
+				Description:     "",
+				Properties: map[string]string{
+					propertyPolicyUID:             "policy-uid",
+					propertyPolicyResourceVersion: "1",
+					propertyPolicyName:            "policy-name",
+					propertyOperation:             "UPDATE",
+					typeValidating:                valueTypeTrue,
+				},
+			},
+		},
 		{
 			name: "Mutating policy, rejected response",
 			policy: &policiesv1.AdmissionPolicy{
@@ -322,3 +359,108 @@ func TestNewPolicyReportResult(t *testing.T) {
 		})
 	}
 }
+
+func TestReuseResultFromPreviousPolicyReport(t *testing.T) {
+	admissionReview := &admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{Operation: admissionv1.Create},
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "The request was rejected"},
+		},
+	}
+
+	tests := []struct {
+		name                   string
+		currentResourceVersion string
+		currentPolicyVersion   string
+		currentOperation       admissionv1.Operation
+		contextAware           bool
+		errored                bool
+		expectedReused         bool
+	}{
+		{
+			name:                   "Resource and policy unchanged",
+			currentResourceVersion: "1",
+			currentPolicyVersion:   "1",
+			expectedReused:         true,
+		},
+		{
+			name:                   "Resource changed",
+			currentResourceVersion: "2",
+			currentPolicyVersion:   "1",
+			expectedReused:         false,
+		},
+		{
+			name:                   "Policy changed",
+			currentResourceVersion: "1",
+			currentPolicyVersion:   "2",
+			expectedReused:         false,
+		},
+		{
+			name:                   "Operation changed",
+			currentResourceVersion: "1",
+			currentPolicyVersion:   "1",
+			currentOperation:       admissionv1.Update,
+			expectedReused:         false,
+		},
+		{
+			name:                   "Previous result errored",
+			currentResourceVersion: "1",
+			currentPolicyVersion:   "1",
+			errored:                true,
+			expectedReused:         false,
+		},
+		{
+			name:                   "Context aware policy",
+			currentResourceVersion: "1",
+			currentPolicyVersion:   "1",
+			contextAware:           true,
+			expectedReused:         false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := &policiesv1.ClusterAdmissionPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					UID:             "policy-uid",
+					ResourceVersion: "1",
+					Name:            "policy-name",
+				},
+			}
+			if test.contextAware {
+				policy.Spec.ContextAwareResources = []policiesv1.ContextAwareResource{{APIVersion: "v1", Kind: "Pod"}}
+			}
+
+			resource := unstructured.Unstructured{}
+			resource.SetUID("uid")
+			resource.SetResourceVersion("1")
+			previousReport := NewPolicyReport("previousRunUID", resource)
+			previousReport.AddResult(policy, admissionReview, test.errored)
+			previousReport.report.Results[0].Timestamp = metav1.Timestamp{Seconds: 1}
+
+			operation := admissionv1.Create
+			if test.currentOperation != "" {
+				operation = test.currentOperation
+			}
+			resource.SetResourceVersion(test.currentResourceVersion)
+			policy.SetResourceVersion(test.currentPolicyVersion)
+			policyReport := NewPolicyReport("runUID", resource)
+
+			reused := policyReport.ReuseResult(previousReport, policy, operation)
+
+			assert.Equal(t, test.expectedReused, reused)
+			if test.expectedReused {
+				require.Len(t, policyReport.report.Results, 1)
+				// the reused result holds for the current scan
+				assert.Greater(t, policyReport.report.Results[0].Timestamp.Seconds, int64(1))
+				policyReport.report.Results[0].Timestamp = previousReport.report.Results[0].Timestamp
+				assert.Equal(t, previousReport.report.Results, policyReport.report.Results)
+				assert.Equal(t, 1, policyReport.report.Summary.Fail)
+			} else {
+				assert.Empty(t, policyReport.report.Results)
+				assert.Equal(t, 0, policyReport.report.Summary.Fail)
+			}
+		})
+	}
+}
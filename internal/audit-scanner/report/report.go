@@ -1,8 +1,13 @@
 package report
 
 import (
+	"maps"
+	"slices"
+	"time"
+
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +28,18 @@ type Report interface {
 	SetSkipPolicies(n int)
 	SetErrorPolicies(n int)
 	AddResult(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview, errored bool)
+	// ReuseResult copies the result of the given policy, evaluated for the
+	// given operation, from a report created by a previous scan, if it is
+	// still valid. Returns true when the result has been reused and the
+	// policy does not need to be evaluated.
+	ReuseResult(previous Report, policy policiesv1.Policy, operation admissionv1.Operation) bool
+	// KeepResults copies the results of a report of the same resource
+	// created by a previous scan, except the ones of the given policies. It
+	// merges the results of a scan restricted to some policies with the
+	// results of the other policies.
+	KeepResults(previous Report, replacedPolicies []string)
+	// Entries returns a flat view of the policy results of the report.
+	Entries() []ResultEntry
 }
 
 func getCategoryAndMessage(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview) (string, string) {
@@ -68,7 +85,7 @@ func computePolicyResultSeverity(policy policiesv1.Policy) string {
 	return ""
 }
 
-func computeProperties(policy policiesv1.Policy) map[string]string {
+func computeProperties(policy policiesv1.Policy, admissionReview *admissionv1.AdmissionReview) map[string]string {
 	properties := map[string]string{}
 	if policy.IsMutating() {
 		properties[typeMutating] = valueTypeTrue
@@ -87,10 +104,109 @@ func computeProperties(policy policiesv1.Policy) map[string]string {
 	if policy.GetNamespace() != "" {
 		properties[propertyPolicyNamespace] = policy.GetNamespace()
 	}
+	// The operation simulated by the audit scanner, CREATE or UPDATE
+	if admissionReview != nil && admissionReview.Request != nil {
+		properties[propertyOperation] = string(admissionReview.Request.Operation)
+	}
+	// The resource drifted from what the mutating policy enforces
+	if admissionReview != nil && admissionReview.Response != nil && len(admissionReview.Response.Patch) > 0 {
+		maps.Copy(properties, computePatchProperties(admissionReview.Response.Patch))
+	}
+	// The expression and the members outcomes explain the result of a group
+	if policyGroup, ok := policy.(policiesv1.PolicyGroup); ok {
+		maps.Copy(properties, computeGroupProperties(policyGroup, admissionReview))
+	}
 
 	return properties
 }
 
+// isResultReusable checks if a result computed by a previous scan is still
+// valid. That's the case when neither the audited resource nor the policy
+// changed since then, and the policy is evaluated for the same operation.
+// Errored results are never reused, the error could be transient. Results of
+// context-aware policies are not reused either, because they depend on other
+// resources that may have changed.
+func isResultReusable(previousScope, currentScope *corev1.ObjectReference, result string, properties map[string]string, policy policiesv1.Policy, operation admissionv1.Operation) bool {
+	if previousScope == nil || currentScope == nil {
+		return false
+	}
+	if currentScope.ResourceVersion == "" ||
+		previousScope.UID != currentScope.UID ||
+		previousScope.ResourceVersion != currentScope.ResourceVersion {
+		return false
+	}
+	if result == statusError || policy.IsContextAware() {
+		return false
+	}
+
+	return properties[propertyPolicyResourceVersion] == policy.GetResourceVersion() &&
+		properties[propertyPolicyUID] == string(policy.GetUID()) &&
+		properties[propertyOperation] == string(operation)
+}
+
+// resultFields reads and copies the results of a kind of report.
+type resultFields[R any] struct {
+	policy       func(result R) string
+	status       func(result R) string
+	properties   func(result R) map[string]string
+	deepCopy     func(result R) R
+	setTimestamp func(result R, timestamp metav1.Timestamp) R
+}
+
+// resultSet gives access to the results of a report and to their summary.
+// It shares the handling of the results between the kinds of reports.
+type resultSet[R any] struct {
+	scope   *corev1.ObjectReference
+	results *[]R
+	summary *openreports.ReportSummary
+	fields  resultFields[R]
+}
+
+// add appends the result and counts it in the summary.
+func (s resultSet[R]) add(result R) {
+	switch s.fields.status(result) {
+	case statusFail:
+		s.summary.Fail++
+	case statusError:
+		s.summary.Error++
+	case statusPass:
+		s.summary.Pass++
+	}
+	*s.results = append(*s.results, result)
+}
+
+// reuse adds a copy of the result of the given policy found in the previous
+// results, if it is still valid. The timestamp of the copy is refreshed, the
+// result holds for the current scan too. Returns true when a result has been
+// reused.
+func (s resultSet[R]) reuse(previous resultSet[R], policy policiesv1.Policy, operation admissionv1.Operation) bool {
+	for _, result := range *previous.results {
+		if s.fields.policy(result) != policy.GetUniqueName() ||
+			!isResultReusable(previous.scope, s.scope, s.fields.status(result), s.fields.properties(result), policy, operation) {
+			continue
+		}
+		now := metav1.Timestamp{Seconds: time.Now().Unix()}
+		s.add(s.fields.setTimestamp(s.fields.deepCopy(result), now))
+		return true
+	}
+	return false
+}
+
+// keep adds copies of the previous results of the same resource, except the
+// ones of the given policies.
+func (s resultSet[R]) keep(previous resultSet[R], replacedPolicies []string) {
+	if previous.scope == nil || s.scope == nil || previous.scope.UID != s.scope.UID {
+		return
+	}
+	for _, result := range *previous.results {
+		policyName := s.fields.policy(result)
+		if policyName == "" || slices.Contains(replacedPolicies, policyName) {
+			continue
+		}
+		s.add(s.fields.deepCopy(result))
+	}
+}
+
 func getReportObjectMeta(runUID string, resource unstructured.Unstructured) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Name: string(resource.GetUID()),
@@ -120,3 +236,12 @@ func getReportScope(resource unstructured.Unstructured) *corev1.ObjectReference
 		ResourceVersion: resource.GetResourceVersion(),
 	}
 }
+
+// scopeOrEmpty returns the resource of a report, or an empty reference for
+// the aggregated reports, holding the results of several resources.
+func scopeOrEmpty(scope *corev1.ObjectReference) corev1.ObjectReference {
+	if scope == nil {
+		return corev1.ObjectReference{}
+	}
+	return *scope
+}
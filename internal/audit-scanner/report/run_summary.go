@@ -0,0 +1,183 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// RunSummaryDataKey is the key of the ConfigMap data holding the JSON encoded run summary
+	RunSummaryDataKey = "summary.json"
+	// LabelRunSummary identifies the ConfigMaps holding a run summary
+	LabelRunSummary   = "kubewarden.io/audit-scanner-run-summary"
+	runSummaryPrefix  = "audit-scanner-run-"
+	labelValueSummary = "true"
+)
+
+// RunSummary aggregates the outcome of a scan run.
+type RunSummary struct {
+	RunUID    string      `json:"runUID"`
+	StartTime metav1.Time `json:"startTime"`
+	EndTime   metav1.Time `json:"endTime"`
+	// Namespaces is the list of the scanned namespaces
+	Namespaces []string `json:"namespaces"`
+	// ResourcesAudited is the number of audited resources
+	ResourcesAudited int `json:"resourcesAudited"`
+	// Policies holds the results of each policy, by policy unique name
+	Policies map[string]*PolicySummary `json:"policies"`
+	// SkippedPolicies are the policies skipped because they don't match the audit constraints
+	SkippedPolicies []string `json:"skippedPolicies"`
+	// ErroredPolicies are the policies that could not be used, they may be misconfigured
+	ErroredPolicies []string `json:"erroredPolicies"`
+	// Incomplete is true when the run was interrupted before auditing all
+	// the resources
+	Incomplete bool `json:"incomplete,omitempty"`
+	// ShardIndex and ShardCount identify the shard of a sharded run. Each
+	// shard of the run stores its own summary
+	ShardIndex int `json:"shardIndex,omitempty"`
+	ShardCount int `json:"shardCount,omitempty"`
+	// Resumed is true when the run was resumed from a checkpoint. The
+	// summary only holds the results of the audits done after the resume
+	Resumed bool `json:"resumed,omitempty"`
+}
+
+// PolicySummary holds the results of a policy in a scan run.
+type PolicySummary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Error int `json:"error"`
+	// Skip counts the resources targeted by the policy but not evaluated,
+	// because of the policy selectors or matchConditions
+	Skip int `json:"skip"`
+}
+
+// AddResourceResults records the results of the audit of a resource against
+// the given policies. The policies without a result in the report did not
+// match the resource and are counted as skipped.
+func (s *RunSummary) AddResourceResults(policyNames []string, resourceReport Report) {
+	if s.Policies == nil {
+		s.Policies = map[string]*PolicySummary{}
+	}
+	results := map[string]string{}
+	for _, entry := range resourceReport.Entries() {
+		results[entry.Policy] = entry.Result
+	}
+
+	s.ResourcesAudited++
+	for _, name := range policyNames {
+		policySummary, found := s.Policies[name]
+		if !found {
+			policySummary = &PolicySummary{}
+			s.Policies[name] = policySummary
+		}
+
+		switch results[name] {
+		case statusPass:
+			policySummary.Pass++
+		case statusFail:
+			policySummary.Fail++
+		case statusError:
+			policySummary.Error++
+		default:
+			policySummary.Skip++
+		}
+	}
+}
+
+// RunSummaryName returns the name of the ConfigMap holding the summary of the given run.
+func RunSummaryName(runUID string) string {
+	return runSummaryPrefix + runUID
+}
+
+// ShardRunSummaryName returns the name of the ConfigMap holding the summary of
+// the given shard of a sharded run.
+func ShardRunSummaryName(runUID string, shardIndex int) string {
+	return fmt.Sprintf("%s%s-shard-%d", runSummaryPrefix, runUID, shardIndex)
+}
+
+// Name returns the name of the ConfigMap holding the summary.
+func (s *RunSummary) Name() string {
+	if s.ShardCount > 1 {
+		return ShardRunSummaryName(s.RunUID, s.ShardIndex)
+	}
+	return RunSummaryName(s.RunUID)
+}
+
+// createOrPatchRunSummary stores the summary in a ConfigMap of the given namespace.
+func createOrPatchRunSummary(ctx context.Context, c client.Client, namespace string, summary *RunSummary) (controllerutil.OperationResult, error) {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return controllerutil.OperationResultNone, fmt.Errorf("failed to encode the run summary: %w", err)
+	}
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      summary.Name(),
+		Namespace: namespace,
+	}}
+	operation, err := controllerutil.CreateOrPatch(ctx, c, configMap, func() error {
+		configMap.Labels = map[string]string{
+			labelAppManagedBy:                      labelApp,
+			LabelRunSummary:                        labelValueSummary,
+			auditConstants.AuditScannerRunUIDLabel: summary.RunUID,
+		}
+		configMap.Data = map[string]string{
+			RunSummaryDataKey: string(data),
+		}
+
+		return nil
+	})
+	if err != nil {
+		return operation, fmt.Errorf("failed to create or patch run summary %s: %w", configMap.GetName(), err)
+	}
+
+	return operation, nil
+}
+
+// getRunSummaries returns the summaries of the given run stored in the given
+// namespace. A sharded run has a summary for each shard.
+func getRunSummaries(ctx context.Context, c client.Client, scanRunID, namespace string) ([]RunSummary, error) {
+	configMaps := &corev1.ConfigMapList{}
+	if err := c.List(ctx, configMaps, client.InNamespace(namespace), client.MatchingLabels{
+		auditConstants.AuditScannerRunUIDLabel: scanRunID,
+		LabelRunSummary:                        labelValueSummary,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list run summaries: %w", err)
+	}
+
+	summaries := make([]RunSummary, 0, len(configMaps.Items))
+	for _, configMap := range configMaps.Items {
+		summary := RunSummary{}
+		if err := json.Unmarshal([]byte(configMap.Data[RunSummaryDataKey]), &summary); err != nil {
+			return nil, fmt.Errorf("failed to decode run summary %s: %w", configMap.GetName(), err)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// deleteOldRunSummaries deletes the summaries of the runs other than the given one.
+func deleteOldRunSummaries(ctx context.Context, c client.Client, scanRunID, namespace string) error {
+	labelSelector, err := labels.Parse(fmt.Sprintf("%s!=%s,%s=%s,%s=%s",
+		auditConstants.AuditScannerRunUIDLabel, scanRunID,
+		labelAppManagedBy, labelApp,
+		LabelRunSummary, labelValueSummary))
+	if err != nil {
+		return fmt.Errorf("failed to parse label selector: %w", err)
+	}
+
+	if deleteErr := c.DeleteAllOf(ctx, &corev1.ConfigMap{}, &client.DeleteAllOfOptions{ListOptions: client.ListOptions{
+		LabelSelector: labelSelector,
+		Namespace:     namespace,
+	}}); deleteErr != nil {
+		return fmt.Errorf("failed to delete run summaries: %w", deleteErr)
+	}
+	return nil
+}
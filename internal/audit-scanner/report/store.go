@@ -4,21 +4,58 @@ import (
 	"context"
 	"log/slog"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // Store is an interface to abstract the storage of reports. It's agnostic to the
 // kind of report used (PolicyReport or OpenReport).
 type Store interface {
+	// GetReport returns the report stored for the given namespaced resource.
+	// Returns constants.ErrResourceNotFound when there's no such report.
+	GetReport(ctx context.Context, resource unstructured.Unstructured) (Report, error)
 	CreateOrPatchReport(ctx context.Context, report any) error
 	DeleteOldReports(ctx context.Context, scanRunID, namespace string) error
+	// GetClusterReport returns the report stored for the given cluster-wide resource.
+	// Returns constants.ErrResourceNotFound when there's no such report.
+	GetClusterReport(ctx context.Context, resource unstructured.Unstructured) (Report, error)
 	CreateOrPatchClusterReport(ctx context.Context, report any) error
 	DeleteOldClusterReports(ctx context.Context, scanRunID string) error
+	// DeleteOldPolicyResultsReports deletes the reports of the policies, the
+	// cluster ones and the ones of all the namespaces, that do not belong to
+	// the given scan run. The reports of the policies are not deleted by
+	// DeleteOldReports and DeleteOldClusterReports.
+	DeleteOldPolicyResultsReports(ctx context.Context, scanRunID string) error
 }
 
-func NewReportStoreOfKind(kind CrdKind, client client.Client, logger *slog.Logger) Store {
+// RunSummaryStore is an interface to abstract the storage of the summaries of
+// the scan runs.
+type RunSummaryStore interface {
+	// CreateOrPatchRunSummary stores the summary of a scan run in the given namespace.
+	CreateOrPatchRunSummary(ctx context.Context, namespace string, summary *RunSummary) error
+	// GetRunSummaries returns the summaries of the given scan run stored in the given namespace.
+	GetRunSummaries(ctx context.Context, scanRunID, namespace string) ([]RunSummary, error)
+	// DeleteOldRunSummaries deletes the summaries of the scan runs other than the given one.
+	DeleteOldRunSummaries(ctx context.Context, scanRunID, namespace string) error
+}
+
+// CheckpointStore is an interface to abstract the storage of the progress of
+// the scan runs.
+type CheckpointStore interface {
+	// GetCheckpoint returns the checkpoint of the given name stored in the given namespace.
+	// Returns constants.ErrResourceNotFound when there's no such checkpoint.
+	GetCheckpoint(ctx context.Context, namespace, name string) (*Checkpoint, error)
+	// CreateOrPatchCheckpoint stores the checkpoint of a scan run in the given namespace.
+	CreateOrPatchCheckpoint(ctx context.Context, namespace string, checkpoint *Checkpoint) error
+	// DeleteCheckpoint deletes the checkpoint of the given name stored in the given namespace.
+	DeleteCheckpoint(ctx context.Context, namespace, name string) error
+}
+
+// NewReportStoreOfKind returns the store of the given kind of reports,
+// writing them as configured.
+func NewReportStoreOfKind(kind CrdKind, client client.Client, writerConfig WriterConfig, logger *slog.Logger) Store {
 	if kind == ReportKindPolicyReport {
-		return NewPolicyReportStore(client, logger)
+		return NewPolicyReportStore(client, writerConfig, logger)
 	}
-	return NewOpenReportStore(client, logger)
+	return NewOpenReportStore(client, writerConfig, logger)
 }
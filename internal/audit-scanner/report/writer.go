@@ -0,0 +1,376 @@
+package report
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// FieldManager is the field manager of the reports applied by the scanner
+	FieldManager = "kubewarden-audit-scanner"
+	// annotationResultsHash holds the hash of the content of a report,
+	// without the run UID and the timestamps of the results
+	annotationResultsHash = "kubewarden.io/audit-scanner-results-hash"
+)
+
+// The outcomes of the writes of the reports.
+const (
+	operationApplied   = "applied"
+	operationUnchanged = "unchanged"
+)
+
+// WriterConfig configures how the reports are written to the API server.
+type WriterConfig struct {
+	// QPS is the number of reports written per second, the other writes wait
+	// their turn. 0 means no limit
+	QPS float32
+	// Burst is the number of reports written at once before the QPS limit
+	// applies. Defaults to QPS
+	Burst int
+	// SkipUnchanged skips the writes of the reports whose results did not
+	// change since the previous run, apart from their timestamps. Only their
+	// run UID label is patched, so they are not deleted with the old reports
+	SkipUnchanged bool
+}
+
+// reportsKey identifies the reports of a kind in a namespace, empty for the
+// cluster reports.
+type reportsKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+}
+
+// storedReport is the metadata of a stored report compared with the reports
+// written.
+type storedReport struct {
+	hash   string
+	runUID string
+}
+
+// reportWriter writes the reports with server-side apply, through a rate
+// limited queue. It's shared by the concurrent audits of a scan run.
+type reportWriter struct {
+	client        client.Client
+	limiter       flowcontrol.RateLimiter
+	skipUnchanged bool
+
+	mutex sync.Mutex
+	// stored holds the metadata of the stored reports by name, loaded with a
+	// list of their metadata when the first report of a namespace is written
+	stored map[reportsKey]map[string]storedReport
+}
+
+func newReportWriter(client client.Client, config WriterConfig) *reportWriter {
+	var limiter flowcontrol.RateLimiter
+	if config.QPS > 0 {
+		burst := config.Burst
+		if burst <= 0 {
+			burst = max(int(config.QPS), 1)
+		}
+		limiter = flowcontrol.NewTokenBucketRateLimiter(config.QPS, burst)
+	}
+
+	return &reportWriter{
+		client:        client,
+		limiter:       limiter,
+		skipUnchanged: config.SkipUnchanged,
+		stored:        map[reportsKey]map[string]storedReport{},
+	}
+}
+
+// apply writes the given report with server-side apply, unless it's
+// unchanged. The run UID label of an unchanged report is patched instead, so
+// the report is not deleted with the old ones. It returns the outcome of the
+// write.
+func (w *reportWriter) apply(ctx context.Context, obj client.Object) (string, error) {
+	applyConfiguration, gvk, err := w.applyConfiguration(obj)
+	if err != nil {
+		return "", err
+	}
+	hash, err := resultsHash(applyConfiguration)
+	if err != nil {
+		return "", err
+	}
+	applyConfiguration.SetAnnotations(map[string]string{annotationResultsHash: hash})
+
+	key := reportsKey{gvk: gvk, namespace: obj.GetNamespace()}
+	runUID := obj.GetLabels()[auditConstants.AuditScannerRunUIDLabel]
+	if w.skipUnchanged {
+		stored, found, getErr := w.storedReport(ctx, key, obj.GetName())
+		if getErr != nil {
+			return "", getErr
+		}
+		if found && stored.hash == hash {
+			unchanged, labelErr := w.setRunUID(ctx, key, obj.GetName(), stored, runUID)
+			if labelErr != nil {
+				return "", labelErr
+			}
+			if unchanged {
+				return operationUnchanged, nil
+			}
+		}
+	}
+
+	if err = w.wait(ctx); err != nil {
+		return "", err
+	}
+	if err = w.client.Apply(ctx, client.ApplyConfigurationFromUnstructured(applyConfiguration), client.FieldOwner(FieldManager), client.ForceOwnership); err != nil {
+		return "", fmt.Errorf("failed to apply %s %s: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	if w.skipUnchanged {
+		w.setStoredReport(key, obj.GetName(), storedReport{hash: hash, runUID: runUID})
+	}
+	return operationApplied, nil
+}
+
+// setRunUID patches the run UID label of the given unchanged report, unless
+// it's already set. It returns false when the report was deleted meanwhile,
+// it must be written again.
+func (w *reportWriter) setRunUID(ctx context.Context, key reportsKey, name string, stored storedReport, runUID string) (bool, error) {
+	if stored.runUID == runUID {
+		return true, nil
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": map[string]string{auditConstants.AuditScannerRunUIDLabel: runUID},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to encode the run UID patch: %w", err)
+	}
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(key.gvk)
+	obj.SetName(name)
+	obj.SetNamespace(key.namespace)
+
+	if err = w.wait(ctx); err != nil {
+		return false, err
+	}
+	err = w.client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch), client.FieldOwner(FieldManager))
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to patch the run UID of %s %s: %w", key.gvk.Kind, name, err)
+	}
+
+	stored.runUID = runUID
+	w.setStoredReport(key, name, stored)
+	return true, nil
+}
+
+// create creates the given report, unless a report of the same name already
+// exists. It returns true when the report was created.
+func (w *reportWriter) create(ctx context.Context, obj client.Object) (bool, error) {
+	if err := w.wait(ctx); err != nil {
+		return false, err
+	}
+	err := w.client.Create(ctx, obj, client.FieldOwner(FieldManager))
+	if apierrors.IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", obj.GetName(), err)
+	}
+	return true, nil
+}
+
+// deleteOld deletes the reports of the kind of the given object and of the
+// given namespace matching the selector of the old reports.
+func (w *reportWriter) deleteOld(ctx context.Context, obj client.Object, namespace string, labelSelector labels.Selector) error {
+	gvk, err := apiutil.GVKForObject(obj, w.client.Scheme())
+	if err != nil {
+		return fmt.Errorf("failed to get the kind of the reports: %w", err)
+	}
+
+	// the next writes of the namespace compare their hashes with the
+	// reports left after the deletion
+	w.mutex.Lock()
+	delete(w.stored, reportsKey{gvk: gvk, namespace: namespace})
+	w.mutex.Unlock()
+
+	if err = w.wait(ctx); err != nil {
+		return err
+	}
+	listOptions := client.ListOptions{LabelSelector: labelSelector, Namespace: namespace}
+	if err = w.client.DeleteAllOf(ctx, obj, &client.DeleteAllOfOptions{ListOptions: listOptions}); err != nil {
+		return fmt.Errorf("failed to delete the %s reports: %w", gvk.Kind, err)
+	}
+	return nil
+}
+
+// deleteOldInAllNamespaces deletes the reports of the kind of the given
+// object matching the selector of the old reports, in all the namespaces. The
+// collections can only be deleted by namespace, the namespaces are the ones of
+// the matching reports.
+func (w *reportWriter) deleteOldInAllNamespaces(ctx context.Context, obj client.Object, labelSelector labels.Selector) error {
+	gvk, err := apiutil.GVKForObject(obj, w.client.Scheme())
+	if err != nil {
+		return fmt.Errorf("failed to get the kind of the reports: %w", err)
+	}
+	oldReports, err := w.listMetadata(ctx, gvk, &client.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return err
+	}
+
+	namespaces := map[string]struct{}{}
+	for _, item := range oldReports.Items {
+		namespaces[item.Namespace] = struct{}{}
+	}
+	for namespace := range namespaces {
+		err = errors.Join(err, w.deleteOld(ctx, obj, namespace, labelSelector))
+	}
+	return err
+}
+
+// storedReport returns the metadata of the stored report of the given name,
+// and false when there's none. The metadata are listed without holding the
+// mutex, so the writes of the other namespaces don't wait for the list. The
+// concurrent first writes of a namespace may list them each, the first list
+// stored is kept.
+func (w *reportWriter) storedReport(ctx context.Context, key reportsKey, name string) (storedReport, bool, error) {
+	w.mutex.Lock()
+	_, found := w.stored[key]
+	w.mutex.Unlock()
+
+	if !found {
+		storedReports, err := w.listMetadata(ctx, key.gvk, &client.ListOptions{
+			LabelSelector: labels.SelectorFromSet(labels.Set{labelAppManagedBy: labelApp}),
+			Namespace:     key.namespace,
+		})
+		if err != nil {
+			return storedReport{}, false, err
+		}
+		stored := make(map[string]storedReport, len(storedReports.Items))
+		for _, item := range storedReports.Items {
+			stored[item.Name] = storedReport{
+				hash:   item.Annotations[annotationResultsHash],
+				runUID: item.Labels[auditConstants.AuditScannerRunUIDLabel],
+			}
+		}
+
+		w.mutex.Lock()
+		if _, found = w.stored[key]; !found {
+			w.stored[key] = stored
+		}
+		w.mutex.Unlock()
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	metadata, found := w.stored[key][name]
+	return metadata, found, nil
+}
+
+// setStoredReport records the metadata of the report of the given name, once
+// written, when the reports of its namespace are listed.
+func (w *reportWriter) setStoredReport(key reportsKey, name string, metadata storedReport) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if stored, found := w.stored[key]; found {
+		stored[name] = metadata
+	}
+}
+
+// listMetadata lists the metadata of the reports of the given kind, they are
+// smaller than the reports.
+func (w *reportWriter) listMetadata(ctx context.Context, gvk schema.GroupVersionKind, listOptions *client.ListOptions) (*metav1.PartialObjectMetadataList, error) {
+	list := &metav1.PartialObjectMetadataList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := w.client.List(ctx, list, listOptions); err != nil {
+		return nil, fmt.Errorf("failed to list the %s reports: %w", gvk.Kind, err)
+	}
+	return list, nil
+}
+
+// wait waits for the turn of a write, when the writes are rate limited.
+func (w *reportWriter) wait(ctx context.Context) error {
+	if w.limiter == nil {
+		return nil
+	}
+	if err := w.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to wait for the report writes rate limiter: %w", err)
+	}
+	return nil
+}
+
+// applyConfiguration returns the given report as a server-side apply
+// configuration, and its kind.
+func (w *reportWriter) applyConfiguration(obj client.Object) (*unstructured.Unstructured, schema.GroupVersionKind, error) {
+	gvk, err := apiutil.GVKForObject(obj, w.client.Scheme())
+	if err != nil {
+		return nil, schema.GroupVersionKind{}, fmt.Errorf("failed to get the kind of the report: %w", err)
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, schema.GroupVersionKind{}, fmt.Errorf("failed to convert the report: %w", err)
+	}
+
+	applyConfiguration := &unstructured.Unstructured{Object: content}
+	applyConfiguration.SetGroupVersionKind(gvk)
+	applyConfiguration.SetResourceVersion("")
+	applyConfiguration.SetManagedFields(nil)
+	unstructured.RemoveNestedField(applyConfiguration.Object, "metadata", "creationTimestamp")
+	// the counters and the results omitted when empty are set anyway, so
+	// they replace the ones of the reports written before with a patch
+	for _, counter := range []string{statusPass, statusFail, statusWarn, statusError, statusSkip} {
+		if _, found, _ := unstructured.NestedFieldNoCopy(applyConfiguration.Object, "summary", counter); !found {
+			if err = unstructured.SetNestedField(applyConfiguration.Object, int64(0), "summary", counter); err != nil {
+				return nil, schema.GroupVersionKind{}, fmt.Errorf("failed to set the summary of the report: %w", err)
+			}
+		}
+	}
+	if _, found := applyConfiguration.Object["results"]; !found {
+		applyConfiguration.Object["results"] = []any{}
+	}
+	return applyConfiguration, gvk, nil
+}
+
+// resultsHash returns the hash of the content of the given report, without
+// its run UID and the timestamps of its results.
+func resultsHash(applyConfiguration *unstructured.Unstructured) (string, error) {
+	reportLabels := maps.Clone(applyConfiguration.GetLabels())
+	delete(reportLabels, auditConstants.AuditScannerRunUIDLabel)
+
+	results, _ := runtime.DeepCopyJSONValue(applyConfiguration.Object["results"]).([]any)
+	for _, result := range results {
+		if resultMap, ok := result.(map[string]any); ok {
+			delete(resultMap, "timestamp")
+		}
+	}
+
+	content, err := json.Marshal(map[string]any{
+		"labels":          reportLabels,
+		"ownerReferences": applyConfiguration.GetOwnerReferences(),
+		"scope":           applyConfiguration.Object["scope"],
+		"summary":         applyConfiguration.Object["summary"],
+		"results":         results,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the content of the report: %w", err)
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
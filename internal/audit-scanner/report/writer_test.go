@@ -0,0 +1,116 @@
+package report
+
+import (
+	"log/slog"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+func newWriterTestReport(runUID, name string, allowed bool, timestamp int64) *PolicyReport {
+	resource := unstructured.Unstructured{}
+	resource.SetUID(types.UID(name + "-uid"))
+	resource.SetName(name)
+	resource.SetNamespace("namespace")
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetResourceVersion("1")
+
+	policy := &policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "namespace", UID: "policy-uid", ResourceVersion: "1"},
+	}
+	admissionReview := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: allowed},
+	}
+
+	policyReport := NewPolicyReport(runUID, resource)
+	policyReport.AddResult(policy, admissionReview, false)
+	policyReport.report.Results[0].Timestamp = metav1.Timestamp{Seconds: timestamp}
+	return policyReport
+}
+
+func TestReportWriterSkipsUnchangedReports(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	store := NewReportStoreOfKind(ReportKindPolicyReport, fakeClient, WriterConfig{QPS: 100, SkipUnchanged: true}, slog.Default())
+
+	// the first run
+	for _, name := range []string{"unchanged", "changed", "deleted"} {
+		require.NoError(t, store.CreateOrPatchReport(t.Context(), newWriterTestReport("run1", name, true, 1)))
+	}
+	require.NoError(t, store.DeleteOldReports(t.Context(), "run1", "namespace"))
+	unchangedReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "unchanged-uid", Namespace: "namespace"}, unchangedReport))
+
+	// the second run, the results of a resource changed and a resource is
+	// not audited anymore
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newWriterTestReport("run2", "unchanged", true, 2)))
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newWriterTestReport("run2", "changed", false, 2)))
+	require.NoError(t, store.DeleteOldReports(t.Context(), "run2", "namespace"))
+
+	// the unchanged report is not written, only its run UID label is
+	// patched, so it's not deleted
+	storedReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "unchanged-uid", Namespace: "namespace"}, storedReport))
+	assert.Equal(t, "run2", storedReport.Labels[auditConstants.AuditScannerRunUIDLabel])
+	assert.Equal(t, unchangedReport.Annotations, storedReport.Annotations)
+	assert.Equal(t, unchangedReport.Results, storedReport.Results)
+	assert.Equal(t, int64(1), storedReport.Results[0].Timestamp.Seconds)
+
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "changed-uid", Namespace: "namespace"}, storedReport))
+	assert.Equal(t, "run2", storedReport.Labels[auditConstants.AuditScannerRunUIDLabel])
+	assert.Equal(t, 1, storedReport.Summary.Fail)
+
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: "deleted-uid", Namespace: "namespace"}, storedReport)
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestReportWriterUnchangedReportsDeletedByAnotherStore(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	writerConfig := WriterConfig{SkipUnchanged: true}
+	require.NoError(t, NewPolicyReportStore(fakeClient, writerConfig, slog.Default()).CreateOrPatchReport(t.Context(), newWriterTestReport("run1", "pod", true, 1)))
+
+	// a resumed run, or a shard, skips the unchanged report, and another
+	// process deletes the old reports
+	store := NewPolicyReportStore(fakeClient, writerConfig, slog.Default())
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), newWriterTestReport("run2", "pod", true, 2)))
+	require.NoError(t, NewPolicyReportStore(fakeClient, WriterConfig{}, slog.Default()).DeleteOldReports(t.Context(), "run2", "namespace"))
+
+	storedReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "pod-uid", Namespace: "namespace"}, storedReport))
+	assert.Equal(t, "run2", storedReport.Labels[auditConstants.AuditScannerRunUIDLabel])
+
+	// the report already has the run UID, it's not patched again
+	require.NoError(t, NewPolicyReportStore(fakeClient, writerConfig, slog.Default()).CreateOrPatchReport(t.Context(), newWriterTestReport("run2", "pod", true, 3)))
+	relabeledReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "pod-uid", Namespace: "namespace"}, relabeledReport))
+	assert.Equal(t, storedReport.ResourceVersion, relabeledReport.ResourceVersion)
+}
+
+func TestReportWriterReplacesPatchedReport(t *testing.T) {
+	// a report written with a patch, by the previous versions of the scanner
+	patchedReport := newWriterTestReport("run1", "pod", false, 1).report
+	fakeClient, err := testutils.NewFakeClient(patchedReport)
+	require.NoError(t, err)
+	store := NewPolicyReportStore(fakeClient, WriterConfig{}, slog.Default())
+
+	policyReport := newWriterTestReport("run2", "pod", true, 2)
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), policyReport))
+
+	storedReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "pod-uid", Namespace: "namespace"}, storedReport))
+	assert.Equal(t, wgpolicy.PolicyReportSummary{Pass: 1}, storedReport.Summary)
+	assert.Equal(t, policyReport.report.Results, storedReport.Results)
+	assert.NotEmpty(t, storedReport.Annotations[annotationResultsHash])
+}
@@ -1,14 +1,24 @@
 package scanner
 
 import (
+	"net/http"
+
 	admv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-func newAdmissionRequest(resource unstructured.Unstructured) *admv1.AdmissionRequest {
+// newAdmissionRequest builds the admission request used to audit the resource,
+// listed from the given GroupVersionResource. The request is a dry run
+// performed by the given user, so policies can recognize the audit requests.
+// When the operation is UPDATE, the old object is the resource itself: the
+// policy evaluates the resource as if it had been updated without changes.
+func newAdmissionRequest(resource unstructured.Unstructured, gvr schema.GroupVersionResource, operation admv1.Operation, userInfo authenticationv1.UserInfo) *admv1.AdmissionRequest {
 	groupVersionKind := resource.GroupVersionKind()
+	dryRun := true
 	request := admv1.AdmissionRequest{
 		UID:  resource.GetUID(),
 		Name: resource.GetName(),
@@ -18,29 +28,82 @@ func newAdmissionRequest(resource unstructured.Unstructured) *admv1.AdmissionReq
 			Kind:    groupVersionKind.Kind,
 		},
 		Resource: metav1.GroupVersionResource{
-			Group:    groupVersionKind.Group,
-			Version:  groupVersionKind.Version,
-			Resource: groupVersionKind.Kind,
+			Group:    gvr.Group,
+			Version:  gvr.Version,
+			Resource: gvr.Resource,
 		},
 		RequestKind: &metav1.GroupVersionKind{
 			Group:   groupVersionKind.Group,
 			Version: groupVersionKind.Version,
 			Kind:    groupVersionKind.Kind,
 		},
-		Operation: admv1.Create,
+		RequestResource: &metav1.GroupVersionResource{
+			Group:    gvr.Group,
+			Version:  gvr.Version,
+			Resource: gvr.Resource,
+		},
+		Operation: operation,
 		Namespace: resource.GetNamespace(),
+		UserInfo:  userInfo,
 		Object: runtime.RawExtension{
 			Object: resource.DeepCopyObject(),
 			Raw:    nil,
 		},
+		DryRun:  &dryRun,
+		Options: newOperationOptions(operation),
+	}
+	if operation == admv1.Update {
+		request.OldObject = runtime.RawExtension{
+			Object: resource.DeepCopyObject(),
+			Raw:    nil,
+		}
 	}
 	return &request
 }
 
-func newAdmissionReview(resource unstructured.Unstructured) *admv1.AdmissionReview {
-	admissionRequest := newAdmissionRequest(resource)
+// newOperationOptions returns the options of the operation, as the Kubernetes
+// API server sends them to the admission webhooks.
+func newOperationOptions(operation admv1.Operation) runtime.RawExtension {
+	dryRunAll := []string{metav1.DryRunAll}
+	var options runtime.Object
+	if operation == admv1.Update {
+		options = &metav1.UpdateOptions{
+			TypeMeta: metav1.TypeMeta{Kind: "UpdateOptions", APIVersion: metav1.SchemeGroupVersion.String()},
+			DryRun:   dryRunAll,
+		}
+	} else {
+		options = &metav1.CreateOptions{
+			TypeMeta: metav1.TypeMeta{Kind: "CreateOptions", APIVersion: metav1.SchemeGroupVersion.String()},
+			DryRun:   dryRunAll,
+		}
+	}
+
+	return runtime.RawExtension{
+		Object: options,
+		Raw:    nil,
+	}
+}
+
+func newAdmissionReview(resource unstructured.Unstructured, gvr schema.GroupVersionResource, operation admv1.Operation, userInfo authenticationv1.UserInfo) *admv1.AdmissionReview {
+	admissionRequest := newAdmissionRequest(resource, gvr, operation, userInfo)
 	return &admv1.AdmissionReview{
 		Request:  admissionRequest,
 		Response: nil,
 	}
 }
+
+// newErroredAdmissionReview returns an AdmissionReview carrying the given
+// error, so it ends in the message of the errored report result.
+func newErroredAdmissionReview(request *admv1.AdmissionRequest, err error) *admv1.AdmissionReview {
+	return &admv1.AdmissionReview{
+		Request: request,
+		Response: &admv1.AdmissionResponse{
+			UID:     request.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			},
+		},
+	}
+}
@@ -1,10 +1,13 @@
 package scanner
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/google/uuid"
 	admv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -15,6 +18,12 @@ const (
 	resourceNamespace = "testing-namespace"
 )
 
+var podGVR = schema.GroupVersionResource{
+	Group:    "",
+	Version:  "v1",
+	Resource: "pods",
+}
+
 func generateUnstructuredPodObject() unstructured.Unstructured {
 	groupVersionKind := schema.GroupVersionKind{
 		Group:   "core",
@@ -32,7 +41,7 @@ func generateUnstructuredPodObject() unstructured.Unstructured {
 
 func TestObjectInAdmissionRequest(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionRequest := newAdmissionRequest(obj)
+	admissionRequest := newAdmissionRequest(obj, podGVR, admv1.Create, authenticationv1.UserInfo{})
 
 	admReqObj := admissionRequest.Object.Object
 	if admReqObj.GetObjectKind().GroupVersionKind().Group != obj.GroupVersionKind().Group {
@@ -48,7 +57,7 @@ func TestObjectInAdmissionRequest(t *testing.T) {
 
 func TestBasicInfoInAdmissionRequest(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionRequest := newAdmissionRequest(obj)
+	admissionRequest := newAdmissionRequest(obj, podGVR, admv1.Create, authenticationv1.UserInfo{})
 
 	if admissionRequest.Kind.Group != obj.GroupVersionKind().Group {
 		t.Errorf("Group diverge")
@@ -65,13 +74,13 @@ func TestBasicInfoInAdmissionRequest(t *testing.T) {
 	if admissionRequest.Name != resourceName {
 		t.Errorf("Name diverge")
 	}
-	if admissionRequest.Resource.Group != obj.GroupVersionKind().Group {
+	if admissionRequest.Resource.Group != podGVR.Group {
 		t.Errorf("Resource Group diverge")
 	}
-	if admissionRequest.Resource.Resource != obj.GroupVersionKind().Kind {
+	if admissionRequest.Resource.Resource != podGVR.Resource {
 		t.Errorf("Resource diverge")
 	}
-	if admissionRequest.Resource.Version != obj.GroupVersionKind().Version {
+	if admissionRequest.Resource.Version != podGVR.Version {
 		t.Errorf("Resource version diverge")
 	}
 	if admissionRequest.Namespace != resourceNamespace {
@@ -82,9 +91,64 @@ func TestBasicInfoInAdmissionRequest(t *testing.T) {
 	}
 }
 
+func TestDryRunAdmissionRequest(t *testing.T) {
+	obj := generateUnstructuredPodObject()
+	userInfo := authenticationv1.UserInfo{
+		Username: "system:serviceaccount:kubewarden:audit-scanner",
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:kubewarden", "system:authenticated"},
+	}
+	admissionRequest := newAdmissionRequest(obj, podGVR, admv1.Create, userInfo)
+
+	if admissionRequest.DryRun == nil || !*admissionRequest.DryRun {
+		t.Errorf("DryRun should be true")
+	}
+	if !reflect.DeepEqual(admissionRequest.UserInfo, userInfo) {
+		t.Errorf("UserInfo diverge")
+	}
+	if admissionRequest.RequestResource == nil || admissionRequest.RequestResource.Resource != podGVR.Resource {
+		t.Errorf("RequestResource diverge")
+	}
+	options, ok := admissionRequest.Options.Object.(*metav1.CreateOptions)
+	if !ok {
+		t.Fatalf("Options should be CreateOptions")
+	}
+	if options.Kind != "CreateOptions" || !reflect.DeepEqual(options.DryRun, []string{metav1.DryRunAll}) {
+		t.Errorf("Options diverge")
+	}
+
+	updateRequest := newAdmissionRequest(obj, podGVR, admv1.Update, userInfo)
+	if _, ok := updateRequest.Options.Object.(*metav1.UpdateOptions); !ok {
+		t.Errorf("Options should be UpdateOptions")
+	}
+}
+
+func TestUpdateAdmissionRequest(t *testing.T) {
+	obj := generateUnstructuredPodObject()
+	admissionRequest := newAdmissionRequest(obj, podGVR, admv1.Update, authenticationv1.UserInfo{})
+
+	if admissionRequest.Operation != admv1.Update {
+		t.Errorf("Operation diverge")
+	}
+	if admissionRequest.OldObject.Object == nil {
+		t.Fatalf("OldObject should be set")
+	}
+	if !reflect.DeepEqual(admissionRequest.OldObject.Object, admissionRequest.Object.Object) {
+		t.Errorf("OldObject diverge from Object")
+	}
+}
+
+func TestCreateAdmissionRequestHasNoOldObject(t *testing.T) {
+	obj := generateUnstructuredPodObject()
+	admissionRequest := newAdmissionRequest(obj, podGVR, admv1.Create, authenticationv1.UserInfo{})
+
+	if admissionRequest.OldObject.Object != nil {
+		t.Errorf("OldObject should not be set")
+	}
+}
+
 func TestGetAdmissionReview(t *testing.T) {
 	obj := generateUnstructuredPodObject()
-	admissionReview := newAdmissionReview(obj)
+	admissionReview := newAdmissionReview(obj, podGVR, admv1.Create, authenticationv1.UserInfo{})
 
 	if admissionReview.Response != nil {
 		t.Fatalf("Response should not be set")
@@ -107,13 +171,13 @@ func TestGetAdmissionReview(t *testing.T) {
 	if admissionRequest.Name != resourceName {
 		t.Errorf("Name diverge")
 	}
-	if admissionRequest.Resource.Group != obj.GroupVersionKind().Group {
+	if admissionRequest.Resource.Group != podGVR.Group {
 		t.Errorf("Resource Group diverge")
 	}
-	if admissionRequest.Resource.Resource != obj.GroupVersionKind().Kind {
+	if admissionRequest.Resource.Resource != podGVR.Resource {
 		t.Errorf("Resource diverge")
 	}
-	if admissionRequest.Resource.Version != obj.GroupVersionKind().Version {
+	if admissionRequest.Resource.Version != podGVR.Version {
 		t.Errorf("Resource version diverge")
 	}
 	if admissionRequest.Namespace != resourceNamespace {
@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+)
+
+// startAggregatedReport starts the report aggregating the results of the
+// resources of the given namespace, or of the cluster-wide resources when
+// empty, when the reports have the namespace granularity.
+func (s *Scanner) startAggregatedReport(runUID, nsName string) {
+	if s.granularity != report.GranularityNamespace || s.disableStore {
+		return
+	}
+
+	// the reports of a run don't replace the ones of the previous run
+	// until the run is complete, the previous ones are deleted then
+	name := fmt.Sprintf("%s-%s", report.AggregatedReportName, runUID)
+	// the shards audit different cluster-wide resources, each one stores
+	// its own cluster report
+	if nsName == "" && s.shard.sharded() {
+		name = fmt.Sprintf("%s-shard-%d", name, s.shard.Index)
+	}
+	s.aggregatedReports.Store(nsName, report.NewAggregatedReport(s.reportStore, s.reportKind, runUID, nsName, name))
+}
+
+// finishAggregatedReport stores the parts of the aggregated report of the
+// given namespace, or of the cluster-wide resources when empty, if any. The
+// parts of an interrupted scan are not stored, the reports of the previous
+// runs are kept instead.
+func (s *Scanner) finishAggregatedReport(ctx context.Context, nsName string) {
+	aggregatedReport, found := s.aggregatedReports.LoadAndDelete(nsName)
+	if !found {
+		return
+	}
+	if ctx.Err() != nil {
+		s.logger.DebugContext(ctx, "scan interrupted, the aggregated report is not stored",
+			slog.String("ns", nsName))
+		return
+	}
+
+	if err := aggregatedReport.(*report.AggregatedReport).Flush(ctx); err != nil { //nolint:forcetypeassert // only AggregatedReports are stored
+		s.logger.ErrorContext(ctx, "error adding the aggregated report to store",
+			slog.String("error", err.Error()),
+			slog.String("ns", nsName))
+	}
+}
+
+// storeReport stores the report of the given resource. With the namespace
+// granularity, its results are added to the aggregated report of its
+// namespace, or of the cluster-wide resources, instead. The errors are only
+// logged, so the scan continues with the next resources.
+func (s *Scanner) storeReport(ctx context.Context, resourceReport report.Report, nsName string) {
+	var err error
+	if aggregatedReport, found := s.aggregatedReports.Load(nsName); found {
+		err = aggregatedReport.(*report.AggregatedReport).Add(resourceReport) //nolint:forcetypeassert // only AggregatedReports are stored
+	} else if nsName == "" {
+		err = s.reportStore.CreateOrPatchClusterReport(ctx, resourceReport)
+	} else {
+		err = s.reportStore.CreateOrPatchReport(ctx, resourceReport)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error adding report to store",
+			slog.String("error", err.Error()),
+			slog.String("ns", nsName))
+	}
+}
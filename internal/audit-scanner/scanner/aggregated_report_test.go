@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+func TestScanNamespaceWithNamespaceGranularity(t *testing.T) {
+	namespace1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "namespace1"}}
+	pod1 := newTestPod("pod1", "namespace1")
+	pod2 := newTestPod("pod2", "namespace1")
+
+	scanner, fakeClient, _ := newPodsTestScanner(t, []*corev1.Namespace{namespace1}, []*corev1.Pod{pod1, pod2})
+	scanner.granularity = report.GranularityNamespace
+
+	// the report of a resource stored by a previous run
+	previousReport := &wgpolicy.PolicyReport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      string(pod1.GetUID()),
+			Namespace: "namespace1",
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by":        "kubewarden",
+				"kubewarden.io/audit-scanner-run-uid": "previous-run",
+			},
+		},
+	}
+	require.NoError(t, fakeClient.Create(t.Context(), previousReport))
+
+	require.NoError(t, scanner.ScanNamespace(t.Context(), "namespace1", "run"))
+
+	namespaceReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "kubewarden-audit-run-0", Namespace: "namespace1"}, namespaceReport))
+	assert.Nil(t, namespaceReport.Scope)
+	require.Len(t, namespaceReport.Results, 2)
+	subjects := []string{namespaceReport.Results[0].Subjects[0].Name, namespaceReport.Results[1].Subjects[0].Name}
+	assert.ElementsMatch(t, []string{"pod1", "pod2"}, subjects)
+
+	// the reports of the single resources are not stored, the ones of the
+	// previous runs are deleted
+	assert.False(t, hasPolicyReport(t, fakeClient, pod1))
+	assert.False(t, hasPolicyReport(t, fakeClient, pod2))
+}
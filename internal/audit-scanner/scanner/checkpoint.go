@@ -0,0 +1,248 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkpointInterval is the minimum interval between two saves of the
+// progress of a run. The progress made since the last save is lost when the
+// scanner crashes.
+const checkpointInterval = 10 * time.Second
+
+// checkpointer saves the progress of a scan run in a checkpoint, so a
+// restarted scanner resumes the run instead of starting a new one. The nil
+// checkpointer saves nothing.
+type checkpointer struct {
+	// mutex guards the progress, it's never held while the progress is
+	// being stored
+	mutex sync.Mutex
+	// saveMutex serializes the saves, so an older progress never overwrites
+	// a newer one. It guards lastSave
+	saveMutex sync.Mutex
+	store     report.CheckpointStore
+	// namespace where the checkpoint is stored
+	namespace  string
+	checkpoint report.Checkpoint
+	// maxAge is the age of the runs past which they are not resumed
+	// anymore, 0 means no limit
+	maxAge time.Duration
+	// dirty is true when the progress has not been saved yet
+	dirty    bool
+	lastSave time.Time
+	logger   *slog.Logger
+}
+
+func newCheckpointer(store report.CheckpointStore, namespace, name string, maxAge time.Duration, logger *slog.Logger) *checkpointer {
+	return &checkpointer{
+		store:      store,
+		namespace:  namespace,
+		checkpoint: report.Checkpoint{Name: name},
+		maxAge:     maxAge,
+		logger:     logger,
+	}
+}
+
+// StartRun returns the UID of the run to scan. When the checkpoint of an
+// interrupted run is stored, the run is resumed: its UID is returned, and the
+// namespaces and the resources already audited are skipped. Otherwise, a new
+// run is started, with the given UID or a random one when empty. The
+// checkpoint of a run with a different UID than the given one, or older than
+// the maximum checkpoint age, is discarded.
+func (s *Scanner) StartRun(ctx context.Context, runUID string) (string, error) {
+	if s.checkpoints == nil {
+		if runUID == "" {
+			runUID = uuid.New().String()
+		}
+		return runUID, nil
+	}
+
+	runUID, resumed, err := s.checkpoints.start(ctx, runUID)
+	if err != nil {
+		return "", err
+	}
+	if resumed {
+		s.startRunSummary(runUID).markResumed()
+	}
+	return runUID, nil
+}
+
+func (c *checkpointer) start(ctx context.Context, runUID string) (string, bool, error) {
+	stored, err := c.store.GetCheckpoint(ctx, c.namespace, c.checkpoint.Name)
+	if err != nil && !errors.Is(err, constants.ErrResourceNotFound) {
+		return "", false, fmt.Errorf("failed to get the checkpoint: %w", err)
+	}
+
+	resumable := err == nil && (runUID == "" || runUID == stored.RunUID)
+	// the results audited long ago would be stale, start a new run instead
+	if resumable && c.maxAge > 0 && time.Since(stored.StartTime.Time) > c.maxAge {
+		c.logger.InfoContext(ctx, "discarding the checkpoint of a run older than the maximum checkpoint age",
+			slog.String("RunUID", stored.RunUID),
+			slog.Time("start-time", stored.StartTime.Time),
+			slog.Duration("max-age", c.maxAge))
+		resumable = false
+	}
+
+	c.mutex.Lock()
+	if resumable {
+		c.checkpoint = *stored
+		c.mutex.Unlock()
+		c.logger.InfoContext(ctx, "resuming the interrupted run", slog.String("RunUID", stored.RunUID))
+		return stored.RunUID, true, nil
+	}
+	if runUID == "" {
+		runUID = uuid.New().String()
+	}
+	c.checkpoint = report.Checkpoint{Name: c.checkpoint.Name, RunUID: runUID, StartTime: metav1.Now()}
+	c.dirty = true
+	c.mutex.Unlock()
+
+	return runUID, false, c.save(ctx, true)
+}
+
+// resources returns the progress of the resources of the given namespace, or
+// of the cluster-wide resources when empty. It must be called with the mutex
+// held.
+func (c *checkpointer) resources(nsName string) *report.ResourcesProgress {
+	if nsName == "" {
+		return &c.checkpoint.ClusterWide
+	}
+	if c.checkpoint.Namespaces == nil {
+		c.checkpoint.Namespaces = map[string]*report.ResourcesProgress{}
+	}
+	progress, found := c.checkpoint.Namespaces[nsName]
+	if !found {
+		progress = &report.ResourcesProgress{}
+		c.checkpoint.Namespaces[nsName] = progress
+	}
+	return progress
+}
+
+// isCompleted returns true when all the resources of the given namespace, or
+// the cluster-wide resources when empty, have been audited.
+func (c *checkpointer) isCompleted(nsName string) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.resources(nsName).Completed
+}
+
+// resourceState returns whether the resources of the given GVR have been
+// audited, and otherwise the continue token of the next page to audit.
+func (c *checkpointer) resourceState(nsName, gvr string) (bool, string) {
+	if c == nil {
+		return false, ""
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	progress := c.resources(nsName)
+	return slices.Contains(progress.CompletedResources, gvr), progress.Continue[gvr]
+}
+
+// setContinue records that the resources of the given GVR have been audited
+// up to the page of the given continue token, or all of them when empty.
+func (c *checkpointer) setContinue(ctx context.Context, nsName, gvr, continueToken string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	progress := c.resources(nsName)
+	if continueToken == "" {
+		delete(progress.Continue, gvr)
+		progress.CompletedResources = append(progress.CompletedResources, gvr)
+	} else {
+		if progress.Continue == nil {
+			progress.Continue = map[string]string{}
+		}
+		progress.Continue[gvr] = continueToken
+	}
+	c.dirty = true
+	c.mutex.Unlock()
+
+	c.trySave(ctx)
+}
+
+// complete records that all the resources of the given namespace, or the
+// cluster-wide resources when empty, have been audited.
+func (c *checkpointer) complete(ctx context.Context, nsName string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	*c.resources(nsName) = report.ResourcesProgress{Completed: true}
+	c.dirty = true
+	c.mutex.Unlock()
+
+	c.trySave(ctx)
+}
+
+// finish saves the checkpoint of an incomplete run, so it can be resumed, and
+// deletes the checkpoint of a complete run.
+func (c *checkpointer) finish(ctx context.Context, incomplete bool) error {
+	if c == nil {
+		return nil
+	}
+	if incomplete {
+		return c.save(ctx, true)
+	}
+	if err := c.store.DeleteCheckpoint(ctx, c.namespace, c.checkpoint.Name); err != nil {
+		return fmt.Errorf("failed to delete the checkpoint: %w", err)
+	}
+	return nil
+}
+
+// trySave saves the progress, unless it has been saved recently. The errors
+// are only logged, the progress is saved again later.
+func (c *checkpointer) trySave(ctx context.Context) {
+	if err := c.save(ctx, false); err != nil {
+		c.logger.WarnContext(ctx, "failed to save the checkpoint", slog.String("error", err.Error()))
+	}
+}
+
+// save stores the progress not saved yet, at most once every
+// checkpointInterval unless forced. The progress is copied under the mutex
+// and stored after releasing it, so the workers are not blocked by the
+// write. The unforced saves are skipped while another save is in progress.
+func (c *checkpointer) save(ctx context.Context, force bool) error {
+	if force {
+		c.saveMutex.Lock()
+	} else if !c.saveMutex.TryLock() {
+		return nil
+	}
+	defer c.saveMutex.Unlock()
+
+	if !force && time.Since(c.lastSave) < checkpointInterval {
+		return nil
+	}
+	c.mutex.Lock()
+	if !c.dirty {
+		c.mutex.Unlock()
+		return nil
+	}
+	checkpoint := c.checkpoint.DeepCopy()
+	c.dirty = false
+	c.mutex.Unlock()
+
+	if err := c.store.CreateOrPatchCheckpoint(ctx, c.namespace, checkpoint); err != nil {
+		c.mutex.Lock()
+		c.dirty = true
+		c.mutex.Unlock()
+		return fmt.Errorf("failed to save the checkpoint: %w", err)
+	}
+	c.lastSave = time.Now()
+	return nil
+}
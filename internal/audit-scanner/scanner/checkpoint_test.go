@@ -0,0 +1,243 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	testingclient "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const checkpointName = "audit-scanner-checkpoint-policyreport"
+
+// newCheckpointTestScanner returns a scanner auditing the pods of the given
+// namespaces with a policy, saving its progress in a checkpoint.
+func newCheckpointTestScanner(t *testing.T, namespaces []*corev1.Namespace, pods []*corev1.Pod, checkpoint *report.Checkpoint) (*Scanner, client.Client, *dynamicFake.FakeDynamicClient) {
+	t.Helper()
+
+	config, fakeClient, dynamicClient := newPodsTestConfig(t, namespaces, pods)
+	configMapStore := report.NewConfigMapStore(fakeClient, slog.Default())
+	if checkpoint != nil {
+		require.NoError(t, configMapStore.CreateOrPatchCheckpoint(t.Context(), "kubewarden", checkpoint))
+	}
+	config.RunSummaryNamespace = "kubewarden"
+	config.RunSummaryStore = configMapStore
+	config.CheckpointStore = configMapStore
+	config.CheckpointName = checkpointName
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	return scanner, fakeClient, dynamicClient
+}
+
+func TestScanAllNamespacesResumed(t *testing.T) {
+	namespace1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "namespace1"}}
+	namespace2 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "namespace2"}}
+	pod1 := newTestPod("pod1", "namespace1")
+	pod2 := newTestPod("pod2", "namespace2")
+
+	// namespace1 was scanned before the scanner was restarted
+	scanner, fakeClient, _ := newCheckpointTestScanner(t, []*corev1.Namespace{namespace1, namespace2}, []*corev1.Pod{pod1, pod2}, &report.Checkpoint{
+		Name:   checkpointName,
+		RunUID: "interrupted-run",
+		Namespaces: map[string]*report.ResourcesProgress{
+			"namespace1": {Completed: true},
+		},
+	})
+
+	runUID, err := scanner.StartRun(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "interrupted-run", runUID)
+
+	require.NoError(t, scanner.ScanAllNamespaces(t.Context(), runUID))
+	assert.False(t, hasPolicyReport(t, fakeClient, pod1))
+	assert.True(t, hasPolicyReport(t, fakeClient, pod2))
+
+	summary, err := scanner.FinishRun(t.Context(), runUID)
+	require.NoError(t, err)
+	assert.True(t, summary.Resumed)
+	assert.Equal(t, []string{"namespace2"}, summary.Namespaces)
+
+	// the run is complete, the next run is a new one
+	_, err = scanner.checkpoints.store.GetCheckpoint(t.Context(), "kubewarden", checkpointName)
+	require.ErrorIs(t, err, auditConstants.ErrResourceNotFound)
+}
+
+func TestStartRunDiscardsCheckpointOfOtherRun(t *testing.T) {
+	scanner, _, _ := newCheckpointTestScanner(t, nil, nil, &report.Checkpoint{
+		Name:   checkpointName,
+		RunUID: "other-run",
+	})
+
+	runUID, err := scanner.StartRun(t.Context(), "new-run")
+	require.NoError(t, err)
+	assert.Equal(t, "new-run", runUID)
+
+	checkpoint, err := scanner.checkpoints.store.GetCheckpoint(t.Context(), "kubewarden", checkpointName)
+	require.NoError(t, err)
+	assert.Equal(t, "new-run", checkpoint.RunUID)
+	assert.Empty(t, checkpoint.Namespaces)
+}
+
+func TestStartRunDiscardsExpiredCheckpoint(t *testing.T) {
+	tests := []struct {
+		name      string
+		startTime time.Time
+		resumed   bool
+	}{
+		{"recent run", time.Now().Add(-time.Hour), true},
+		{"run older than the maximum age", time.Now().Add(-48 * time.Hour), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scanner, _, _ := newCheckpointTestScanner(t, nil, nil, &report.Checkpoint{
+				Name:      checkpointName,
+				RunUID:    "interrupted-run",
+				StartTime: metav1.NewTime(test.startTime),
+			})
+			scanner.checkpoints.maxAge = 24 * time.Hour
+
+			runUID, err := scanner.StartRun(t.Context(), "")
+			require.NoError(t, err)
+			assert.Equal(t, test.resumed, runUID == "interrupted-run")
+
+			checkpoint, err := scanner.checkpoints.store.GetCheckpoint(t.Context(), "kubewarden", checkpointName)
+			require.NoError(t, err)
+			assert.Equal(t, runUID, checkpoint.RunUID)
+		})
+	}
+}
+
+// blockingCheckpointStore blocks the saves of the checkpoints until
+// released.
+type blockingCheckpointStore struct {
+	report.CheckpointStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingCheckpointStore) CreateOrPatchCheckpoint(ctx context.Context, namespace string, checkpoint *report.Checkpoint) error {
+	s.saving <- struct{}{}
+	<-s.release
+	return s.CheckpointStore.CreateOrPatchCheckpoint(ctx, namespace, checkpoint)
+}
+
+func TestCheckpointSaveDoesNotBlockProgress(t *testing.T) {
+	scanner, fakeClient, _ := newCheckpointTestScanner(t, nil, nil, nil)
+	runUID, err := scanner.StartRun(t.Context(), "")
+	require.NoError(t, err)
+
+	store := &blockingCheckpointStore{
+		CheckpointStore: report.NewConfigMapStore(fakeClient, slog.Default()),
+		saving:          make(chan struct{}),
+		release:         make(chan struct{}),
+	}
+	scanner.checkpoints.store = store
+	scanner.checkpoints.complete(t.Context(), "namespace1")
+
+	saved := make(chan error)
+	go func() {
+		saved <- scanner.checkpoints.save(t.Context(), true)
+	}()
+	<-store.saving
+
+	// the progress is recorded while the checkpoint is being stored
+	scanner.checkpoints.complete(t.Context(), "namespace2")
+	assert.True(t, scanner.checkpoints.isCompleted("namespace2"))
+
+	close(store.release)
+	require.NoError(t, <-saved)
+
+	// the stored checkpoint is the snapshot taken before the write
+	checkpoint, err := store.GetCheckpoint(t.Context(), "kubewarden", checkpointName)
+	require.NoError(t, err)
+	assert.Equal(t, runUID, checkpoint.RunUID)
+	assert.Contains(t, checkpoint.Namespaces, "namespace1")
+	assert.NotContains(t, checkpoint.Namespaces, "namespace2")
+
+	// the progress recorded during the write is saved by the next one
+	go func() {
+		saved <- scanner.checkpoints.save(t.Context(), true)
+	}()
+	<-store.saving
+	require.NoError(t, <-saved)
+	checkpoint, err = store.GetCheckpoint(t.Context(), "kubewarden", checkpointName)
+	require.NoError(t, err)
+	assert.Contains(t, checkpoint.Namespaces, "namespace2")
+}
+
+func TestScanNamespaceResumedFromContinueToken(t *testing.T) {
+	podsGVR := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+
+	tests := []struct {
+		name          string
+		continueToken string
+		auditedPod1   bool
+	}{
+		{"resumed from the saved page", "page2", false},
+		{"expired continue token", "expired", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			namespace1 := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "namespace1"}}
+			pod1 := newTestPod("pod1", "namespace1")
+			pod2 := newTestPod("pod2", "namespace1")
+
+			scanner, fakeClient, dynamicClient := newCheckpointTestScanner(t, []*corev1.Namespace{namespace1}, []*corev1.Pod{pod1, pod2}, &report.Checkpoint{
+				Name:   checkpointName,
+				RunUID: "interrupted-run",
+				Namespaces: map[string]*report.ResourcesProgress{
+					"namespace1": {Continue: map[string]string{podsGVR.String(): test.continueToken}},
+				},
+			})
+
+			// a page for each pod
+			dynamicClient.PrependReactor("list", "pods", func(action testingclient.Action) (bool, runtime.Object, error) {
+				listAction, ok := action.(testingclient.ListActionImpl)
+				require.True(t, ok)
+				toUnstructured := func(pod *corev1.Pod) unstructured.Unstructured {
+					object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+					require.NoError(t, err)
+					resource := unstructured.Unstructured{Object: object}
+					resource.SetAPIVersion("v1")
+					resource.SetKind("Pod")
+					return resource
+				}
+
+				list := &unstructured.UnstructuredList{}
+				list.SetAPIVersion("v1")
+				list.SetKind("PodList")
+				switch listAction.ListOptions.Continue {
+				case "":
+					list.Items = []unstructured.Unstructured{toUnstructured(pod1)}
+					list.SetContinue("page2")
+				case "page2":
+					list.Items = []unstructured.Unstructured{toUnstructured(pod2)}
+				default:
+					return true, nil, apimachineryErrors.NewResourceExpired("continue token expired")
+				}
+				return true, list, nil
+			})
+
+			runUID, err := scanner.StartRun(t.Context(), "")
+			require.NoError(t, err)
+			require.NoError(t, scanner.ScanNamespace(t.Context(), "namespace1", runUID))
+
+			assert.Equal(t, test.auditedPod1, hasPolicyReport(t, fakeClient, pod1))
+			assert.True(t, hasPolicyReport(t, fakeClient, pod2))
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen is returned when a PolicyServer is not sent requests because
+// its circuit breaker is open.
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker stops sending requests to a PolicyServer after too many
+// consecutive failures. Once open, the requests fail right away until the
+// cooldown is elapsed. Then a single request probes the PolicyServer, with
+// its retries, while the other ones keep failing for another cooldown: the
+// circuit closes when the probe succeeds.
+type circuitBreaker struct {
+	mutex sync.Mutex
+	// threshold is the number of consecutive failures opening the circuit
+	threshold int
+	// cooldown is how long the circuit stays open
+	cooldown time.Duration
+	// failures is the number of consecutive failures
+	failures int
+	// lastErr is the error of the last failure
+	lastErr error
+	// openedAt is when the circuit opened, or when the last probe was
+	// allowed. It's zero when the circuit is closed
+	openedAt time.Time
+}
+
+// allow returns an error when the request must not be sent. It returns true
+// when the request probes the PolicyServer: its retries are sent without
+// asking again, until the probe is recorded as a success or a failure.
+func (b *circuitBreaker) allow(now time.Time) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.openedAt.IsZero() {
+		return false, nil
+	}
+	if now.Sub(b.openedAt) < b.cooldown {
+		return false, fmt.Errorf("%w after %d consecutive failures, last error: %w", errCircuitOpen, b.failures, b.lastErr)
+	}
+	// let this request probe the PolicyServer. When the probe is never
+	// recorded, e.g. because it's canceled, another request probes it after
+	// another cooldown
+	b.openedAt = now
+	return true, nil
+}
+
+// success records a request that reached the PolicyServer.
+func (b *circuitBreaker) success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	b.lastErr = nil
+	b.openedAt = time.Time{}
+}
+
+// failure records a request that could not reach the PolicyServer. It returns
+// true when the failure opened the circuit.
+func (b *circuitBreaker) failure(err error, now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	b.lastErr = err
+	if !b.openedAt.IsZero() {
+		// a failed probe, the circuit stays open
+		b.openedAt = now
+		return false
+	}
+	if b.failures >= b.threshold {
+		b.openedAt = now
+		return true
+	}
+	return false
+}
+
+// circuitBreakers holds a circuitBreaker for each PolicyServer.
+type circuitBreakers struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	breakers  map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(threshold int, cooldown time.Duration) *circuitBreakers {
+	return &circuitBreakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  map[string]*circuitBreaker{},
+	}
+}
+
+// get returns the circuitBreaker of the given PolicyServer. It returns nil
+// when the circuit breakers are disabled.
+func (c *circuitBreakers) get(policyServer string) *circuitBreaker {
+	if c.threshold <= 0 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	breaker, ok := c.breakers[policyServer]
+	if !ok {
+		breaker = &circuitBreaker{threshold: c.threshold, cooldown: c.cooldown}
+		c.breakers[policyServer] = breaker
+	}
+	return breaker
+}
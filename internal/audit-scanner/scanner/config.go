@@ -2,16 +2,27 @@ package scanner
 
 import (
 	"log/slog"
+	"time"
 
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/output"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	authenticationv1 "k8s.io/api/authentication/v1"
 )
 
 type ParallelizationConfig struct {
-	ParallelNamespacesAudits int
-	ParallelResourcesAudits  int
-	PoliciesAudits           int
+	// ParallelResourcesAudits is the number of resources audited at the
+	// same time, shared by all the namespaces and the cluster-wide resources.
+	// It also bounds the namespaces scanned at the same time
+	ParallelResourcesAudits int
+	// MaxInflightRequests is the maximum number of requests sent to all the
+	// PolicyServers at the same time. Defaults to 100
+	MaxInflightRequests int
+	// MaxInflightRequestsPerPolicyServer is the maximum number of requests
+	// sent to a PolicyServer at the same time. 0 means no limit, besides
+	// MaxInflightRequests
+	MaxInflightRequestsPerPolicyServer int
 }
 
 type TLSConfig struct {
@@ -21,18 +32,77 @@ type TLSConfig struct {
 	ClientKeyFile  string
 }
 
+// PolicyServerRequestsConfig configures how the admission reviews are sent to
+// the PolicyServers.
+type PolicyServerRequestsConfig struct {
+	// Timeout of each request. Defaults to 10 seconds
+	Timeout time.Duration
+	// MaxRetries is the number of times a request failing with a retryable
+	// error is sent again: network errors, timeouts and the 429, 502, 503 and
+	// 504 status codes
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. It's doubled at
+	// each retry, up to MaxBackoff, and randomized with a jitter
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// CircuitBreakerThreshold is the number of consecutive failed requests
+	// after which no more requests are sent to a PolicyServer, the
+	// evaluations are errored right away. 0 disables the circuit breaker
+	CircuitBreakerThreshold int
+	// CircuitBreakerCooldown is how long a PolicyServer is not sent requests
+	// once its circuit breaker opens, before a request probes it again
+	CircuitBreakerCooldown time.Duration
+	// LoadBalancing is how the requests are distributed among the ready
+	// replicas of a PolicyServer. Defaults to round-robin
+	LoadBalancing LoadBalancing
+}
+
 type Config struct {
 	PoliciesClient *policies.Client
 	K8sClient      *k8s.Client
 
 	ReportStore report.Store
 	ReportKind  report.CrdKind
+	// ReportGranularity is the number of resources whose results are stored
+	// in a report. Defaults to a report per resource
+	ReportGranularity report.Granularity
+	// PolicyCentricReports stores a report per policy too, listing the
+	// resources failing the policy or whose evaluation errored
+	PolicyCentricReports bool
 
-	TLS             TLSConfig
-	Parallelization ParallelizationConfig
+	TLS                  TLSConfig
+	Parallelization      ParallelizationConfig
+	PolicyServerRequests PolicyServerRequestsConfig
 
-	OutputScan   bool
+	OutputScan bool
+	// Output writes the results of each audited resource, when not nil
+	Output       output.Writer
 	DisableStore bool
+	// Incremental reuses the results stored by previous scans when neither
+	// the resource nor the policy changed since then
+	Incremental bool
+	// UserInfo is the user performing the admission requests sent to the
+	// policies, policies can use it to recognize the audit requests
+	UserInfo authenticationv1.UserInfo
+	// RunSummaryNamespace is the namespace where the summary of each scan run
+	// is stored. When empty, the summary is only logged
+	RunSummaryNamespace string
+	// RunSummaryStore stores the summary of each scan run. When nil, the
+	// summary is only logged
+	RunSummaryStore report.RunSummaryStore
+	// Shard is the part of the audit done by this scanner, when the audit is
+	// split among several replicas
+	Shard ShardConfig
+	// CheckpointName is the name of the ConfigMap where the progress of the
+	// runs is saved, so a restarted scanner resumes the interrupted run.
+	// When empty, the progress is not saved
+	CheckpointName string
+	// CheckpointStore stores the progress of the runs. When nil, the
+	// progress is not saved
+	CheckpointStore report.CheckpointStore
+	// CheckpointMaxAge is the age of the interrupted runs past which they
+	// are not resumed anymore, a new run is started instead. 0 means no limit
+	CheckpointMaxAge time.Duration
 
 	Logger *slog.Logger
 }
@@ -0,0 +1,210 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+)
+
+// LoadBalancing is how the requests are distributed among the replicas of a
+// PolicyServer.
+type LoadBalancing string
+
+const (
+	// LoadBalancingRoundRobin sends the requests to the ready replicas in turn.
+	LoadBalancingRoundRobin LoadBalancing = "round-robin"
+	// LoadBalancingLeastOutstanding sends each request to the ready replica
+	// with the fewest requests in flight.
+	LoadBalancingLeastOutstanding LoadBalancing = "least-outstanding"
+	// LoadBalancingService sends the requests to the PolicyServer Service,
+	// with a new connection for each request, so kube-proxy spreads them.
+	LoadBalancingService LoadBalancing = "service"
+)
+
+// SupportedLoadBalancings returns the supported load balancing strategies.
+func SupportedLoadBalancings() []LoadBalancing {
+	return []LoadBalancing{LoadBalancingRoundRobin, LoadBalancingLeastOutstanding, LoadBalancingService}
+}
+
+const (
+	// maxIdleConnsPerEndpoint is the number of keep-alive connections kept
+	// open with each PolicyServer replica, it matches the default number of
+	// parallel resources audits
+	maxIdleConnsPerEndpoint = 100
+	dialTimeout             = 30 * time.Second
+	dialKeepAlive           = 30 * time.Second
+)
+
+// policyServerEndpoint is a ready replica of a PolicyServer, with its own pool
+// of keep-alive connections.
+type policyServerEndpoint struct {
+	// address of the replica, in the host:port format
+	address    string
+	transport  *http.Transport
+	httpClient *http.Client
+	// outstanding is the number of requests in flight
+	outstanding atomic.Int64
+}
+
+// endpointPool holds the ready replicas of a PolicyServer.
+type endpointPool struct {
+	mutex sync.Mutex
+	// addresses are the sorted addresses of the endpoints
+	addresses []string
+	endpoints []*policyServerEndpoint
+	// next is the index of the next endpoint picked by round-robin
+	next int
+}
+
+// endpointBalancer distributes the requests sent to the PolicyServers among
+// their ready replicas. The requests keep the URL of the PolicyServer
+// Service, so the TLS certificate of the PolicyServer is verified as usual,
+// but the connections are opened with the picked replica.
+type endpointBalancer struct {
+	mutex    sync.Mutex
+	strategy LoadBalancing
+	// transport is cloned for each endpoint
+	transport *http.Transport
+	timeout   time.Duration
+	// pools holds the endpoints of each PolicyServer, by Service host
+	pools map[string]*endpointPool
+}
+
+func newEndpointBalancer(strategy LoadBalancing, transport *http.Transport, timeout time.Duration) *endpointBalancer {
+	if strategy == "" {
+		strategy = LoadBalancingRoundRobin
+	}
+	return &endpointBalancer{
+		strategy:  strategy,
+		transport: transport,
+		timeout:   timeout,
+		pools:     map[string]*endpointPool{},
+	}
+}
+
+// pick returns the endpoint the next request to the given PolicyServer is
+// sent to. The endpoints of the PolicyServer are refreshed when the given
+// addresses changed. It returns nil when the request must be sent to the
+// Service.
+func (b *endpointBalancer) pick(policyServerHost string, addresses []string) *policyServerEndpoint {
+	if b.strategy == LoadBalancingService || len(addresses) == 0 {
+		return nil
+	}
+
+	b.mutex.Lock()
+	pool, ok := b.pools[policyServerHost]
+	if !ok {
+		pool = &endpointPool{}
+		b.pools[policyServerHost] = pool
+	}
+	b.mutex.Unlock()
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	if !slices.Equal(pool.addresses, addresses) {
+		b.refresh(pool, addresses)
+	}
+
+	if b.strategy == LoadBalancingLeastOutstanding {
+		// start from the round-robin position, so the endpoints without
+		// requests in flight are picked in turn
+		picked := pool.endpoints[pool.next%len(pool.endpoints)]
+		for i := range pool.endpoints {
+			endpoint := pool.endpoints[(pool.next+i)%len(pool.endpoints)]
+			if endpoint.outstanding.Load() < picked.outstanding.Load() {
+				picked = endpoint
+			}
+		}
+		pool.next++
+		return picked
+	}
+
+	picked := pool.endpoints[pool.next%len(pool.endpoints)]
+	pool.next++
+	return picked
+}
+
+// pickEndpoint returns the replica the next request to the PolicyServer of
+// the given Service is sent to, or nil when it must be sent to the given URL.
+// The ready replicas are resolved at each request, through the short-lived
+// cache of the policies client, so the replicas of a rollout are followed
+// during the scan.
+func (s *Scanner) pickEndpoint(ctx context.Context, url *url.URL, service *policies.PolicyServerService) *policyServerEndpoint {
+	if service == nil || s.endpoints.strategy == LoadBalancingService {
+		return nil
+	}
+	addresses, err := s.policiesClient.GetPolicyServerEndpoints(ctx, service)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to resolve the PolicyServer endpoints, using its Service",
+			slog.String("error", err.Error()),
+			slog.String("service", service.Name))
+	}
+	return s.endpoints.pick(url.Host, addresses)
+}
+
+// isDialError returns true when the connection with a replica could not be
+// opened, e.g. because the replica was removed.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return (errors.As(err, &opErr) && opErr.Op == "dial") || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// refresh replaces the endpoints of the pool, keeping the connections of the
+// endpoints still ready and closing the ones of the removed endpoints.
+func (b *endpointBalancer) refresh(pool *endpointPool, addresses []string) {
+	endpoints := make([]*policyServerEndpoint, 0, len(addresses))
+	for _, address := range addresses {
+		index := slices.IndexFunc(pool.endpoints, func(endpoint *policyServerEndpoint) bool {
+			return endpoint.address == address
+		})
+		if index >= 0 {
+			endpoints = append(endpoints, pool.endpoints[index])
+			continue
+		}
+		endpoints = append(endpoints, b.newEndpoint(address))
+	}
+
+	for _, endpoint := range pool.endpoints {
+		if !slices.Contains(addresses, endpoint.address) {
+			endpoint.transport.CloseIdleConnections()
+		}
+	}
+
+	pool.addresses = slices.Clone(addresses)
+	pool.endpoints = endpoints
+}
+
+func (b *endpointBalancer) newEndpoint(address string) *policyServerEndpoint {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: dialKeepAlive,
+	}
+	transport := b.transport.Clone()
+	transport.DisableKeepAlives = false
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerEndpoint
+	// the connections are opened with the replica, whatever the host of
+	// the request URL
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return &policyServerEndpoint{
+		address:   address,
+		transport: transport,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   b.timeout,
+		},
+	}
+}
@@ -0,0 +1,179 @@
+package scanner
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newReplicaMockPolicyServer returns a PolicyServer replica counting its
+// requests. It closes the connections after each request, so the scanner
+// dials it for each request.
+func newReplicaMockPolicyServer(requests *atomic.Int32) *httptest.Server {
+	replica := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		allowedAdmissionReviewHandler(writer, r)
+	}))
+	replica.Config.SetKeepAlivesEnabled(false)
+	replica.Start()
+	return replica
+}
+
+// newReplicaEndpointSlice returns the EndpointSlice of the default
+// PolicyServer Service holding the given replica.
+func newReplicaEndpointSlice(t *testing.T, name string, replica *httptest.Server) *discoveryv1.EndpointSlice {
+	t.Helper()
+	address, ok := replica.Listener.Addr().(*net.TCPAddr)
+	require.True(t, ok)
+
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kubewarden",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "policy-server-default"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{address.IP.String()}}},
+		Ports:       []discoveryv1.EndpointPort{{Name: ptr.To("http"), Port: ptr.To(int32(address.Port))}},
+	}
+}
+
+// newEndpointsTestScanner returns a scanner resolving the replicas of the
+// default PolicyServer Service from the given EndpointSlices.
+func newEndpointsTestScanner(t *testing.T, endpointSlices ...runtime.Object) (*Scanner, client.Client) {
+	t.Helper()
+	fakeClient, err := testutils.NewFakeClient(endpointSlices...)
+	require.NoError(t, err)
+
+	scanner, err := NewScanner(Config{
+		PoliciesClient:       policies.NewClient(fakeClient, nil, "kubewarden", "", nil, slog.Default()),
+		PolicyServerRequests: PolicyServerRequestsConfig{LoadBalancing: LoadBalancingRoundRobin},
+		Logger:               slog.Default(),
+	})
+	require.NoError(t, err)
+	return scanner, fakeClient
+}
+
+var testPolicyServerService = &policies.PolicyServerService{Namespace: "kubewarden", Name: "policy-server-default", PortName: "http"}
+
+func TestSendAdmissionReviewToEndpoints(t *testing.T) {
+	requests1 := atomic.Int32{}
+	replica1 := newReplicaMockPolicyServer(&requests1)
+	defer replica1.Close()
+	requests2 := atomic.Int32{}
+	replica2 := newReplicaMockPolicyServer(&requests2)
+	defer replica2.Close()
+
+	// the Service host cannot be resolved, the requests must reach the replicas
+	policyServerURL, err := url.Parse("http://policy-server-default.kubewarden.svc.invalid:443/audit/policy")
+	require.NoError(t, err)
+
+	scanner, _ := newEndpointsTestScanner(t,
+		newReplicaEndpointSlice(t, "replica1", replica1),
+		newReplicaEndpointSlice(t, "replica2", replica2),
+	)
+	for range 4 {
+		_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, testPolicyServerService, &admissionv1.AdmissionReview{}, nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), requests1.Load())
+	assert.Equal(t, int32(2), requests2.Load())
+}
+
+func TestSendAdmissionReviewEndpointsChangeMidScan(t *testing.T) {
+	serviceRequests := atomic.Int32{}
+	service := newReplicaMockPolicyServer(&serviceRequests)
+	defer service.Close()
+	requests1 := atomic.Int32{}
+	replica1 := newReplicaMockPolicyServer(&requests1)
+	requests2 := atomic.Int32{}
+	replica2 := newReplicaMockPolicyServer(&requests2)
+	defer replica2.Close()
+	requests3 := atomic.Int32{}
+	replica3 := newReplicaMockPolicyServer(&requests3)
+	defer replica3.Close()
+
+	policyServerURL, err := url.Parse(service.URL + "/audit/policy")
+	require.NoError(t, err)
+	endpointSlice1 := newReplicaEndpointSlice(t, "replica1", replica1)
+	scanner, fakeClient := newEndpointsTestScanner(t, endpointSlice1, newReplicaEndpointSlice(t, "replica2", replica2))
+
+	for range 2 {
+		_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, testPolicyServerService, &admissionv1.AdmissionReview{}, nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), requests1.Load())
+	assert.Equal(t, int32(1), requests2.Load())
+
+	// a rollout replaces the first replica while the replicas are cached
+	replica1.Close()
+	require.NoError(t, fakeClient.Delete(t.Context(), endpointSlice1))
+	require.NoError(t, fakeClient.Create(t.Context(), newReplicaEndpointSlice(t, "replica3", replica3)))
+
+	for range 5 {
+		_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, testPolicyServerService, &admissionv1.AdmissionReview{}, nil)
+		require.NoError(t, err)
+	}
+	// the request picking the removed replica is sent to the Service, the
+	// next ones are sent to the replicas resolved again
+	assert.Equal(t, int32(1), requests1.Load())
+	assert.Equal(t, int32(1), serviceRequests.Load())
+	assert.Equal(t, int32(5), requests2.Load()+requests3.Load())
+	assert.Positive(t, requests3.Load())
+}
+
+func TestEndpointBalancerPick(t *testing.T) {
+	addresses := []string{"10.0.0.1:8443", "10.0.0.2:8443", "10.0.0.3:8443"}
+
+	t.Run("round-robin", func(t *testing.T) {
+		balancer := newEndpointBalancer(LoadBalancingRoundRobin, &http.Transport{}, time.Second)
+		picked := []string{}
+		for range 4 {
+			picked = append(picked, balancer.pick("policy-server", addresses).address)
+		}
+		assert.Equal(t, []string{"10.0.0.1:8443", "10.0.0.2:8443", "10.0.0.3:8443", "10.0.0.1:8443"}, picked)
+	})
+
+	t.Run("least-outstanding", func(t *testing.T) {
+		balancer := newEndpointBalancer(LoadBalancingLeastOutstanding, &http.Transport{}, time.Second)
+		// keep the requests in flight
+		for range 3 {
+			balancer.pick("policy-server", addresses).outstanding.Add(1)
+		}
+		// all the replicas have a request in flight, they are picked in turn
+		endpoint := balancer.pick("policy-server", addresses)
+		require.Equal(t, "10.0.0.1:8443", endpoint.address)
+		endpoint.outstanding.Add(1)
+
+		// the second replica completed its request
+		balancer.pools["policy-server"].endpoints[1].outstanding.Add(-1)
+
+		assert.Equal(t, "10.0.0.2:8443", balancer.pick("policy-server", addresses).address)
+	})
+
+	t.Run("service", func(t *testing.T) {
+		balancer := newEndpointBalancer(LoadBalancingService, &http.Transport{}, time.Second)
+		assert.Nil(t, balancer.pick("policy-server", addresses))
+	})
+
+	t.Run("no endpoints", func(t *testing.T) {
+		balancer := newEndpointBalancer(LoadBalancingRoundRobin, &http.Transport{}, time.Second)
+		assert.Nil(t, balancer.pick("policy-server", nil))
+	})
+}
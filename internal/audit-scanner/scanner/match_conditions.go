@@ -0,0 +1,96 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	celtypes "github.com/google/cel-go/common/types"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	admv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// matchesConditions returns true if all the matchConditions of the policy
+// evaluate to true for the given admission request, or if the policy has no
+// matchConditions. They are evaluated the same way the Kubernetes API server
+// does before sending a request to the policy webhook: a condition evaluating
+// to false takes precedence over evaluation errors. Any other error is
+// returned, the policy cannot be evaluated for the resource.
+func matchesConditions(ctx context.Context, policy *policies.Policy, request *admv1.AdmissionRequest) (bool, error) {
+	// the matchConditions are compiled by the policies client, which skips
+	// the policies whose expressions use the authorizer variable
+	evaluator := policy.MatchConditions
+	if evaluator == nil {
+		return true, nil
+	}
+	if compilationErrors := evaluator.CompilationErrors(); len(compilationErrors) > 0 {
+		return false, fmt.Errorf("failed to compile matchConditions: %w", errors.Join(compilationErrors...))
+	}
+
+	evalResults, _, err := evaluator.ForInput(ctx, newVersionedAttributes(request), request, plugincel.OptionalVariableBindings{}, nil, celconfig.RuntimeCELCostBudgetMatchConditions)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate matchConditions: %w", err)
+	}
+
+	var evalErrors []error
+	for _, evalResult := range evalResults {
+		if evalResult.Error != nil {
+			evalErrors = append(evalErrors, evalResult.Error)
+			continue
+		}
+		if evalResult.EvalResult == celtypes.False {
+			return false, nil
+		}
+	}
+	if len(evalErrors) > 0 {
+		return false, fmt.Errorf("failed to evaluate matchConditions: %w", errors.Join(evalErrors...))
+	}
+
+	return true, nil
+}
+
+// newVersionedAttributes builds the admission attributes bound to the
+// "object" and "oldObject" CEL variables from the synthesized admission request.
+func newVersionedAttributes(request *admv1.AdmissionRequest) *admission.VersionedAttributes {
+	kind := schema.GroupVersionKind{
+		Group:   request.Kind.Group,
+		Version: request.Kind.Version,
+		Kind:    request.Kind.Kind,
+	}
+	resource := schema.GroupVersionResource{
+		Group:    request.Resource.Group,
+		Version:  request.Resource.Version,
+		Resource: request.Resource.Resource,
+	}
+	dryRun := request.DryRun != nil && *request.DryRun
+
+	attributes := admission.NewAttributesRecord(
+		request.Object.Object,
+		request.OldObject.Object,
+		kind,
+		request.Namespace,
+		request.Name,
+		resource,
+		request.SubResource,
+		admission.Operation(request.Operation),
+		request.Options.Object,
+		dryRun,
+		&user.DefaultInfo{
+			Name:   request.UserInfo.Username,
+			UID:    request.UserInfo.UID,
+			Groups: request.UserInfo.Groups,
+		},
+	)
+
+	return &admission.VersionedAttributes{
+		Attributes:         attributes,
+		VersionedKind:      kind,
+		VersionedObject:    request.Object.Object,
+		VersionedOldObject: request.OldObject.Object,
+	}
+}
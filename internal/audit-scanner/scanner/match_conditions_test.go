@@ -0,0 +1,105 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestMatchConditions(t *testing.T) {
+	tests := []struct {
+		name            string
+		matchConditions map[string]string
+		expectedMatch   bool
+		expectedErr     bool
+	}{
+		{
+			name:            "no matchConditions",
+			matchConditions: map[string]string{},
+			expectedMatch:   true,
+		},
+		{
+			name: "all matchConditions are true",
+			matchConditions: map[string]string{
+				"is-pod":       "object.kind == 'Pod'",
+				"is-namespace": "request.namespace == 'testing-namespace'",
+			},
+			expectedMatch: true,
+		},
+		{
+			name: "dry run request for the pods resource",
+			matchConditions: map[string]string{
+				"is-pods": "request.resource.resource == 'pods'",
+				"dry-run": "request.dryRun",
+			},
+			expectedMatch: true,
+		},
+		{
+			name: "one matchCondition is false",
+			matchConditions: map[string]string{
+				"is-pod":          "object.kind == 'Pod'",
+				"is-kube-system":  "request.namespace == 'kube-system'",
+				"has-no-metadata": "!has(object.metadata)",
+			},
+			expectedMatch: false,
+		},
+		{
+			name: "false takes precedence over errors",
+			matchConditions: map[string]string{
+				"is-kube-system": "request.namespace == 'kube-system'",
+				"runtime-error":  "object.spec.containers.size() > 0",
+			},
+			expectedMatch: false,
+		},
+		{
+			name: "evaluation error",
+			matchConditions: map[string]string{
+				"runtime-error": "object.spec.containers.size() > 0",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "compilation error",
+			matchConditions: map[string]string{
+				"not-boolean": "object.metadata.name",
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory := testutils.NewClusterAdmissionPolicyFactory().
+				Name("policy").
+				Rule(admissionregistrationv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"pods"},
+				})
+			for name, expression := range test.matchConditions {
+				factory.MatchCondition(name, expression)
+			}
+			policy := factory.Build()
+
+			policyToUse := &policies.Policy{
+				Policy:          policy,
+				MatchConditions: policies.CompileMatchConditions(policy),
+			}
+			admissionRequest := newAdmissionRequest(generateUnstructuredPodObject(), podGVR, admv1.Create, authenticationv1.UserInfo{})
+
+			matches, err := matchesConditions(t.Context(), policyToUse, admissionRequest)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedMatch, matches)
+		})
+	}
+}
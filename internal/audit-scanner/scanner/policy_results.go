@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// policyResultsCollector aggregates the failed and errored results of a scan
+// run in a report per policy, the policy-centric view of the run. It's shared
+// by all the workers of the run.
+type policyResultsCollector struct {
+	mutex sync.Mutex
+	// reports holds the reports of the policies, by policy unique name
+	reports map[string]*report.AggregatedReport
+}
+
+// getPolicyResults returns the collector of the given run, creating it when
+// this is the first result of the run. It returns nil when the policy-centric
+// reports are disabled.
+func (s *Scanner) getPolicyResults(runUID string) *policyResultsCollector {
+	if !s.policyCentricReports || s.disableStore {
+		return nil
+	}
+
+	collector, _ := s.policyResults.LoadOrStore(runUID, &policyResultsCollector{
+		reports: map[string]*report.AggregatedReport{},
+	})
+
+	return collector.(*policyResultsCollector) //nolint:forcetypeassert // only policyResultsCollectors are stored
+}
+
+// addPolicyResults adds the failed and errored results of the audit of the
+// given resource to the reports of their policies.
+func (s *Scanner) addPolicyResults(runUID string, resource unstructured.Unstructured, results []policyAuditResult) error {
+	collector := s.getPolicyResults(runUID)
+	if collector == nil {
+		return nil
+	}
+
+	var err error
+	for _, result := range results {
+		if !result.errored && result.admissionReviewResponse.Response.Allowed {
+			continue
+		}
+		policyResultsReport := collector.get(s, runUID, result)
+		if addErr := policyResultsReport.AddPolicyResult(result.policy, resource, result.admissionReviewResponse, result.errored); addErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to add the result of policy %s: %w", result.policy.GetUniqueName(), addErr))
+		}
+	}
+	return err
+}
+
+// get returns the report of the policy of the given result, creating it when
+// this is the first result of the policy.
+func (c *policyResultsCollector) get(s *Scanner, runUID string, result policyAuditResult) *report.AggregatedReport {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name := result.policy.GetUniqueName()
+	policyResultsReport, found := c.reports[name]
+	if !found {
+		// the shards audit the resources of different namespaces, each
+		// one stores its own reports
+		var nameSuffix string
+		if s.shard.sharded() {
+			nameSuffix = fmt.Sprintf("-shard-%d", s.shard.Index)
+		}
+		policyResultsReport = report.NewPolicyResultsReport(s.reportStore, s.reportKind, runUID, result.policy, nameSuffix)
+		c.reports[name] = policyResultsReport
+	}
+	return policyResultsReport
+}
+
+// finishPolicyResults stores the reports of the policies of the given run,
+// then deletes the ones of the previous runs. They are not stored when the run
+// is incomplete, the reports would miss the resources not audited yet, and the
+// reports of the previous runs are kept instead. The reports of the previous
+// runs are kept too when the reports of the run are not all stored.
+func (s *Scanner) finishPolicyResults(ctx context.Context, runUID string, incomplete bool) error {
+	value, found := s.policyResults.LoadAndDelete(runUID)
+	if incomplete || !s.policyCentricReports || s.disableStore {
+		return nil
+	}
+
+	if found {
+		collector := value.(*policyResultsCollector) //nolint:forcetypeassert // only policyResultsCollectors are stored
+		if err := collector.flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	if s.policiesClient.Filtered() {
+		s.logger.InfoContext(ctx, "filtered audit, keeping the reports of the policies of the previous runs")
+		return nil
+	}
+	if s.shard.sharded() {
+		s.logger.DebugContext(ctx, "sharded audit, the reports of the policies of the previous runs are deleted by the coordinator")
+		return nil
+	}
+	if err := s.reportStore.DeleteOldPolicyResultsReports(ctx, runUID); err != nil {
+		return fmt.Errorf("failed to delete the old reports of the policies: %w", err)
+	}
+	return nil
+}
+
+// flush stores the reports of the policies.
+func (c *policyResultsCollector) flush(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var err error
+	for name, policyResultsReport := range c.reports {
+		if flushErr := policyResultsReport.Flush(ctx); flushErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to store the report of policy %s: %w", name, flushErr))
+		}
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+func TestPolicyCentricReports(t *testing.T) {
+	scanner, fakeClient, _ := newPodsTestScanner(t, nil, nil)
+	scanner.policyCentricReports = true
+
+	clusterPolicy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-policy", UID: "cluster-policy-uid"},
+	}
+	namespacedPolicy := &policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "namespace1", UID: "policy-uid"},
+	}
+	rejected := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "rejected"},
+		},
+	}
+	allowed := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: true},
+	}
+
+	// the reports of the policies stored by a previous run: the second part
+	// of the report of the cluster-wide policy, and the report of a policy
+	// whose resources don't fail anymore
+	previousClusterReport := testutils.NewClusterPolicyReportFactory().
+		Name("kubewarden-policy-clusterwide-cluster-policy-1").WithAppLabel().WithPolicyResultsLabel().RunUID("previous-run").Build()
+	previousReport := testutils.NewPolicyReportFactory().
+		Name("kubewarden-policy-namespaced-namespace2-policy-0").Namespace("namespace2").WithAppLabel().WithPolicyResultsLabel().RunUID("previous-run").Build()
+	require.NoError(t, fakeClient.Create(t.Context(), previousClusterReport))
+	require.NoError(t, fakeClient.Create(t.Context(), previousReport))
+
+	pod1 := *toUnstructured(t, newTestPod("pod1", "namespace1"), corev1.SchemeGroupVersion.WithKind("Pod"))
+	pod2 := *toUnstructured(t, newTestPod("pod2", "namespace1"), corev1.SchemeGroupVersion.WithKind("Pod"))
+	require.NoError(t, scanner.addPolicyResults("run", pod1, []policyAuditResult{
+		{policy: clusterPolicy, admissionReviewResponse: rejected},
+		{policy: namespacedPolicy, admissionReviewResponse: allowed},
+	}))
+	require.NoError(t, scanner.addPolicyResults("run", pod2, []policyAuditResult{
+		{policy: clusterPolicy, admissionReviewResponse: allowed},
+		{policy: namespacedPolicy, admissionReviewResponse: rejected, errored: true},
+	}))
+	require.NoError(t, scanner.finishPolicyResults(t.Context(), "run", false))
+
+	// the failed results of the cluster-wide policy
+	clusterReport := &wgpolicy.ClusterPolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "kubewarden-policy-clusterwide-cluster-policy-0"}, clusterReport))
+	assert.Equal(t, report.ReportTypePolicy, clusterReport.Labels[report.LabelReportType])
+	assert.Equal(t, "cluster-policy-uid", clusterReport.Labels[report.LabelPolicyUID])
+	require.Len(t, clusterReport.Results, 1)
+	assert.Equal(t, wgpolicy.PolicyResult("fail"), clusterReport.Results[0].Result)
+	assert.Equal(t, "rejected", clusterReport.Results[0].Description)
+	assert.Equal(t, "pod1", clusterReport.Results[0].Subjects[0].Name)
+	assert.Equal(t, "namespace1", clusterReport.Results[0].Subjects[0].Namespace)
+
+	// the errored results of the namespaced policy, in its namespace
+	namespacedReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "kubewarden-policy-namespaced-namespace1-policy-0", Namespace: "namespace1"}, namespacedReport))
+	require.Len(t, namespacedReport.Results, 1)
+	assert.Equal(t, wgpolicy.PolicyResult("error"), namespacedReport.Results[0].Result)
+	assert.Equal(t, "pod2", namespacedReport.Results[0].Subjects[0].Name)
+
+	// the reports of the previous run are deleted once the reports of the
+	// run are stored
+	err := fakeClient.Get(t.Context(), types.NamespacedName{Name: previousClusterReport.Name}, &wgpolicy.ClusterPolicyReport{})
+	assert.True(t, apimachineryErrors.IsNotFound(err))
+	err = fakeClient.Get(t.Context(), types.NamespacedName{Name: previousReport.Name, Namespace: "namespace2"}, &wgpolicy.PolicyReport{})
+	assert.True(t, apimachineryErrors.IsNotFound(err))
+}
+
+func TestPolicyCentricReportsOfIncompleteRun(t *testing.T) {
+	scanner, fakeClient, _ := newPodsTestScanner(t, nil, nil)
+	scanner.policyCentricReports = true
+
+	clusterPolicy := &policiesv1.ClusterAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-policy", UID: "cluster-policy-uid"},
+	}
+	rejected := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: false},
+	}
+
+	// the report of the policy stored by a previous run
+	previousClusterReport := testutils.NewClusterPolicyReportFactory().
+		Name("kubewarden-policy-clusterwide-cluster-policy-0").WithAppLabel().WithPolicyResultsLabel().RunUID("previous-run").Build()
+	require.NoError(t, fakeClient.Create(t.Context(), previousClusterReport))
+
+	pod1 := *toUnstructured(t, newTestPod("pod1", "namespace1"), corev1.SchemeGroupVersion.WithKind("Pod"))
+	require.NoError(t, scanner.addPolicyResults("run", pod1, []policyAuditResult{
+		{policy: clusterPolicy, admissionReviewResponse: rejected},
+	}))
+	require.NoError(t, scanner.finishPolicyResults(t.Context(), "run", true))
+
+	// the report would miss the resources not audited by the run, the
+	// report of the previous run is kept
+	clusterReport := &wgpolicy.ClusterPolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "kubewarden-policy-clusterwide-cluster-policy-0"}, clusterReport))
+	assert.Equal(t, "previous-run", clusterReport.Labels["kubewarden.io/audit-scanner-run-uid"])
+	assert.Empty(t, clusterReport.Results)
+}
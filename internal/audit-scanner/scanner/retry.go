@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	"net/http"
+)
+
+// retryableError is a failed request to a PolicyServer worth a retry, like a
+// network error or an unavailable PolicyServer. These failures are also
+// counted by the circuit breakers.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// isRetryableStatusCode returns true for the status codes returned while a
+// PolicyServer is overloaded or restarting, e.g. during a rollout.
+func isRetryableStatusCode(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,191 @@
+package scanner
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// newMockPolicyServerWithStatusCodes returns a PolicyServer replying with the
+// given status codes, in order, then allowing all the requests.
+func newMockPolicyServerWithStatusCodes(requests *atomic.Int32, statusCodes ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		request := int(requests.Add(1))
+		if request <= len(statusCodes) {
+			writer.WriteHeader(statusCodes[request-1])
+			return
+		}
+		allowedAdmissionReviewHandler(writer, r)
+	}))
+}
+
+func newTestRetryScanner(t *testing.T, requestsConfig PolicyServerRequestsConfig) *Scanner {
+	t.Helper()
+	scanner, err := NewScanner(Config{
+		PolicyServerRequests: requestsConfig,
+		Logger:               slog.Default(),
+	})
+	require.NoError(t, err)
+	return scanner
+}
+
+func TestSendAdmissionReviewRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		statusCodes      []int
+		maxRetries       int
+		expectedRequests int32
+		expectedErr      bool
+	}{
+		{
+			name:             "transient failures during a rollout",
+			statusCodes:      []int{http.StatusServiceUnavailable, http.StatusBadGateway},
+			maxRetries:       3,
+			expectedRequests: 3,
+		},
+		{
+			name:             "retries exhausted",
+			statusCodes:      []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			maxRetries:       2,
+			expectedRequests: 3,
+			expectedErr:      true,
+		},
+		{
+			name:             "non retryable failure",
+			statusCodes:      []int{http.StatusNotFound},
+			maxRetries:       3,
+			expectedRequests: 1,
+			expectedErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := atomic.Int32{}
+			policyServer := newMockPolicyServerWithStatusCodes(&requests, test.statusCodes...)
+			defer policyServer.Close()
+			policyServerURL, err := url.Parse(policyServer.URL + "/audit/policy")
+			require.NoError(t, err)
+
+			scanner := newTestRetryScanner(t, PolicyServerRequestsConfig{
+				MaxRetries:     test.maxRetries,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     5 * time.Millisecond,
+			})
+
+			response, err := scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, nil, &admissionv1.AdmissionReview{}, nil)
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, response.Response.Allowed)
+			}
+			assert.Equal(t, test.expectedRequests, requests.Load())
+		})
+	}
+}
+
+func TestSendAdmissionReviewCircuitBreaker(t *testing.T) {
+	requests := atomic.Int32{}
+	policyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer policyServer.Close()
+
+	scanner := newTestRetryScanner(t, PolicyServerRequestsConfig{
+		CircuitBreakerThreshold: 2,
+		CircuitBreakerCooldown:  time.Hour,
+	})
+
+	for _, policy := range []string{"policy1", "policy2", "policy3", "policy4"} {
+		policyServerURL, err := url.Parse(policyServer.URL + "/audit/" + policy)
+		require.NoError(t, err)
+
+		_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, nil, &admissionv1.AdmissionReview{}, nil)
+		require.Error(t, err)
+		if policy == "policy3" || policy == "policy4" {
+			require.ErrorIs(t, err, errCircuitOpen)
+			assert.Contains(t, err.Error(), "after 2 consecutive failures")
+		}
+	}
+	// the circuit opened after 2 failures, the PolicyServer was not called anymore
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, cooldown: time.Minute}
+	now := time.Now()
+
+	probe, err := breaker.allow(now)
+	require.NoError(t, err)
+	assert.False(t, probe)
+	assert.True(t, breaker.failure(assert.AnError, now))
+	_, err = breaker.allow(now.Add(time.Second))
+	require.ErrorIs(t, err, errCircuitOpen)
+
+	// once the cooldown is elapsed, a single request probes the PolicyServer
+	probeTime := now.Add(2 * time.Minute)
+	probe, err = breaker.allow(probeTime)
+	require.NoError(t, err)
+	assert.True(t, probe)
+	_, err = breaker.allow(probeTime)
+	require.ErrorIs(t, err, errCircuitOpen)
+
+	// the probe failed, the circuit stays open for another cooldown
+	assert.False(t, breaker.failure(assert.AnError, probeTime))
+	_, err = breaker.allow(probeTime.Add(time.Second))
+	require.ErrorIs(t, err, errCircuitOpen)
+
+	// the probe succeeded, the circuit is closed
+	probe, err = breaker.allow(probeTime.Add(2 * time.Minute))
+	require.NoError(t, err)
+	assert.True(t, probe)
+	breaker.success()
+	probe, err = breaker.allow(probeTime.Add(2 * time.Minute))
+	require.NoError(t, err)
+	assert.False(t, probe)
+}
+
+func TestSendAdmissionReviewCircuitBreakerProbeRetries(t *testing.T) {
+	requests := atomic.Int32{}
+	// the first request opens the circuit, the probe succeeds at its last retry
+	policyServer := newMockPolicyServerWithStatusCodes(&requests,
+		http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	defer policyServer.Close()
+	policyServerURL, err := url.Parse(policyServer.URL + "/audit/policy")
+	require.NoError(t, err)
+
+	scanner := newTestRetryScanner(t, PolicyServerRequestsConfig{
+		InitialBackoff:          time.Millisecond,
+		MaxBackoff:              5 * time.Millisecond,
+		CircuitBreakerThreshold: 1,
+		CircuitBreakerCooldown:  50 * time.Millisecond,
+	})
+
+	_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, nil, &admissionv1.AdmissionReview{}, nil)
+	require.Error(t, err)
+	_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, nil, &admissionv1.AdmissionReview{}, nil)
+	require.ErrorIs(t, err, errCircuitOpen)
+
+	// the probe is retried without being rejected by the open circuit
+	time.Sleep(100 * time.Millisecond)
+	scanner.policyServerRequests.MaxRetries = 2
+	response, err := scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, nil, &admissionv1.AdmissionReview{}, nil)
+	require.NoError(t, err)
+	assert.True(t, response.Response.Allowed)
+	assert.Equal(t, int32(4), requests.Load())
+
+	// the circuit is closed
+	_, err = scanner.sendAdmissionReviewToPolicyServer(t.Context(), "", policyServerURL, nil, &admissionv1.AdmissionReview{}, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,181 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// runSummaryCollector aggregates the outcome of the audits of a scan run.
+// It's shared by all the workers of the run.
+type runSummaryCollector struct {
+	mutex   sync.Mutex
+	summary report.RunSummary
+	// finished is set once the run is finished, the late audits are not
+	// recorded anymore
+	finished bool
+}
+
+// startRunSummary returns the collector of the given run, creating it when
+// this is the first scan of the run.
+func (s *Scanner) startRunSummary(runUID string) *runSummaryCollector {
+	collector, _ := s.runSummaries.LoadOrStore(runUID, &runSummaryCollector{
+		summary: report.RunSummary{
+			RunUID:    runUID,
+			StartTime: metav1.Now(),
+			Policies:  map[string]*report.PolicySummary{},
+		},
+	})
+
+	return collector.(*runSummaryCollector) //nolint:forcetypeassert // only runSummaryCollectors are stored
+}
+
+// getRunSummary returns the collector of the given run, or nil when the run
+// has not been started or it's already finished.
+func (s *Scanner) getRunSummary(runUID string) *runSummaryCollector {
+	collector, found := s.runSummaries.Load(runUID)
+	if !found {
+		return nil
+	}
+
+	return collector.(*runSummaryCollector) //nolint:forcetypeassert // only runSummaryCollectors are stored
+}
+
+// addNamespace records a scanned namespace.
+func (c *runSummaryCollector) addNamespace(namespace string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.finished {
+		return
+	}
+	c.summary.Namespaces = append(c.summary.Namespaces, namespace)
+}
+
+// addPolicies records the auditable, skipped and errored policies. The
+// auditable policies are recorded even when they don't target any resource.
+func (c *runSummaryCollector) addPolicies(auditablePolicies *policies.Policies) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.finished {
+		return
+	}
+	for _, gvrPolicies := range auditablePolicies.PoliciesByGVR {
+		for _, policy := range gvrPolicies {
+			if _, found := c.summary.Policies[policy.GetUniqueName()]; !found {
+				c.summary.Policies[policy.GetUniqueName()] = &report.PolicySummary{}
+			}
+		}
+	}
+	c.summary.SkippedPolicies = appendUnique(c.summary.SkippedPolicies, auditablePolicies.SkippedPolicies...)
+	c.summary.ErroredPolicies = appendUnique(c.summary.ErroredPolicies, auditablePolicies.ErroredPolicies...)
+}
+
+// markIncomplete records that the run was interrupted.
+func (c *runSummaryCollector) markIncomplete() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.summary.Incomplete = true
+}
+
+// markResumed records that the run was resumed from a checkpoint.
+func (c *runSummaryCollector) markResumed() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.summary.Resumed = true
+}
+
+// addResourceAudit records the results of the audit of a resource.
+func (c *runSummaryCollector) addResourceAudit(auditedPolicies []*policies.Policy, resourceReport report.Report) {
+	policyNames := make([]string, len(auditedPolicies))
+	for i, policy := range auditedPolicies {
+		policyNames[i] = policy.GetUniqueName()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.finished {
+		return
+	}
+	c.summary.AddResourceResults(policyNames, resourceReport)
+}
+
+// FinishRun completes the summary of the given run. The summary is logged and,
+// unless the store is disabled, saved in a ConfigMap replacing the summaries
+// of the previous runs. The summaries of the previous runs are kept when the
+// run is incomplete or filtered. The shards of a sharded run store a summary
+// each. The reports of the policies of the run are stored too, when it's
+// complete. When the run has not been started or it's already finished, an
+// empty summary is returned and nothing is stored.
+func (s *Scanner) FinishRun(ctx context.Context, runUID string) (*report.RunSummary, error) {
+	value, found := s.runSummaries.LoadAndDelete(runUID)
+	if !found {
+		return &report.RunSummary{RunUID: runUID, Policies: map[string]*report.PolicySummary{}}, nil
+	}
+	collector := value.(*runSummaryCollector) //nolint:forcetypeassert // only runSummaryCollectors are stored
+
+	collector.mutex.Lock()
+	collector.finished = true
+	summary := collector.summary
+	collector.mutex.Unlock()
+
+	summary.EndTime = metav1.Now()
+	if s.shard.sharded() {
+		summary.ShardIndex = s.shard.Index
+		summary.ShardCount = s.shard.Count
+	}
+	slices.Sort(summary.Namespaces)
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return &summary, fmt.Errorf("failed to encode the run summary: %w", err)
+	}
+	s.logger.InfoContext(ctx, "run summary", slog.String("RunUID", runUID), slog.String("summary", string(summaryJSON)))
+
+	// an incomplete run is resumed by the next one
+	if err = s.checkpoints.finish(ctx, summary.Incomplete); err != nil {
+		return &summary, err
+	}
+	if err = s.finishPolicyResults(ctx, runUID, summary.Incomplete); err != nil {
+		return &summary, err
+	}
+	if s.disableStore || s.runSummaryNamespace == "" || s.runSummaryStore == nil {
+		return &summary, nil
+	}
+
+	if err = s.runSummaryStore.CreateOrPatchRunSummary(ctx, s.runSummaryNamespace, &summary); err != nil {
+		return &summary, fmt.Errorf("failed to store the run summary: %w", err)
+	}
+	// the summaries of an incomplete or filtered run don't cover what the
+	// previous runs audited
+	if summary.Incomplete || s.policiesClient.Filtered() {
+		return &summary, nil
+	}
+	if err = s.runSummaryStore.DeleteOldRunSummaries(ctx, runUID, s.runSummaryNamespace); err != nil {
+		return &summary, fmt.Errorf("failed to delete old run summaries: %w", err)
+	}
+
+	return &summary, nil
+}
+
+func appendUnique(values []string, newValues ...string) []string {
+	for _, value := range newValues {
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	slices.Sort(values)
+
+	return values
+}
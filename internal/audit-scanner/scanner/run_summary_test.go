@@ -0,0 +1,189 @@
+package scanner
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestFinishRunStoresRunSummary(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+			UID:  "namespace1-uid",
+		},
+	}
+
+	pod1 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "namespace1",
+			UID:       "pod1-uid",
+			Labels:    map[string]string{"env": "prod"},
+		},
+	}
+
+	pod2 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod2",
+			Namespace: "namespace1",
+			UID:       "pod2-uid",
+		},
+	}
+
+	podRule := admissionregistrationv1.Rule{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"pods"},
+	}
+
+	// an AdmissionPolicy targeting all the pods
+	admissionPolicy1 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy1").
+		Namespace("namespace1").
+		Rule(podRule).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// an AdmissionPolicy with an objectSelector matching only pod1
+	admissionPolicy2 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy2").
+		Namespace("namespace1").
+		ObjectSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}).
+		Rule(podRule).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// an AdmissionPolicy with background audit set to false, it should be skipped
+	admissionPolicy3 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy3").
+		Namespace("namespace1").
+		Rule(podRule).
+		BackgroundAudit(false).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// the summary of a previous run, it should be deleted
+	oldRunSummary := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      report.RunSummaryName("old-run"),
+			Namespace: "kubewarden",
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by":         "kubewarden",
+				report.LabelRunSummary:                 "true",
+				auditConstants.AuditScannerRunUIDLabel: "old-run",
+			},
+		},
+	}
+
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		scheme.Scheme,
+		namespace1,
+		pod1,
+		pod2,
+	)
+	clientset := fake.NewClientset(
+		namespace1,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		admissionPolicy1,
+		admissionPolicy2,
+		admissionPolicy3,
+		oldRunSummary,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	config.RunSummaryNamespace = "kubewarden"
+	config.RunSummaryStore = report.NewConfigMapStore(client, logger)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	runUID := "new-run"
+	require.NoError(t, scanner.ScanAllNamespaces(t.Context(), runUID))
+	finishedRunSummary, err := scanner.FinishRun(t.Context(), runUID)
+	require.NoError(t, err)
+
+	runSummaryConfigMap := corev1.ConfigMap{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: report.RunSummaryName(runUID), Namespace: "kubewarden"}, &runSummaryConfigMap)
+	require.NoError(t, err)
+	assert.Equal(t, runUID, runSummaryConfigMap.Labels[auditConstants.AuditScannerRunUIDLabel])
+
+	runSummary := report.RunSummary{}
+	require.NoError(t, json.Unmarshal([]byte(runSummaryConfigMap.Data[report.RunSummaryDataKey]), &runSummary))
+	assert.Equal(t, runUID, runSummary.RunUID)
+	assert.Equal(t, []string{"namespace1"}, runSummary.Namespaces)
+	assert.Equal(t, 2, runSummary.ResourcesAudited)
+	assert.Equal(t, map[string]*report.PolicySummary{
+		admissionPolicy1.GetUniqueName(): {Pass: 2},
+		admissionPolicy2.GetUniqueName(): {Pass: 1, Skip: 1},
+	}, runSummary.Policies)
+	assert.Equal(t, []string{admissionPolicy3.GetUniqueName()}, runSummary.SkippedPolicies)
+	assert.Empty(t, runSummary.ErroredPolicies)
+	assert.False(t, runSummary.EndTime.Before(&runSummary.StartTime))
+	assert.Equal(t, finishedRunSummary.Policies, runSummary.Policies)
+
+	err = client.Get(t.Context(), types.NamespacedName{Name: oldRunSummary.GetName(), Namespace: "kubewarden"}, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// the run is finished, finishing it again returns an empty summary
+	finishedRunSummary, err = scanner.FinishRun(t.Context(), runUID)
+	require.NoError(t, err)
+	assert.Empty(t, finishedRunSummary.Policies)
+}
@@ -3,8 +3,6 @@ package scanner
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,38 +10,84 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"sync"
 	"time"
 
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/httpclient"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/output"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
 	"golang.org/x/sync/semaphore"
 	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-const httpClientTimeout = 10 * time.Second
+const (
+	defaultHTTPClientTimeout   = 10 * time.Second
+	defaultMaxInflightRequests = 100
+)
 
 // Scanner verifies that existing resources don't violate any of the policies.
 type Scanner struct {
 	policiesClient *policies.Client
 	k8sClient      *k8s.Client
 	reportStore    report.Store
+	// runSummaryStore stores the summaries of the runs, it's nil when they
+	// are only logged
+	runSummaryStore report.RunSummaryStore
 	// http client used to make requests against the Policy Server
-	httpClient               http.Client
-	outputScan               bool
-	disableStore             bool
-	parallelNamespacesAudits int
-	parallelResourcesAudits  int
-	parallelPoliciesAudits   int
-	logger                   *slog.Logger
-	reportKind               report.CrdKind
+	httpClient http.Client
+	// policyServerRequests configures the retries of the requests sent to
+	// the PolicyServers
+	policyServerRequests PolicyServerRequestsConfig
+	// circuitBreakers stops sending requests to the unavailable PolicyServers
+	circuitBreakers *circuitBreakers
+	// endpoints distributes the requests among the PolicyServer replicas
+	endpoints *endpointBalancer
+	// scheduler bounds the requests sent to the PolicyServers, sharing them
+	// fairly among the audited namespaces
+	scheduler  *requestScheduler
+	outputScan bool
+	// output writes the results of each audited resource, when not nil
+	output       output.Writer
+	disableStore bool
+	incremental  bool
+	// userInfo is the user performing the admission requests sent to the policies
+	userInfo                authenticationv1.UserInfo
+	parallelResourcesAudits int
+	// resourceAudits bounds the resources audited at once by the scanner,
+	// whatever the number of namespaces scanned in parallel
+	resourceAudits *semaphore.Weighted
+	logger         *slog.Logger
+	reportKind     report.CrdKind
+	granularity    report.Granularity
+	// aggregatedReports holds the reports aggregating the results of the
+	// namespaces being scanned, by namespace name, with the namespace
+	// granularity. The cluster-wide resources have an empty one
+	aggregatedReports sync.Map
+	// policyCentricReports stores a report per policy too, with its failed
+	// and errored results
+	policyCentricReports bool
+	// policyResults holds the collectors of the results of the policies of
+	// the runs in progress, by runUID
+	policyResults sync.Map
+	// runSummaryNamespace is the namespace where the run summaries are stored
+	runSummaryNamespace string
+	// runSummaries holds the summary collectors of the runs in progress, by runUID
+	runSummaries sync.Map
+	// shard is the part of the audit done by this scanner
+	shard ShardConfig
+	// checkpoints saves the progress of the runs, it's nil when the runs
+	// cannot be resumed
+	checkpoints *checkpointer
 }
 
 // NewScanner creates a new scanner
@@ -51,42 +95,18 @@ type Scanner struct {
 // cert trust store. This gets used by the httpClient when connection to
 // PolicyServers endpoints.
 func NewScanner(config Config) (*Scanner, error) {
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-	}
-
-	// Get the SystemCertPool to build an in-app cert pool from it
-	// Continue with an empty pool on error
-	rootCAs, _ := x509.SystemCertPool()
-	if rootCAs == nil {
-		rootCAs = x509.NewCertPool()
-	}
-
 	logger := config.Logger.With("component", "scanner")
+	tlsConfig, err := httpclient.NewTLSConfig(config.TLS.CAFile, config.TLS.ClientCertFile, config.TLS.ClientKeyFile)
+	if err != nil {
+		return nil, err
+	}
 	if config.TLS.CAFile != "" {
-		caCert, err := os.ReadFile(config.TLS.CAFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file %q with CA cert: %w", config.TLS.CAFile, err)
-		}
-		// Append our cert to the in-app cert pool
-		if ok := rootCAs.AppendCertsFromPEM(caCert); !ok {
-			return nil, errors.New("failed to append cert to in-app RootCAs trust store")
-		}
 		logger.Debug("appended cert file to in-app RootCAs trust store", slog.String("ca-cert", config.TLS.CAFile))
 	}
-
-	tlsConfig.RootCAs = rootCAs
-
 	if config.TLS.ClientCertFile != "" && config.TLS.ClientKeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(config.TLS.ClientCertFile, config.TLS.ClientKeyFile)
-		if err != nil {
-			return nil, fmt.Errorf("loading client certificate: %w", err)
-		}
-		logger.Debug("appended cert file to in-app RootCAs trust store",
+		logger.Debug("loaded the client certificate",
 			slog.String("client-cert", config.TLS.ClientCertFile),
 			slog.String("client-key", config.TLS.ClientKeyFile))
-
-		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
 	if config.TLS.Insecure {
@@ -95,7 +115,10 @@ func NewScanner(config Config) (*Scanner, error) {
 	tlsConfig.InsecureSkipVerify = config.TLS.Insecure
 
 	httpClient := *http.DefaultClient
-	httpClient.Timeout = httpClientTimeout
+	httpClient.Timeout = config.PolicyServerRequests.Timeout
+	if httpClient.Timeout <= 0 {
+		httpClient.Timeout = defaultHTTPClientTimeout
+	}
 	httpClient.Transport = http.DefaultTransport
 	transport, ok := httpClient.Transport.(*http.Transport)
 	if !ok {
@@ -110,20 +133,44 @@ func NewScanner(config Config) (*Scanner, error) {
 	// PolicyServer Pod, causing the load to be unevenly distributed.
 	// To avoid this, we disable keep-alives, which ensures a
 	// new connection is created for each evaluation request.
+	// This only applies to the requests sent to the PolicyServer Service,
+	// the endpointBalancer keeps the connections with each replica alive.
 	transport.DisableKeepAlives = true
 
+	maxInflightRequests := config.Parallelization.MaxInflightRequests
+	if maxInflightRequests <= 0 {
+		maxInflightRequests = defaultMaxInflightRequests
+	}
+
+	var checkpoints *checkpointer
+	if config.CheckpointName != "" && config.CheckpointStore != nil && !config.DisableStore && config.RunSummaryNamespace != "" {
+		checkpoints = newCheckpointer(config.CheckpointStore, config.RunSummaryNamespace, config.CheckpointName, config.CheckpointMaxAge, logger)
+	}
+
 	return &Scanner{
-		policiesClient:           config.PoliciesClient,
-		k8sClient:                config.K8sClient,
-		reportStore:              config.ReportStore,
-		httpClient:               httpClient,
-		outputScan:               config.OutputScan,
-		disableStore:             config.DisableStore,
-		parallelNamespacesAudits: config.Parallelization.ParallelNamespacesAudits,
-		parallelResourcesAudits:  config.Parallelization.ParallelResourcesAudits,
-		parallelPoliciesAudits:   config.Parallelization.PoliciesAudits,
-		logger:                   logger,
-		reportKind:               config.ReportKind,
+		policiesClient:          config.PoliciesClient,
+		k8sClient:               config.K8sClient,
+		reportStore:             config.ReportStore,
+		runSummaryStore:         config.RunSummaryStore,
+		httpClient:              httpClient,
+		policyServerRequests:    config.PolicyServerRequests,
+		circuitBreakers:         newCircuitBreakers(config.PolicyServerRequests.CircuitBreakerThreshold, config.PolicyServerRequests.CircuitBreakerCooldown),
+		endpoints:               newEndpointBalancer(config.PolicyServerRequests.LoadBalancing, transport, httpClient.Timeout),
+		scheduler:               newRequestScheduler(maxInflightRequests, config.Parallelization.MaxInflightRequestsPerPolicyServer),
+		outputScan:              config.OutputScan,
+		output:                  config.Output,
+		disableStore:            config.DisableStore,
+		incremental:             config.Incremental,
+		userInfo:                config.UserInfo,
+		parallelResourcesAudits: config.Parallelization.ParallelResourcesAudits,
+		resourceAudits:          semaphore.NewWeighted(int64(config.Parallelization.ParallelResourcesAudits)),
+		logger:                  logger,
+		reportKind:              config.ReportKind,
+		granularity:             config.ReportGranularity,
+		policyCentricReports:    config.PolicyCentricReports,
+		runSummaryNamespace:     config.RunSummaryNamespace,
+		shard:                   config.Shard,
+		checkpoints:             checkpoints,
 	}, nil
 }
 
@@ -136,7 +183,10 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 		slog.String("namespace", nsName),
 		slog.String("RunUID", runUID),
 		slog.Int("parallel-resources-audits", s.parallelResourcesAudits))
-	semaphore := semaphore.NewWeighted(int64(s.parallelResourcesAudits))
+	if s.checkpoints.isCompleted(nsName) {
+		s.logger.InfoContext(ctx, "namespace already scanned by the resumed run", slog.String("namespace", nsName))
+		return nil
+	}
 	var workers sync.WaitGroup
 
 	namespace, err := s.k8sClient.GetNamespace(ctx, nsName)
@@ -147,6 +197,10 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 	if err != nil {
 		return fmt.Errorf("failed to obtain auditable policies for namespace %s: %w", nsName, err)
 	}
+	runSummary := s.startRunSummary(runUID)
+	runSummary.addNamespace(nsName)
+	runSummary.addPolicies(policies)
+	s.startAggregatedReport(runUID, nsName)
 
 	s.logger.InfoContext(ctx, "policy count",
 		slog.String("namespace", nsName),
@@ -155,32 +209,16 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 		slog.Int("policies-errored", policies.ErroredNum))
 
 	for gvr, pols := range policies.PoliciesByGVR {
-		pager := s.k8sClient.GetResources(gvr, nsName)
-
-		err = pager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
-			resource, ok := obj.(*unstructured.Unstructured)
-			if !ok {
-				return errors.New("failed to convert runtime.Object to *unstructured.Unstructured")
-			}
-
-			acquireErr := semaphore.Acquire(ctx, 1)
-			if acquireErr != nil {
-				return fmt.Errorf("failed to acquire the permission to audit resource: %w", acquireErr)
+		// stop dispatching new audits once the scan is canceled
+		if ctx.Err() != nil {
+			break
+		}
+		err = s.auditResources(ctx, gvr, nsName, &workers, func(resource unstructured.Unstructured) {
+			if auditErr := s.auditResource(ctx, pols, gvr, resource, runUID, policies.SkippedNum, policies.ErroredNum); auditErr != nil {
+				s.logger.ErrorContext(ctx, "error auditing resource",
+					slog.String("error", auditErr.Error()),
+					slog.String("RunUID", runUID))
 			}
-			workers.Add(1)
-			policiesToAudit := pols
-
-			go func() {
-				defer semaphore.Release(1)
-				defer workers.Done()
-
-				if auditErr := s.auditResource(ctx, policiesToAudit, *resource, runUID, policies.SkippedNum, policies.ErroredNum); auditErr != nil {
-					s.logger.ErrorContext(ctx, "error auditing resource",
-						slog.String("error", auditErr.Error()),
-						slog.String("RunUID", runUID))
-				}
-			}()
-			return nil
 		})
 		if err != nil {
 			// If we fail to get the resources, we log the error inside the pager function
@@ -194,48 +232,83 @@ func (s *Scanner) ScanNamespace(ctx context.Context, nsName, runUID string) erro
 		}
 	}
 	workers.Wait()
-
-	if deleteErr := s.reportStore.DeleteOldReports(ctx, runUID, nsName); deleteErr != nil {
+	s.finishAggregatedReport(ctx, nsName)
+
+	// the resources not audited yet still have the reports of the previous
+	// runs, they must not be deleted
+	if ctx.Err() != nil {
+		runSummary.markIncomplete()
+		s.logger.WarnContext(ctx, "namespace scan interrupted, keeping the reports of the previous runs",
+			slog.String("ns", nsName),
+			slog.String("RunUID", runUID))
+		return fmt.Errorf("namespace %s scan interrupted: %w", nsName, ctx.Err())
+	}
+
+	// a filtered audit does not produce the reports of all the resources,
+	// the reports of the previous runs are still valid
+	if s.policiesClient.Filtered() {
+		s.logger.InfoContext(ctx, "filtered audit, keeping the reports of the previous runs", slog.String("ns", nsName))
+	} else if s.shard.sharded() {
+		// the other shards may not be finished yet, the coordinator
+		// deletes the reports of the previous runs
+		s.logger.DebugContext(ctx, "sharded audit, the reports of the previous runs are deleted by the coordinator", slog.String("ns", nsName))
+	} else if deleteErr := s.reportStore.DeleteOldReports(ctx, runUID, nsName); deleteErr != nil {
 		s.logger.ErrorContext(ctx, "error deleting old reports",
 			slog.String("error", deleteErr.Error()),
 			slog.String("RunUID", runUID))
 	}
+	s.checkpoints.complete(ctx, nsName)
 	s.logger.InfoContext(ctx, "Namespaced resources scan finished")
 	return nil
 }
 
 // ScanAllNamespaces scans resources for all namespaces, except the ones in the skipped list.
+// The namespaces are scanned in parallel, the scheduler shares the requests
+// sent to the PolicyServers fairly among them. At most as many namespaces as
+// resource audits are scanned at once: the other ones would only keep their
+// page of resources in memory while waiting for an audit.
 // Returns errors if there's any when fetching policies or resources, but only
 // logs them if there's a problem auditing the resource of saving the Report or
 // Result, so it can continue with the next audit, or next Result.
 func (s *Scanner) ScanAllNamespaces(ctx context.Context, runUID string) error {
-	s.logger.InfoContext(ctx, "all-namespaces scan started",
-		slog.Group("dict",
-			slog.Int("parallel-namespaces-audits", s.parallelNamespacesAudits)))
+	s.logger.InfoContext(ctx, "all-namespaces scan started")
 	nsList, err := s.k8sClient.GetAuditedNamespaces(ctx)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "error scanning all namespaces", slog.String("error", err.Error()))
+		s.startRunSummary(runUID).markIncomplete()
+		return fmt.Errorf("error scanning all namespaces: %w", err)
 	}
-	semaphore := semaphore.NewWeighted(int64(s.parallelNamespacesAudits))
 	var workers sync.WaitGroup
+	var errMutex sync.Mutex
+	namespaceScans := semaphore.NewWeighted(int64(s.parallelResourcesAudits))
 
 	for _, namespace := range nsList.Items {
-		workers.Add(1)
-		acquireErr := semaphore.Acquire(ctx, 1)
-		if acquireErr != nil {
-			return fmt.Errorf("failed to acquire the permission to audit namespace: %w", err)
+		if !s.shard.owns(namespace.Name) {
+			continue
 		}
 		namespaceName := namespace.Name
 
-		go func() {
-			defer semaphore.Release(1)
-			defer workers.Done()
+		if acquireErr := namespaceScans.Acquire(ctx, 1); acquireErr != nil {
+			// the scan is interrupted, the namespaces not scanned yet are
+			// not audited
+			s.startRunSummary(runUID).markIncomplete()
+			errMutex.Lock()
+			err = errors.Join(err, fmt.Errorf("all-namespaces scan interrupted: %w", acquireErr))
+			errMutex.Unlock()
+			break
+		}
+		workers.Go(func() {
+			defer namespaceScans.Release(1)
 
 			if e := s.ScanNamespace(ctx, namespaceName, runUID); e != nil {
 				s.logger.ErrorContext(ctx, "error scanning namespace", slog.String("error", e.Error()), slog.String("ns", namespaceName))
+				// the namespace has not been fully scanned
+				s.startRunSummary(runUID).markIncomplete()
+				errMutex.Lock()
 				err = errors.Join(err, e)
+				errMutex.Unlock()
 			}
-		}()
+		})
 	}
 	workers.Wait()
 
@@ -253,14 +326,20 @@ func (s *Scanner) ScanAllNamespaces(ctx context.Context, runUID string) error {
 // Result, so it can continue with the next audit, or next Result.
 func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) error {
 	s.logger.InfoContext(ctx, "clusterwide resources scan started", slog.String("RunUID", runUID))
+	if s.checkpoints.isCompleted("") {
+		s.logger.InfoContext(ctx, "clusterwide resources already scanned by the resumed run")
+		return nil
+	}
 
-	semaphore := semaphore.NewWeighted(int64(s.parallelResourcesAudits))
 	var workers sync.WaitGroup
 
 	policies, err := s.policiesClient.GetClusterWidePolicies(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to obtain cluster auditable policies: %w", err)
 	}
+	runSummary := s.startRunSummary(runUID)
+	runSummary.addPolicies(policies)
+	s.startAggregatedReport(runUID, "")
 
 	s.logger.InfoContext(ctx, "cluster admission policies count",
 		slog.Int("policies-to-evaluate", policies.PolicyNum),
@@ -269,28 +348,16 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 		slog.Int("parallel-resources-audits", s.parallelResourcesAudits))
 
 	for gvr, pols := range policies.PoliciesByGVR {
-		pager := s.k8sClient.GetResources(gvr, "")
-		err = pager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
-			resource, ok := obj.(*unstructured.Unstructured)
-			if !ok {
-				return errors.New("failed to convert runtime.Object to *unstructured.Unstructured")
-			}
-
-			workers.Add(1)
-			acquireErr := semaphore.Acquire(ctx, 1)
-			if acquireErr != nil {
-				return fmt.Errorf("failed to acquire the permission to audit a resource: %w", acquireErr)
-			}
-			policiesToAudit := pols
-
-			go func() {
-				defer semaphore.Release(1)
-				defer workers.Done()
-
-				s.auditClusterResource(ctx, policiesToAudit, *resource, runUID, policies.SkippedNum, policies.ErroredNum)
-			}()
-
-			return nil
+		// stop dispatching new audits once the scan is canceled
+		if ctx.Err() != nil {
+			break
+		}
+		// the resource is audited by another shard
+		if !s.shard.owns(gvr.GroupResource().String()) {
+			continue
+		}
+		err = s.auditResources(ctx, gvr, "", &workers, func(resource unstructured.Unstructured) {
+			s.auditClusterResource(ctx, pols, gvr, resource, runUID, policies.SkippedNum, policies.ErroredNum)
 		})
 		if err != nil {
 			// If we fail to get the resources, we log the error inside the pager function
@@ -304,105 +371,132 @@ func (s *Scanner) ScanClusterWideResources(ctx context.Context, runUID string) e
 	}
 
 	workers.Wait()
+	s.finishAggregatedReport(ctx, "")
+
+	if ctx.Err() != nil {
+		runSummary.markIncomplete()
+		s.logger.WarnContext(ctx, "clusterwide resources scan interrupted, keeping the cluster reports of the previous runs",
+			slog.String("RunUID", runUID))
+		return fmt.Errorf("clusterwide resources scan interrupted: %w", ctx.Err())
+	}
 
-	if deleteErr := s.reportStore.DeleteOldClusterReports(ctx, runUID); deleteErr != nil {
+	if s.policiesClient.Filtered() {
+		s.logger.InfoContext(ctx, "filtered audit, keeping the cluster reports of the previous runs")
+	} else if s.shard.sharded() {
+		s.logger.DebugContext(ctx, "sharded audit, the cluster reports of the previous runs are deleted by the coordinator")
+	} else if deleteErr := s.reportStore.DeleteOldClusterReports(ctx, runUID); deleteErr != nil {
 		s.logger.ErrorContext(ctx, "error deleting old ClusterReports",
 			slog.String("error", deleteErr.Error()),
 			slog.String("RunUID", runUID))
 	}
+	s.checkpoints.complete(ctx, "")
 	s.logger.InfoContext(ctx, "Cluster-wide resources scan finished")
 	return nil
 }
 
-type policyAuditResult struct {
-	policy                  policiesv1.Policy
-	admissionReviewResponse *admissionv1.AdmissionReview
-	errored                 bool
-}
-
-//gocognit:ignore
-func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy, resource unstructured.Unstructured, runUID string, skippedPoliciesNum, erroredPoliciesNum int) error {
-	s.logger.InfoContext(ctx, "audit resource",
-		slog.String("resource", resource.GetName()),
-		slog.Int("policies-to-evaluate", len(policies)),
-		slog.Int("parallel-policies-audit", s.parallelPoliciesAudits))
-
-	semaphore := semaphore.NewWeighted(int64(s.parallelPoliciesAudits))
-	var workers sync.WaitGroup
-	auditResults := make(chan policyAuditResult, len(policies))
-
-	for _, policyToUse := range policies {
-		err := semaphore.Acquire(ctx, 1)
+// auditResources audits the resources of the given GVR, in the given
+// namespace or the cluster-wide ones when empty. The resources are listed page
+// by page, starting from the page saved by the checkpoint of a resumed run,
+// and audited by the workers, sharing the resource audits of the scanner
+// with the other namespaces. The progress is saved once all the resources of
+// a page, and of the previous ones, are audited.
+func (s *Scanner) auditResources(ctx context.Context, gvr schema.GroupVersionResource, nsName string, workers *sync.WaitGroup, audit func(resource unstructured.Unstructured)) error {
+	completed, continueToken := s.checkpoints.resourceState(nsName, gvr.String())
+	if completed {
+		s.logger.DebugContext(ctx, "resources already audited by the resumed run",
+			slog.String("resource-GVK", gvr.String()),
+			slog.String("ns", nsName))
+		return nil
+	}
+
+	listPager := s.k8sClient.GetResources(gvr, nsName)
+	opts := metav1.ListOptions{Limit: listPager.PageSize, Continue: continueToken}
+	restarted := false
+	// closed once the resources of the previous pages are audited
+	previousPages := make(chan struct{})
+	close(previousPages)
+	for {
+		obj, err := listPager.PageFn(ctx, opts)
 		if err != nil {
-			return fmt.Errorf("failed to acquire the permission to audit a resource: %w", err)
-		}
-		workers.Add(1)
-
-		url := policyToUse.PolicyServer
-		policy := policyToUse.Policy
-
-		go func() {
-			defer semaphore.Release(1)
-			defer workers.Done()
-
-			matches, matchErr := policyMatches(policy, resource)
-			if matchErr != nil {
-				s.logger.ErrorContext(ctx, "error matching policy to resource", slog.String("error", matchErr.Error()))
+			// the continue token expired, list the resources from the start
+			if opts.Continue != "" && !restarted && apierrors.IsResourceExpired(err) {
+				s.logger.WarnContext(ctx, "continue token expired, listing the resources from the start",
+					slog.String("resource-GVK", gvr.String()),
+					slog.String("ns", nsName))
+				opts.Continue = ""
+				restarted = true
+				continue
 			}
+			return err
+		}
+		list, ok := obj.(*unstructured.UnstructuredList)
+		if !ok {
+			return errors.New("failed to convert runtime.Object to *unstructured.UnstructuredList")
+		}
 
-			if !matches {
-				return
+		var pageWorkers sync.WaitGroup
+		for _, resource := range list.Items {
+			if acquireErr := s.resourceAudits.Acquire(ctx, 1); acquireErr != nil {
+				return fmt.Errorf("failed to acquire the permission to audit resource: %w", acquireErr)
 			}
+			pageWorkers.Add(1)
+			workers.Go(func() {
+				defer s.resourceAudits.Release(1)
+				defer pageWorkers.Done()
 
-			admissionReviewRequest := newAdmissionReview(resource)
-			admissionReviewResponse, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, url, admissionReviewRequest)
-			errored := false
-
-			if responseErr != nil {
-				errored = true
-				// log responseErr, will end in PolicyReportResult too
-				s.logger.ErrorContext(ctx, "error sending AdmissionReview to PolicyServer",
-					slog.String("error", responseErr.Error()),
-					slog.Group("response",
-						slog.String("admissionRequest-name", admissionReviewRequest.Request.Name),
-						slog.String("policy", policy.GetName()),
-						slog.String("resource", resource.GetName())))
-			} else if admissionReviewResponse.Response.Result != nil &&
-				admissionReviewResponse.Response.Result.Code == 500 {
-				errored = true
-				// log Result.Message, will end in PolicyReportResult too
-				s.logger.ErrorContext(ctx, "error evaluating Policy in PolicyServer", slog.String("error", errors.New(admissionReviewResponse.Response.Result.Message).Error()),
-					slog.Group("response",
-						slog.String("admissionRequest-name", admissionReviewRequest.Request.Name),
-						slog.String("policy", policy.GetName()),
-						slog.String("resource", resource.GetName())))
-			}
+				audit(resource)
+			})
+		}
 
-			if !errored {
-				s.logger.DebugContext(ctx, "audit review response",
-					slog.Group("response",
-						slog.String("uid", string(admissionReviewResponse.Response.UID)),
-						slog.String("policy", policy.GetName()),
-						slog.String("resource", resource.GetName()),
-						slog.Bool("allowed", admissionReviewResponse.Response.Allowed)))
+		nextToken := list.GetContinue()
+		pageAudited := make(chan struct{})
+		waitPreviousPages := previousPages
+		workers.Go(func() {
+			defer close(pageAudited)
+			pageWorkers.Wait()
+			<-waitPreviousPages
+			// the reports of the audits of an interrupted scan are not stored
+			if ctx.Err() == nil {
+				s.checkpoints.setContinue(ctx, nsName, gvr.String(), nextToken)
 			}
+		})
+		previousPages = pageAudited
 
-			auditResults <- policyAuditResult{
-				policy,
-				admissionReviewResponse,
-				errored,
-			}
-		}()
+		if nextToken == "" {
+			return nil
+		}
+		opts.Continue = nextToken
 	}
-	workers.Wait()
-	close(auditResults)
+}
+
+type policyAuditResult struct {
+	policy                  policiesv1.Policy
+	admissionReviewResponse *admissionv1.AdmissionReview
+	errored                 bool
+}
+
+func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy, gvr schema.GroupVersionResource, resource unstructured.Unstructured, runUID string, skippedPoliciesNum, erroredPoliciesNum int) error {
+	s.logger.InfoContext(ctx, "audit resource",
+		slog.String("resource", resource.GetName()),
+		slog.Int("policies-to-evaluate", len(policies)))
 
 	policyReport := report.NewReportOfKind(s.reportKind, runUID, resource)
 	policyReport.SetErrorPolicies(erroredPoliciesNum)
 	policyReport.SetSkipPolicies(skippedPoliciesNum)
-	for res := range auditResults {
-		policyReport.AddResult(res.policy, res.admissionReviewResponse, res.errored)
+	previousReport := s.getPreviousReport(ctx, resource, false)
+
+	s.auditPolicies(ctx, policies, gvr, resource, runUID, policyReport, previousReport)
+	if ctx.Err() != nil {
+		s.logger.DebugContext(ctx, "audit interrupted, the report of the resource is not stored",
+			slog.String("resource", resource.GetName()))
+		return nil
+	}
+	if runSummary := s.getRunSummary(runUID); runSummary != nil {
+		runSummary.addResourceAudit(policies, policyReport)
 	}
+	// the report is complete, it's stored even when the scan is canceled
+	// in the meantime
+	ctx = context.WithoutCancel(ctx)
 
 	if s.outputScan {
 		policyReportJSON, err := json.Marshal(policyReport)
@@ -412,17 +506,16 @@ func (s *Scanner) auditResource(ctx context.Context, policies []*policies.Policy
 
 		s.logger.InfoContext(ctx, "PolicyReport summary", slog.String("report", string(policyReportJSON)))
 	}
+	s.writeOutput(ctx, policyReport)
 
 	if !s.disableStore {
-		err := s.reportStore.CreateOrPatchReport(ctx, policyReport)
-		if err != nil {
-			s.logger.ErrorContext(ctx, "error adding PolicyReport to store.", slog.String("error", err.Error()))
-		}
+		s.keepStoredResults(ctx, policyReport, resource, false)
+		s.storeReport(ctx, policyReport, resource.GetNamespace())
 	}
 	return nil
 }
 
-func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies.Policy, resource unstructured.Unstructured, runUID string, skippedPoliciesNum, erroredPoliciesNum int) {
+func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies.Policy, gvr schema.GroupVersionResource, resource unstructured.Unstructured, runUID string, skippedPoliciesNum, erroredPoliciesNum int) {
 	s.logger.InfoContext(ctx, "audit clusterwide resource",
 		slog.String("resource", resource.GetName()),
 		slog.Int("policies-to-evaluate", len(policies)))
@@ -430,69 +523,233 @@ func (s *Scanner) auditClusterResource(ctx context.Context, policies []*policies
 	clusterReport := report.NewClusterReportOfKind(s.reportKind, runUID, resource)
 	clusterReport.SetSkipPolicies(skippedPoliciesNum)
 	clusterReport.SetErrorPolicies(erroredPoliciesNum)
-	for _, p := range policies {
-		url := p.PolicyServer
-		policy := p.Policy
+	previousReport := s.getPreviousReport(ctx, resource, true)
 
-		matches, err := policyMatches(policy, resource)
+	s.auditPolicies(ctx, policies, gvr, resource, runUID, clusterReport, previousReport)
+	if ctx.Err() != nil {
+		s.logger.DebugContext(ctx, "audit interrupted, the report of the resource is not stored",
+			slog.String("resource", resource.GetName()))
+		return
+	}
+	if runSummary := s.getRunSummary(runUID); runSummary != nil {
+		runSummary.addResourceAudit(policies, clusterReport)
+	}
+	// the report is complete, it's stored even when the scan is canceled
+	// in the meantime
+	ctx = context.WithoutCancel(ctx)
+
+	if s.outputScan {
+		clusterPolicyReportJSON, err := json.Marshal(clusterReport)
 		if err != nil {
-			s.logger.ErrorContext(ctx, "error matching policy to resource", slog.String("error", err.Error()))
+			s.logger.ErrorContext(ctx, "error while marshalling ClusterPolicyReport to JSON, skipping output scan", slog.String("error", err.Error()))
 		}
 
-		if !matches {
+		s.logger.InfoContext(ctx, "ClusterPolicyReport summary", slog.String("report", string(clusterPolicyReportJSON)))
+	}
+	s.writeOutput(ctx, clusterReport)
+
+	if !s.disableStore {
+		s.keepStoredResults(ctx, clusterReport, resource, true)
+		s.storeReport(ctx, clusterReport, "")
+	}
+}
+
+// auditPolicies evaluates the policies against the given resource and adds
+// the results to the report. The policies are evaluated in parallel, each
+// evaluation starts once the scheduler allows its request to be sent, so the
+// requests waiting for their turn are not built yet. The results of the
+// previous report are reused when neither the resource, the policy nor the
+// simulated operation changed. The failed and errored results are added to
+// the reports of their policies too, when the policy-centric reports are
+// enabled.
+func (s *Scanner) auditPolicies(ctx context.Context, policies []*policies.Policy, gvr schema.GroupVersionResource, resource unstructured.Unstructured, runUID string, resourceReport, previousReport report.Report) {
+	var workers sync.WaitGroup
+	auditResults := make(chan policyAuditResult, len(policies))
+
+	for _, policy := range policies {
+		if previousReport != nil && resourceReport.ReuseResult(previousReport, policy.Policy, admissionv1.Operation(policy.Operation)) {
+			s.logger.DebugContext(ctx, "resource and policy unchanged, reusing previous result",
+				slog.String("policy", policy.GetName()),
+				slog.String("resource", resource.GetName()))
 			continue
 		}
 
-		admissionReviewRequest := newAdmissionReview(resource)
-		admissionReviewResponse, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, url, admissionReviewRequest)
-		errored := false
-
-		if responseErr != nil {
-			errored = true
-			// log error, will end in ClusterPolicyReportResult too
-			s.logger.ErrorContext(ctx, "error sending AdmissionReview to PolicyServer", slog.String("error", responseErr.Error()),
-				slog.Group("response",
-					slog.String("admissionRequest name", admissionReviewRequest.Request.Name),
-					slog.String("policy", policy.GetName()),
-					slog.String("resource", resource.GetName())))
-		} else if admissionReviewResponse.Response.Result != nil &&
-			admissionReviewResponse.Response.Result.Code == 500 {
-			errored = true
-			// log Result.Message, will end in PolicyReportResult too
-			s.logger.ErrorContext(ctx, "error evaluating Policy in PolicyServer", slog.String("error", errors.New(admissionReviewResponse.Response.Result.Message).Error()),
-				slog.Group("response",
-					slog.String("admissionRequest-name", admissionReviewRequest.Request.Name),
-					slog.String("policy", policy.GetName()),
-					slog.String("resource", resource.GetName())))
+		// the requests are queued by namespace, the cluster-wide resources
+		// have an empty one
+		release, err := s.scheduler.acquire(ctx, resource.GetNamespace(), policyServerName(policy.PolicyServer))
+		if err != nil {
+			// the audit is interrupted, its results are not stored
+			break
 		}
+		workers.Go(func() {
+			if result := s.auditPolicy(ctx, policy, gvr, resource, release); result != nil {
+				auditResults <- *result
+			}
+		})
+	}
+	workers.Wait()
+	close(auditResults)
 
-		if !errored {
-			s.logger.DebugContext(ctx, "audit review response",
-				slog.Group("response",
-					slog.String("uid", string(admissionReviewResponse.Response.UID)),
-					slog.String("policy", policy.GetName()),
-					slog.String("resource", resource.GetName()),
-					slog.Bool("allowed", admissionReviewResponse.Response.Allowed)))
-		}
+	results := make([]policyAuditResult, 0, len(policies))
+	for res := range auditResults {
+		resourceReport.AddResult(res.policy, res.admissionReviewResponse, res.errored)
+		results = append(results, res)
+	}
 
-		clusterReport.AddResult(policy, admissionReviewResponse, errored)
+	// the results of an interrupted audit are not stored
+	if ctx.Err() != nil {
+		return
+	}
+	if err := s.addPolicyResults(runUID, resource, results); err != nil {
+		s.logger.ErrorContext(ctx, "error adding the results to the reports of the policies",
+			slog.String("error", err.Error()),
+			slog.String("resource", resource.GetName()))
 	}
+}
 
-	if s.outputScan {
-		clusterPolicyReportJSON, err := json.Marshal(clusterReport)
-		if err != nil {
-			s.logger.ErrorContext(ctx, "error while marshalling ClusterPolicyReport to JSON, skipping output scan", slog.String("error", err.Error()))
+// auditPolicy evaluates the policy against the given resource, release gives
+// back the permission of the scheduler to send the first request to the
+// PolicyServer. It returns nil when the policy does not match the resource,
+// hence there's no result to report.
+func (s *Scanner) auditPolicy(ctx context.Context, policyToUse *policies.Policy, gvr schema.GroupVersionResource, resource unstructured.Unstructured, release func()) *policyAuditResult {
+	policy := policyToUse.Policy
+
+	matches, matchErr := policyMatches(policy, resource)
+	if matchErr != nil {
+		s.logger.ErrorContext(ctx, "error matching policy to resource", slog.String("error", matchErr.Error()))
+	}
+
+	if !matches {
+		release()
+		return nil
+	}
+
+	admissionReviewRequest := newAdmissionReview(resource, gvr, admissionv1.Operation(policyToUse.Operation), s.userInfo)
+
+	matches, matchErr = matchesConditions(ctx, policyToUse, admissionReviewRequest.Request)
+	if matchErr != nil {
+		release()
+		// log matchErr, will end in PolicyReportResult too
+		s.logger.ErrorContext(ctx, "error evaluating policy matchConditions",
+			slog.String("error", matchErr.Error()),
+			slog.String("policy", policy.GetName()),
+			slog.String("resource", resource.GetName()))
+
+		return &policyAuditResult{
+			policy,
+			newErroredAdmissionReview(admissionReviewRequest.Request, matchErr),
+			true,
 		}
+	}
 
-		s.logger.InfoContext(ctx, "ClusterPolicyReport summary", slog.Any("report", clusterPolicyReportJSON))
+	if !matches {
+		release()
+		s.logger.DebugContext(ctx, "policy matchConditions do not match the resource, skipping",
+			slog.String("policy", policy.GetName()),
+			slog.String("resource", resource.GetName()))
+		return nil
+	}
+
+	admissionReviewResponse, responseErr := s.sendAdmissionReviewToPolicyServer(ctx, resource.GetNamespace(), policyToUse.PolicyServer, policyToUse.PolicyServerService, admissionReviewRequest, release)
+	errored := false
+
+	if responseErr != nil {
+		errored = true
+		// log responseErr, will end in PolicyReportResult too
+		s.logger.ErrorContext(ctx, "error sending AdmissionReview to PolicyServer",
+			slog.String("error", responseErr.Error()),
+			slog.Group("response",
+				slog.String("admissionRequest-name", admissionReviewRequest.Request.Name),
+				slog.String("policy", policy.GetName()),
+				slog.String("resource", resource.GetName())))
+		admissionReviewResponse = newErroredAdmissionReview(admissionReviewRequest.Request, responseErr)
+	} else if admissionReviewResponse.Response.Result != nil &&
+		admissionReviewResponse.Response.Result.Code == 500 {
+		errored = true
+		// log Result.Message, will end in PolicyReportResult too
+		s.logger.ErrorContext(ctx, "error evaluating Policy in PolicyServer", slog.String("error", errors.New(admissionReviewResponse.Response.Result.Message).Error()),
+			slog.Group("response",
+				slog.String("admissionRequest-name", admissionReviewRequest.Request.Name),
+				slog.String("policy", policy.GetName()),
+				slog.String("resource", resource.GetName())))
+	}
+
+	if !errored {
+		s.logger.DebugContext(ctx, "audit review response",
+			slog.Group("response",
+				slog.String("uid", string(admissionReviewResponse.Response.UID)),
+				slog.String("policy", policy.GetName()),
+				slog.String("resource", resource.GetName()),
+				slog.Bool("allowed", admissionReviewResponse.Response.Allowed)))
+	}
+
+	// The policy server replies with the response only, keep the request
+	// to record the simulated operation in the report result
+	if admissionReviewResponse == nil {
+		admissionReviewResponse = &admissionv1.AdmissionReview{}
+	}
+	admissionReviewResponse.Request = admissionReviewRequest.Request
+
+	return &policyAuditResult{
+		policy,
+		admissionReviewResponse,
+		errored,
 	}
+}
 
-	if !s.disableStore {
-		err := s.reportStore.CreateOrPatchClusterReport(ctx, clusterReport)
-		if err != nil {
-			s.logger.ErrorContext(ctx, "error adding ClusterPolicyReport to store", slog.String("error", err.Error()))
+// writeOutput writes the results of the report to the output, if any.
+func (s *Scanner) writeOutput(ctx context.Context, resourceReport report.Report) {
+	if s.output == nil {
+		return
+	}
+	if err := s.output.Write(resourceReport.Entries()); err != nil {
+		s.logger.ErrorContext(ctx, "error writing the results to the output", slog.String("error", err.Error()))
+	}
+}
+
+// getPreviousReport returns the report stored by a previous scan for the given
+// resource. It returns nil when incremental scans are disabled, the store is
+// disabled or there's no previous report.
+func (s *Scanner) getPreviousReport(ctx context.Context, resource unstructured.Unstructured, clusterWide bool) report.Report {
+	if !s.incremental || s.disableStore {
+		return nil
+	}
+	return s.getStoredReport(ctx, resource, clusterWide)
+}
+
+// keepStoredResults adds to the report of a scan restricted to some policies
+// the stored results of the other policies. The report replaces the stored
+// one, which holds the results of all the policies.
+func (s *Scanner) keepStoredResults(ctx context.Context, resourceReport report.Report, resource unstructured.Unstructured, clusterWide bool) {
+	filteredPolicies := s.policiesClient.FilteredPolicies()
+	if len(filteredPolicies) == 0 {
+		return
+	}
+	if storedReport := s.getStoredReport(ctx, resource, clusterWide); storedReport != nil {
+		resourceReport.KeepResults(storedReport, filteredPolicies)
+	}
+}
+
+// getStoredReport returns the report stored for the given resource, or nil
+// when there's none.
+func (s *Scanner) getStoredReport(ctx context.Context, resource unstructured.Unstructured, clusterWide bool) report.Report {
+	var storedReport report.Report
+	var err error
+	if clusterWide {
+		storedReport, err = s.reportStore.GetClusterReport(ctx, resource)
+	} else {
+		storedReport, err = s.reportStore.GetReport(ctx, resource)
+	}
+	if err != nil {
+		if !errors.Is(err, constants.ErrResourceNotFound) {
+			s.logger.WarnContext(ctx, "failed to get the stored report",
+				slog.String("error", err.Error()),
+				slog.String("resource", resource.GetName()))
 		}
+		return nil
 	}
+
+	return storedReport
 }
 
 func policyMatches(policy policiesv1.Policy, resource unstructured.Unstructured) (bool, error) {
@@ -513,29 +770,129 @@ func policyMatches(policy policiesv1.Policy, resource unstructured.Unstructured)
 	return true, nil
 }
 
-func (s *Scanner) sendAdmissionReviewToPolicyServer(ctx context.Context, url *url.URL, admissionRequest *admissionv1.AdmissionReview) (*admissionv1.AdmissionReview, error) {
+// sendAdmissionReviewToPolicyServer sends the admission review to the
+// PolicyServer, retrying the retryable failures with a jittered exponential
+// backoff. The request fails right away when the circuit breaker of the
+// PolicyServer is open. Each attempt is sent to one of the ready replicas of
+// the PolicyServer of the given Service, resolved when the attempt is sent,
+// or to the PolicyServer URL when there are none or when the picked replica
+// cannot be reached. The first attempt
+// is sent with the given permission of the scheduler, when not nil, the
+// other ones wait for a new permission, in the given queue.
+func (s *Scanner) sendAdmissionReviewToPolicyServer(ctx context.Context, queue string, url *url.URL, service *policies.PolicyServerService, admissionRequest *admissionv1.AdmissionReview, release func()) (*admissionv1.AdmissionReview, error) {
+	// the permission is given back whatever the outcome of the first attempt
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
 	payload, err := json.Marshal(admissionRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode the admission request: %w", err)
 	}
 
+	policyServer := policyServerName(url)
+	breaker := s.circuitBreakers.get(policyServer)
+	// the retries of the request probing an open circuit are not rejected
+	// by the circuit breaker
+	probe := false
+	for attempt := 0; ; attempt++ {
+		if breaker != nil && !probe {
+			if probe, err = breaker.allow(time.Now()); err != nil {
+				return nil, fmt.Errorf("PolicyServer %s not available: %w", policyServer, err)
+			}
+		}
+
+		if release == nil {
+			if release, err = s.scheduler.acquire(ctx, queue, policyServer); err != nil {
+				return nil, err
+			}
+		}
+		endpoint := s.pickEndpoint(ctx, url, service)
+		admissionReview, err := s.postAdmissionReview(ctx, url, endpoint, payload)
+		if endpoint != nil && isDialError(err) {
+			// the replica is gone, e.g. replaced by a rollout: the replicas
+			// are resolved again, and the request is sent to the Service
+			// meanwhile
+			s.policiesClient.InvalidatePolicyServerEndpoints(service, endpoint.address)
+			admissionReview, err = s.postAdmissionReview(ctx, url, nil, payload)
+		}
+		release()
+		release = nil
+		var retryableErr *retryableError
+		if !errors.As(err, &retryableErr) {
+			if breaker != nil {
+				breaker.success()
+			}
+			return admissionReview, err
+		}
+
+		if ctx.Err() != nil {
+			// the PolicyServer is not to blame
+			return nil, err
+		}
+		if attempt >= s.policyServerRequests.MaxRetries {
+			if breaker != nil && breaker.failure(err, time.Now()) {
+				s.logger.WarnContext(ctx, "circuit breaker opened, not sending requests to the PolicyServer",
+					slog.String("policy-server", policyServer),
+					slog.String("error", err.Error()))
+			}
+			return nil, err
+		}
+
+		delay := httpclient.Backoff(attempt, s.policyServerRequests.InitialBackoff, s.policyServerRequests.MaxBackoff)
+		s.logger.DebugContext(ctx, "retrying request to PolicyServer",
+			slog.String("policy-server", policyServer),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", delay),
+			slog.String("error", err.Error()))
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("request to policy server canceled: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// policyServerName identifies the PolicyServer of the given URL, for the
+// scheduler and the circuit breakers.
+func policyServerName(url *url.URL) string {
+	return url.Scheme + "://" + url.Host
+}
+
+// postAdmissionReview sends a single request to the PolicyServer, through the
+// given endpoint when not nil. The failures worth a retry are returned as a
+// retryableError.
+func (s *Scanner) postAdmissionReview(ctx context.Context, url *url.URL, endpoint *policyServerEndpoint, payload []byte) (*admissionv1.AdmissionReview, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, fmt.Errorf("failed to build the policy server request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/json")
 
-	res, err := s.httpClient.Do(req)
+	httpClient := &s.httpClient
+	if endpoint != nil {
+		httpClient = endpoint.httpClient
+		endpoint.outstanding.Add(1)
+		defer endpoint.outstanding.Add(-1)
+	}
+
+	res, err := httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request to policy server failed: %w ", err)
+		return nil, &retryableError{fmt.Errorf("request to policy server failed: %w ", err)}
 	}
 	defer res.Body.Close()
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read body of response: %w", err)
+		return nil, &retryableError{fmt.Errorf("cannot read body of response: %w", err)}
 	}
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d body: %s", res.StatusCode, body)
+		err = fmt.Errorf("unexpected status code: %d body: %s", res.StatusCode, body)
+		if isRetryableStatusCode(res.StatusCode) {
+			return nil, &retryableError{err}
+		}
+		return nil, err
 	}
 
 	admissionReview := admissionv1.AdmissionReview{}
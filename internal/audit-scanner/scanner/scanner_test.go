@@ -1,19 +1,25 @@
 package scanner
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
 	auditConstants "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/output"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
 	auditscheme "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/scheme"
@@ -26,22 +32,24 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apimachineryErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	dynamicFake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	testingclient "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
 
 	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
 )
 
 const (
-	parallelNamespacesAudits = 1
-	parallelResourcesAudits  = 10
-	parallelPoliciesAudits   = 2
-	pageSize                 = 100
+	parallelResourcesAudits = 10
+	maxInflightRequests     = 4
+	pageSize                = 100
 )
 
 func newTestConfig(policiesClient *policies.Client, k8sClient *k8s.Client, reportStore report.Store) Config {
@@ -50,9 +58,8 @@ func newTestConfig(policiesClient *policies.Client, k8sClient *k8s.Client, repor
 		K8sClient:      k8sClient,
 		ReportStore:    reportStore,
 		Parallelization: ParallelizationConfig{
-			ParallelNamespacesAudits: parallelNamespacesAudits,
-			ParallelResourcesAudits:  parallelResourcesAudits,
-			PoliciesAudits:           parallelPoliciesAudits,
+			ParallelResourcesAudits: parallelResourcesAudits,
+			MaxInflightRequests:     maxInflightRequests,
 		},
 		OutputScan:   false,
 		DisableStore: false,
@@ -61,25 +68,149 @@ func newTestConfig(policiesClient *policies.Client, k8sClient *k8s.Client, repor
 	}
 }
 
-func newMockPolicyServer() *httptest.Server {
-	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
-		writer.WriteHeader(http.StatusOK)
+// newPodsTestConfig returns the configuration of a scanner auditing the pods
+// of the given namespaces with a ClusterAdmissionPolicy, evaluated by a mock
+// PolicyServer.
+func newPodsTestConfig(t *testing.T, namespaces []*corev1.Namespace, pods []*corev1.Pod) (Config, client.Client, *dynamicFake.FakeDynamicClient) {
+	t.Helper()
 
-		admissionReview := admissionv1.AdmissionReview{
-			Response: &admissionv1.AdmissionResponse{
-				Allowed: true,
-				Result:  nil,
+	mockPolicyServer := newMockPolicyServer()
+	t.Cleanup(mockPolicyServer.Close)
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
 			},
-		}
-		response, err := json.Marshal(admissionReview)
-		if err != nil {
-			writer.WriteHeader(http.StatusInternalServerError)
-		}
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+	clusterAdmissionPolicy := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
 
-		_, err = writer.Write(response)
-		if err != nil {
-			writer.WriteHeader(http.StatusInternalServerError)
-		}
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	objects := []runtime.Object{}
+	clientObjects := []runtime.Object{policyServer, policyServerService, clusterAdmissionPolicy}
+	for _, namespace := range namespaces {
+		objects = append(objects, namespace)
+		clientObjects = append(clientObjects, namespace)
+	}
+	namespacedObjects := append([]runtime.Object{}, objects...)
+	for _, pod := range pods {
+		namespacedObjects = append(namespacedObjects, pod)
+	}
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(auditScheme, namespacedObjects...)
+	clientset := fake.NewClientset(objects...)
+	fakeClient, err := testutils.NewFakeClient(clientObjects...)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(fakeClient, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	policyReportStore := report.NewPolicyReportStore(fakeClient, report.WriterConfig{}, logger)
+
+	return newTestConfig(policiesClient, k8sClient, policyReportStore), fakeClient, dynamicClient
+}
+
+// newPodsTestScanner returns a scanner auditing the pods of the given
+// namespaces with a ClusterAdmissionPolicy, without checkpoints.
+func newPodsTestScanner(t *testing.T, namespaces []*corev1.Namespace, pods []*corev1.Pod) (*Scanner, client.Client, *dynamicFake.FakeDynamicClient) {
+	t.Helper()
+
+	config, fakeClient, dynamicClient := newPodsTestConfig(t, namespaces, pods)
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	return scanner, fakeClient, dynamicClient
+}
+
+// newTestPod returns a pod whose UID is derived from its name.
+func newTestPod(name, namespace string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			UID:       types.UID(name + "-uid"),
+		},
+	}
+}
+
+// toUnstructured converts the given object, the resources returned by the
+// dynamic client.
+func toUnstructured(t *testing.T, obj runtime.Object, gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	t.Helper()
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	require.NoError(t, err)
+	resource := &unstructured.Unstructured{Object: content}
+	resource.SetGroupVersionKind(gvk)
+
+	return resource
+}
+
+// hasPolicyReport returns true when the report of the given pod is stored.
+func hasPolicyReport(t *testing.T, fakeClient client.Client, pod *corev1.Pod) bool {
+	t.Helper()
+
+	err := fakeClient.Get(t.Context(), types.NamespacedName{Name: string(pod.GetUID()), Namespace: pod.GetNamespace()}, &wgpolicy.PolicyReport{})
+	if apimachineryErrors.IsNotFound(err) {
+		return false
+	}
+	require.NoError(t, err)
+	return true
+}
+
+func allowedAdmissionReviewHandler(writer http.ResponseWriter, _ *http.Request) {
+	writer.WriteHeader(http.StatusOK)
+
+	admissionReview := admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: true,
+			Result:  nil,
+		},
+	}
+	response, err := json.Marshal(admissionReview)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}
+
+	_, err = writer.Write(response)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func newMockPolicyServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(allowedAdmissionReviewHandler))
+}
+
+func newMockPolicyServerWithRequestCounter(requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		allowedAdmissionReviewHandler(writer, r)
 	}))
 }
 
@@ -390,9 +521,9 @@ func TestScanAllNamespacesWithPolicyReport(t *testing.T) {
 	logger := slog.Default()
 	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
 
-	policyReportStore := report.NewPolicyReportStore(client, logger)
+	policyReportStore := report.NewPolicyReportStore(client, report.WriterConfig{}, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
 	scanner, err := NewScanner(config)
@@ -596,9 +727,9 @@ func TestScanClusterWideResourcesWithPolicyReport(t *testing.T) {
 	logger := slog.Default()
 	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
 
-	policyReportStore := report.NewPolicyReportStore(client, logger)
+	policyReportStore := report.NewPolicyReportStore(client, report.WriterConfig{}, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
 	scanner, err := NewScanner(config)
@@ -706,9 +837,9 @@ func TestScanWithHTTPErrors(t *testing.T) {
 	logger := slog.Default()
 	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServerWithErrors.URL, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServerWithErrors.URL, nil, logger)
 
-	policyReportStore := report.NewPolicyReportStore(client, logger)
+	policyReportStore := report.NewPolicyReportStore(client, report.WriterConfig{}, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
 	scanner, err := NewScanner(config)
@@ -829,9 +960,9 @@ func TestScanWithMTLS(t *testing.T) {
 	logger := slog.Default()
 	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
 
-	policyReportStore := report.NewPolicyReportStore(client, logger)
+	policyReportStore := report.NewPolicyReportStore(client, report.WriterConfig{}, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
 	config.TLS = TLSConfig{
@@ -965,8 +1096,8 @@ func TestScanFailureClusterWideResources(t *testing.T) {
 
 	logger := slog.Default()
 	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, 1, logger)
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
-	policyReportStore := report.NewPolicyReportStore(client, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	policyReportStore := report.NewPolicyReportStore(client, report.WriterConfig{}, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
 	scanner, err := NewScanner(config)
@@ -1261,8 +1392,8 @@ func TestScanFailureAllNamespaces(t *testing.T) {
 
 	logger := slog.Default()
 	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
-	policyReportStore := report.NewPolicyReportStore(client, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	policyReportStore := report.NewPolicyReportStore(client, report.WriterConfig{}, logger)
 
 	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
 	scanner, err := NewScanner(config)
@@ -1551,9 +1682,9 @@ func TestScanAllNamespacesWithOpenReport(t *testing.T) {
 	logger := slog.Default()
 	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
 
-	openReportStore := report.NewOpenReportStore(client, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
 	config := newTestConfig(policiesClient, k8sClient, openReportStore)
 	config.ReportKind = report.ReportKindOpenReport
 	scanner, err := NewScanner(config)
@@ -1756,9 +1887,9 @@ func TestScanClusterWideResourcesWithOpenReport(t *testing.T) {
 	logger := slog.Default()
 	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
 
-	policiesClient := policies.NewClient(client, "kubewarden", mockPolicyServer.URL, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
 
-	openReportStore := report.NewOpenReportStore(client, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
 	config := newTestConfig(policiesClient, k8sClient, openReportStore)
 	config.ReportKind = report.ReportKindOpenReport
 	scanner, err := NewScanner(config)
@@ -1787,3 +1918,696 @@ func TestScanClusterWideResourcesWithOpenReport(t *testing.T) {
 	assert.Len(t, clusterPolicyReport.Results, 3)
 	assert.Equal(t, runUID, clusterPolicyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
 }
+
+func TestIncrementalScanReusesUnchangedResults(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithRequestCounter(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+		},
+	}
+
+	pod1 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod1",
+			Namespace:       "namespace1",
+			UID:             "pod1-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	// an AdmissionPolicy targeting pods in namespace1
+	admissionPolicy1 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy1").
+		Namespace("namespace1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		pod1,
+		namespace1)
+	clientset := fake.NewClientset(
+		namespace1,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		admissionPolicy1,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	config.Incremental = true
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	// first scan, there is no previous report
+	err = scanner.ScanNamespace(t.Context(), "namespace1", uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// second scan, neither the resource nor the policy changed
+	runUID := uuid.New().String()
+	err = scanner.ScanNamespace(t.Context(), "namespace1", runUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+
+	policyReport := openreports.Report{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod1.GetUID()), Namespace: "namespace1"}, &policyReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, policyReport.Summary.Pass)
+	assert.Len(t, policyReport.Results, 1)
+	assert.Equal(t, runUID, policyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+
+	// third scan, the policy changed and must be evaluated again
+	admissionPolicy1.Spec.Settings = runtime.RawExtension{Raw: []byte(`{"foo":"bar"}`)}
+	err = client.Update(t.Context(), admissionPolicy1)
+	require.NoError(t, err)
+
+	err = scanner.ScanNamespace(t.Context(), "namespace1", uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestPolicyFilteredScanKeepsTheResultsOfTheOtherPolicies(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithRequestCounter(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+		},
+	}
+
+	pod1 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod1",
+			Namespace:       "namespace1",
+			UID:             "pod1-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	// two AdmissionPolicies targeting pods in namespace1
+	admissionPolicy1 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy1").
+		Namespace("namespace1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+	admissionPolicy2 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy2").
+		Namespace("namespace1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		pod1,
+		namespace1)
+	clientset := fake.NewClientset(
+		namespace1,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		admissionPolicy1,
+		admissionPolicy2,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	// first scan, all the policies are evaluated
+	err = scanner.ScanNamespace(t.Context(), "namespace1", uuid.New().String())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+
+	// second scan, restricted to the first policy
+	policiesClient.SetFilter(policies.Filter{Policies: []string{admissionPolicy1.GetUniqueName()}})
+	runUID := uuid.New().String()
+	err = scanner.ScanNamespace(t.Context(), "namespace1", runUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+
+	policyReport := openreports.Report{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod1.GetUID()), Namespace: "namespace1"}, &policyReport)
+	require.NoError(t, err)
+	assert.Equal(t, runUID, policyReport.GetLabels()[auditConstants.AuditScannerRunUIDLabel])
+	assert.Equal(t, 2, policyReport.Summary.Pass)
+	require.Len(t, policyReport.Results, 2)
+	policyNames := []string{policyReport.Results[0].Policy, policyReport.Results[1].Policy}
+	assert.ElementsMatch(t, []string{admissionPolicy1.GetUniqueName(), admissionPolicy2.GetUniqueName()}, policyNames)
+}
+
+func TestScanClusterWideResourcesWithMatchConditions(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+			UID:  "namespace1-uid",
+		},
+	}
+
+	namespaceRule := admissionregistrationv1.Rule{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"namespaces"},
+	}
+
+	// a ClusterAdmissionPolicy with a matchCondition that matches namespace1
+	clusterAdmissionPolicy1 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(namespaceRule).
+		MatchCondition("is-namespace1", "object.metadata.name == 'namespace1'").
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// a ClusterAdmissionPolicy with a matchCondition that does not match namespace1, should not be evaluated
+	clusterAdmissionPolicy2 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy2").
+		Rule(namespaceRule).
+		MatchCondition("is-kube-system", "object.metadata.name == 'kube-system'").
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// a ClusterAdmissionPolicy with a matchCondition that cannot be evaluated, should be reported as error
+	clusterAdmissionPolicy3 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy3").
+		Rule(namespaceRule).
+		MatchCondition("has-labels", "object.metadata.labels['env'] == 'prod'").
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		scheme.Scheme,
+		namespace1,
+	)
+	clientset := fake.NewClientset(
+		namespace1,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy1,
+		clusterAdmissionPolicy2,
+		clusterAdmissionPolicy3,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	err = scanner.ScanClusterWideResources(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+
+	clusterReport := openreports.ClusterReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace1.GetUID())}, &clusterReport)
+	require.NoError(t, err)
+	assert.Equal(t, 1, clusterReport.Summary.Pass)
+	assert.Equal(t, 1, clusterReport.Summary.Error)
+	require.Len(t, clusterReport.Results, 2)
+
+	for _, result := range clusterReport.Results {
+		switch result.Policy {
+		case clusterAdmissionPolicy1.GetUniqueName():
+			assert.Equal(t, openreports.Result("pass"), result.Result)
+		case clusterAdmissionPolicy3.GetUniqueName():
+			assert.Equal(t, openreports.Result("error"), result.Result)
+			assert.Contains(t, result.Description, "matchConditions")
+		default:
+			t.Errorf("unexpected result for policy %s", result.Policy)
+		}
+	}
+}
+
+func TestScanClusterWideResourcesWithUpdateOnlyPolicy(t *testing.T) {
+	// the mock policy server rejects the UPDATE requests without the old object
+	mockPolicyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		admissionReview := admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(&admissionReview); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if admissionReview.Request.Operation == admissionv1.Update &&
+			!bytes.Equal(admissionReview.Request.OldObject.Raw, admissionReview.Request.Object.Raw) {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		allowedAdmissionReviewHandler(writer, r)
+	}))
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+			UID:  "namespace1-uid",
+		},
+	}
+
+	namespaceRule := admissionregistrationv1.Rule{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"namespaces"},
+	}
+
+	// a ClusterAdmissionPolicy targeting the CREATE operation
+	clusterAdmissionPolicy1 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(namespaceRule, admissionregistrationv1.Create, admissionregistrationv1.Update).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// a ClusterAdmissionPolicy targeting only the UPDATE operation
+	clusterAdmissionPolicy2 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy2").
+		Rule(namespaceRule, admissionregistrationv1.Update).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		scheme.Scheme,
+		namespace1,
+	)
+	clientset := fake.NewClientset(
+		namespace1,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy1,
+		clusterAdmissionPolicy2,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	err = scanner.ScanClusterWideResources(t.Context(), uuid.New().String())
+	require.NoError(t, err)
+
+	clusterReport := openreports.ClusterReport{}
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(namespace1.GetUID())}, &clusterReport)
+	require.NoError(t, err)
+	assert.Equal(t, 2, clusterReport.Summary.Pass)
+	require.Len(t, clusterReport.Results, 2)
+
+	for _, result := range clusterReport.Results {
+		switch result.Policy {
+		case clusterAdmissionPolicy1.GetUniqueName():
+			assert.Equal(t, "CREATE", result.Properties["operation"])
+		case clusterAdmissionPolicy2.GetUniqueName():
+			assert.Equal(t, "UPDATE", result.Properties["operation"])
+		default:
+			t.Errorf("unexpected result for policy %s", result.Policy)
+		}
+	}
+}
+
+func TestScanClusterWideResourcesWritesOutput(t *testing.T) {
+	mockPolicyServer := newMockPolicyServer()
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+			UID:  "namespace1-uid",
+		},
+	}
+
+	clusterAdmissionPolicy1 := testutils.
+		NewClusterAdmissionPolicyFactory().
+		Name("clusterAdmissionPolicy1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"namespaces"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		scheme.Scheme,
+		namespace1,
+	)
+	clientset := fake.NewClientset(
+		namespace1,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		clusterAdmissionPolicy1,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
+
+	out := bytes.Buffer{}
+	writer, err := output.NewWriter(output.FormatNDJSON, &out)
+	require.NoError(t, err)
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	config.Output = writer
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	runUID := uuid.New().String()
+	require.NoError(t, scanner.ScanClusterWideResources(t.Context(), runUID))
+	require.NoError(t, writer.Close())
+
+	entry := report.ResultEntry{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, runUID, entry.RunUID)
+	assert.Equal(t, clusterAdmissionPolicy1.GetUniqueName(), entry.Policy)
+	assert.Equal(t, report.ResourceReference{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       "namespace1",
+		UID:        "namespace1-uid",
+	}, entry.Resource)
+	assert.Equal(t, report.ResultPass, entry.Result)
+}
+
+func TestScanNamespaceInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	// the scan is interrupted while the policy is evaluated
+	mockPolicyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		cancel()
+		allowedAdmissionReviewHandler(writer, r)
+	}))
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+		},
+	}
+
+	pod1 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "namespace1",
+			UID:       "pod1-uid",
+		},
+	}
+
+	admissionPolicy1 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy1").
+		Namespace("namespace1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	// a policy report of a previous run, it must be kept
+	oldPolicyReport := testutils.NewPolicyReportFactory().
+		Name("oldPolicyReport").
+		Namespace(namespace1.GetName()).
+		WithAppLabel().
+		RunUID(uuid.New().String()).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		pod1,
+		namespace1,
+		oldPolicyReport)
+	clientset := fake.NewClientset(
+		namespace1,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		admissionPolicy1,
+		oldPolicyReport,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	policyReportStore := report.NewPolicyReportStore(client, report.WriterConfig{}, logger)
+
+	scanner, err := NewScanner(newTestConfig(policiesClient, k8sClient, policyReportStore))
+	require.NoError(t, err)
+
+	runUID := uuid.New().String()
+	err = scanner.ScanNamespace(ctx, "namespace1", runUID)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// the old report is not deleted, the report of the interrupted audit is not stored
+	require.NoError(t, client.Get(t.Context(), types.NamespacedName{Name: oldPolicyReport.GetName(), Namespace: oldPolicyReport.GetNamespace()}, oldPolicyReport))
+	err = client.Get(t.Context(), types.NamespacedName{Name: string(pod1.GetUID()), Namespace: "namespace1"}, &wgpolicy.PolicyReport{})
+	require.True(t, apimachineryErrors.IsNotFound(err))
+
+	runSummary, err := scanner.FinishRun(t.Context(), runUID)
+	require.NoError(t, err)
+	assert.True(t, runSummary.Incomplete)
+	assert.Zero(t, runSummary.ResourcesAudited)
+}
+
+func TestScanAllNamespacesSharesResourceAudits(t *testing.T) {
+	namespaces := []*corev1.Namespace{}
+	pods := []*corev1.Pod{}
+	for i := range 4 {
+		namespace := fmt.Sprintf("namespace%d", i)
+		namespaces = append(namespaces, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		pods = append(pods, newTestPod(namespace+"-pod1", namespace), newTestPod(namespace+"-pod2", namespace))
+	}
+
+	// a single resource audit at a time, shared by all the namespaces
+	config, fakeClient, _ := newPodsTestConfig(t, namespaces, pods)
+	config.Parallelization.ParallelResourcesAudits = 1
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 30*time.Second)
+	defer cancel()
+	require.NoError(t, scanner.ScanAllNamespaces(ctx, "run-uid"))
+	for _, pod := range pods {
+		assert.True(t, hasPolicyReport(t, fakeClient, pod), pod.Name)
+	}
+}
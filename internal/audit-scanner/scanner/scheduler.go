@@ -0,0 +1,158 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// requestWaiter is a request waiting for the permission to be sent to a
+// PolicyServer.
+type requestWaiter struct {
+	policyServer string
+	// granted is closed once the request can be sent
+	granted chan struct{}
+}
+
+// requestScheduler bounds the requests sent at the same time to all the
+// PolicyServers and to each of them. All the audits, cluster-wide and
+// namespaced, go through the same scheduler. The requests are queued by
+// namespace, the cluster-wide resources having their own queue, and the
+// queues are served in turn when a request completes, so a namespace with
+// many resources cannot starve the others.
+type requestScheduler struct {
+	mutex sync.Mutex
+	// maxInflight is the maximum number of requests sent at the same time
+	maxInflight int
+	// maxInflightPerPolicyServer is the maximum number of requests sent at
+	// the same time to a PolicyServer. 0 means no limit, besides maxInflight
+	maxInflightPerPolicyServer int
+	inflight                   int
+	inflightByPolicyServer     map[string]int
+	// queues holds the waiting requests in FIFO order, by queue name
+	queues map[string][]*requestWaiter
+	// order holds the names of the queues with waiting requests, served in
+	// round-robin
+	order []string
+	// next is the index in order of the next queue served
+	next int
+}
+
+func newRequestScheduler(maxInflight, maxInflightPerPolicyServer int) *requestScheduler {
+	return &requestScheduler{
+		maxInflight:                max(maxInflight, 1),
+		maxInflightPerPolicyServer: maxInflightPerPolicyServer,
+		inflightByPolicyServer:     map[string]int{},
+		queues:                     map[string][]*requestWaiter{},
+	}
+}
+
+// acquire waits for the permission to send a request to the given
+// PolicyServer, queued in the given queue. The returned function must be
+// called once the request completed.
+func (s *requestScheduler) acquire(ctx context.Context, queue, policyServer string) (func(), error) {
+	waiter := &requestWaiter{
+		policyServer: policyServer,
+		granted:      make(chan struct{}),
+	}
+
+	s.mutex.Lock()
+	if len(s.queues[queue]) == 0 {
+		s.order = append(s.order, queue)
+	}
+	s.queues[queue] = append(s.queues[queue], waiter)
+	s.dispatch()
+	s.mutex.Unlock()
+
+	release := func() { s.release(policyServer) }
+
+	select {
+	case <-waiter.granted:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-waiter.granted:
+		// granted in the meantime, give the permission back
+		s.put(policyServer)
+	default:
+		s.remove(queue, waiter)
+	}
+	return nil, fmt.Errorf("request to policy server canceled: %w", ctx.Err())
+}
+
+func (s *requestScheduler) release(policyServer string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.put(policyServer)
+}
+
+// put gives back the permission of a request to the given PolicyServer and
+// grants it to the waiting requests. It must be called with the mutex held.
+func (s *requestScheduler) put(policyServer string) {
+	s.inflight--
+	s.inflightByPolicyServer[policyServer]--
+	if s.inflightByPolicyServer[policyServer] == 0 {
+		delete(s.inflightByPolicyServer, policyServer)
+	}
+	s.dispatch()
+}
+
+// dispatch grants the free permissions to the waiting requests, taking the
+// first request of each queue in turn. The requests to the PolicyServers
+// with no free permission are left waiting, without blocking the requests to
+// the other PolicyServers queued after them. It must be called with the
+// mutex held.
+func (s *requestScheduler) dispatch() {
+	for s.inflight < s.maxInflight && len(s.order) > 0 {
+		granted := false
+		for range len(s.order) {
+			s.next %= len(s.order)
+			queue := s.order[s.next]
+			index := slices.IndexFunc(s.queues[queue], func(waiter *requestWaiter) bool {
+				return s.maxInflightPerPolicyServer <= 0 || s.inflightByPolicyServer[waiter.policyServer] < s.maxInflightPerPolicyServer
+			})
+			if index < 0 {
+				s.next++
+				continue
+			}
+
+			waiter := s.queues[queue][index]
+			s.inflight++
+			s.inflightByPolicyServer[waiter.policyServer]++
+			close(waiter.granted)
+			// the queue is served after the other ones next time
+			s.next++
+			s.remove(queue, waiter)
+			granted = true
+			break
+		}
+		if !granted {
+			return
+		}
+	}
+}
+
+// remove removes the waiter from the queue, and the queue from the
+// round-robin order once empty. It must be called with the mutex held.
+func (s *requestScheduler) remove(queue string, waiter *requestWaiter) {
+	s.queues[queue] = slices.DeleteFunc(s.queues[queue], func(queued *requestWaiter) bool {
+		return queued == waiter
+	})
+	if len(s.queues[queue]) > 0 {
+		return
+	}
+
+	delete(s.queues, queue)
+	index := slices.Index(s.order, queue)
+	s.order = slices.Delete(s.order, index, index+1)
+	// keep serving the queue following the removed one
+	if index < s.next {
+		s.next--
+	}
+}
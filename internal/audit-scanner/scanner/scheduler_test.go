@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acquireAsync waits for the permission in a goroutine. The returned channel
+// receives the release function once granted.
+func acquireAsync(ctx context.Context, scheduler *requestScheduler, queue, policyServer string) <-chan func() {
+	granted := make(chan func(), 1)
+	go func() {
+		release, err := scheduler.acquire(ctx, queue, policyServer)
+		if err == nil {
+			granted <- release
+		}
+	}()
+	return granted
+}
+
+// waitQueued waits until the given number of requests are waiting.
+func waitQueued(t *testing.T, scheduler *requestScheduler, waiting int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		scheduler.mutex.Lock()
+		defer scheduler.mutex.Unlock()
+		queued := 0
+		for _, queue := range scheduler.queues {
+			queued += len(queue)
+		}
+		return queued == waiting
+	}, time.Second, time.Millisecond)
+}
+
+func TestRequestSchedulerLimits(t *testing.T) {
+	scheduler := newRequestScheduler(3, 2)
+
+	release1, err := scheduler.acquire(t.Context(), "namespace1", "policy-server-a")
+	require.NoError(t, err)
+	_, err = scheduler.acquire(t.Context(), "namespace1", "policy-server-a")
+	require.NoError(t, err)
+
+	// the PolicyServer limit is reached, the other PolicyServers are not blocked
+	blocked := acquireAsync(t.Context(), scheduler, "namespace1", "policy-server-a")
+	waitQueued(t, scheduler, 1)
+	_, err = scheduler.acquire(t.Context(), "namespace1", "policy-server-b")
+	require.NoError(t, err)
+
+	// the global limit is reached
+	blockedB := acquireAsync(t.Context(), scheduler, "namespace1", "policy-server-b")
+	waitQueued(t, scheduler, 2)
+
+	// a request to the first PolicyServer completed, its waiting request is sent
+	release1()
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		require.Fail(t, "the request to the PolicyServer was not granted")
+	}
+	select {
+	case <-blockedB:
+		require.Fail(t, "the global limit was exceeded")
+	default:
+	}
+}
+
+func TestRequestSchedulerFairness(t *testing.T) {
+	scheduler := newRequestScheduler(1, 0)
+	release, err := scheduler.acquire(t.Context(), "busy", "policy-server")
+	require.NoError(t, err)
+
+	// a namespace with many resources queues its requests first
+	var mutex sync.Mutex
+	grantedQueues := []string{}
+	var workers sync.WaitGroup
+	acquire := func(queue string) {
+		workers.Go(func() {
+			release, err := scheduler.acquire(t.Context(), queue, "policy-server")
+			if !assert.NoError(t, err) {
+				return
+			}
+			mutex.Lock()
+			grantedQueues = append(grantedQueues, queue)
+			mutex.Unlock()
+			release()
+		})
+	}
+	for waiting := range 3 {
+		acquire("busy")
+		waitQueued(t, scheduler, waiting+1)
+	}
+	acquire("small")
+	waitQueued(t, scheduler, 4)
+	acquire("")
+	waitQueued(t, scheduler, 5)
+
+	release()
+	workers.Wait()
+
+	// the queues are served in turn
+	assert.Equal(t, []string{"busy", "small", "", "busy", "busy"}, grantedQueues)
+}
+
+func TestRequestSchedulerCanceled(t *testing.T) {
+	scheduler := newRequestScheduler(1, 0)
+	release, err := scheduler.acquire(t.Context(), "namespace1", "policy-server")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	canceled := make(chan error)
+	go func() {
+		_, err := scheduler.acquire(ctx, "namespace2", "policy-server")
+		canceled <- err
+	}()
+	waitQueued(t, scheduler, 1)
+	cancel()
+	require.ErrorIs(t, <-canceled, context.Canceled)
+	waitQueued(t, scheduler, 0)
+
+	// the canceled request did not take the permission
+	release()
+	release, err = scheduler.acquire(t.Context(), "namespace1", "policy-server")
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"time"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+)
+
+// shardsPollInterval is the interval between the checks of the shards
+// finished by the coordinator of a sharded run
+const shardsPollInterval = 10 * time.Second
+
+// ShardConfig splits the audit among several scanner replicas. Each replica
+// audits the namespaces and the cluster-wide resources of its shard.
+type ShardConfig struct {
+	// Index of the shard audited by this replica, from 0 to Count - 1
+	Index int
+	// Count is the number of shards. 0 and 1 disable the sharding
+	Count int
+}
+
+func (c ShardConfig) sharded() bool {
+	return c.Count > 1
+}
+
+// owns returns true when the given key, a namespace name or a cluster-wide
+// resource, belongs to the shard.
+func (c ShardConfig) owns(key string) bool {
+	if !c.sharded() {
+		return true
+	}
+	return shardOf(key, c.Count) == c.Index
+}
+
+// shardOf returns the shard of the given key. The keys are assigned with
+// rendezvous hashing: changing the number of shards only moves the keys of
+// the added or removed shards.
+func shardOf(key string, count int) int {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(key))
+	keyHash := hash.Sum64()
+
+	owner := 0
+	var highest uint64
+	for shard := range count {
+		weight := mix64(keyHash ^ uint64(shard)*0x9e3779b97f4a7c15) //nolint:gosec // the shard index is not negative
+		if shard == 0 || weight > highest {
+			owner = shard
+			highest = weight
+		}
+	}
+	return owner
+}
+
+// mix64 is the finalizer of splitmix64, it spreads the bits of the given
+// value so the weights of the shards of a key are not correlated.
+func mix64(value uint64) uint64 {
+	value = (value ^ (value >> 30)) * 0xbf58476d1ce4e5b9
+	value = (value ^ (value >> 27)) * 0x94d049bb133111eb
+	return value ^ (value >> 31)
+}
+
+// FinishShardedRun coordinates a sharded run: it waits for all the shards of
+// the given run to store their summary, then deletes the reports of the
+// previous runs, as a run that is not sharded does once finished. The reports
+// of the previous runs are kept when the run of a shard is incomplete. When
+// clusterWideOnly is set, only the cluster-wide reports are deleted. The
+// reports of the policies of the previous runs are deleted with the other
+// ones, when the scanner stores them.
+func (s *Scanner) FinishShardedRun(ctx context.Context, runUID string, clusterWideOnly bool) error {
+	summaries, err := s.waitShards(ctx, runUID)
+	if err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		if summary.Incomplete {
+			s.logger.WarnContext(ctx, "incomplete shard run, keeping the reports of the previous runs",
+				slog.String("RunUID", runUID),
+				slog.Int("shard", summary.ShardIndex))
+			return fmt.Errorf("the run of shard %d is incomplete", summary.ShardIndex)
+		}
+	}
+	s.logger.InfoContext(ctx, "all the shards finished", slog.String("RunUID", runUID))
+
+	if s.policiesClient.Filtered() {
+		s.logger.InfoContext(ctx, "filtered audit, keeping the reports of the previous runs")
+		return nil
+	}
+	if err = s.reportStore.DeleteOldClusterReports(ctx, runUID); err != nil {
+		return fmt.Errorf("failed to delete old ClusterReports: %w", err)
+	}
+	if clusterWideOnly {
+		return nil
+	}
+	if s.policyCentricReports {
+		if err = s.reportStore.DeleteOldPolicyResultsReports(ctx, runUID); err != nil {
+			return fmt.Errorf("failed to delete the old reports of the policies: %w", err)
+		}
+	}
+
+	nsList, err := s.k8sClient.GetAuditedNamespaces(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the audited namespaces: %w", err)
+	}
+	for _, namespace := range nsList.Items {
+		if deleteErr := s.reportStore.DeleteOldReports(ctx, runUID, namespace.Name); deleteErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to delete old reports of namespace %s: %w", namespace.Name, deleteErr))
+		}
+	}
+	return err
+}
+
+// waitShards waits until all the shards of the given run stored their
+// summary, and returns them.
+func (s *Scanner) waitShards(ctx context.Context, runUID string) ([]report.RunSummary, error) {
+	if s.runSummaryStore == nil {
+		return nil, errors.New("the summaries of the shards are not stored, they cannot be waited for")
+	}
+	ticker := time.NewTicker(shardsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		summaries, err := s.runSummaryStore.GetRunSummaries(ctx, runUID, s.runSummaryNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the summaries of the shards: %w", err)
+		}
+		finished := map[int]report.RunSummary{}
+		for _, summary := range summaries {
+			if summary.ShardCount == s.shard.Count {
+				finished[summary.ShardIndex] = summary
+			}
+		}
+		if len(finished) == s.shard.Count {
+			shardSummaries := make([]report.RunSummary, 0, len(finished))
+			for shard := range s.shard.Count {
+				shardSummaries = append(shardSummaries, finished[shard])
+			}
+			return shardSummaries, nil
+		}
+
+		s.logger.InfoContext(ctx, "waiting for the shards to finish",
+			slog.String("RunUID", runUID),
+			slog.Int("finished", len(finished)),
+			slog.Int("shards", s.shard.Count))
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for the shards canceled: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	auditscheme "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/scheme"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+func TestShardOf(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("namespace-%d", i)
+	}
+
+	// the keys are spread among the shards
+	keysByShard := map[int]int{}
+	for _, key := range keys {
+		keysByShard[shardOf(key, 4)]++
+	}
+	require.Len(t, keysByShard, 4)
+	for shard, count := range keysByShard {
+		assert.InDelta(t, 250, count, 75, "shard %d", shard)
+	}
+
+	// adding a shard only moves keys to the new shard
+	moved := 0
+	for _, key := range keys {
+		if shard := shardOf(key, 5); shard != shardOf(key, 4) {
+			assert.Equal(t, 4, shard, key)
+			moved++
+		}
+	}
+	assert.InDelta(t, 200, moved, 75)
+
+	assert.True(t, ShardConfig{}.owns("namespace-1"))
+	assert.True(t, ShardConfig{Index: 0, Count: 1}.owns("namespace-1"))
+}
+
+func TestFinishShardedRun(t *testing.T) {
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+		},
+	}
+
+	runUID := uuid.New().String()
+	policyReport := testutils.NewPolicyReportFactory().
+		Name("policyReport").
+		Namespace(namespace1.GetName()).
+		WithAppLabel().
+		RunUID(runUID).
+		Build()
+	oldPolicyReport := testutils.NewPolicyReportFactory().
+		Name("oldPolicyReport").
+		Namespace(namespace1.GetName()).
+		WithAppLabel().
+		RunUID(uuid.New().String()).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(auditScheme, namespace1)
+	clientset := fake.NewClientset(namespace1)
+	client, err := testutils.NewFakeClient(namespace1, policyReport, oldPolicyReport)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", "", nil, logger)
+	policyReportStore := report.NewPolicyReportStore(client, report.WriterConfig{}, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, policyReportStore)
+	runSummaryStore := report.NewConfigMapStore(client, logger)
+	config.RunSummaryNamespace = "kubewarden"
+	config.RunSummaryStore = runSummaryStore
+	config.Shard = ShardConfig{Count: 2}
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	oldPolicyReportExists := func() bool {
+		err := client.Get(t.Context(), types.NamespacedName{Name: oldPolicyReport.GetName(), Namespace: oldPolicyReport.GetNamespace()}, &wgpolicy.PolicyReport{})
+		if apimachineryErrors.IsNotFound(err) {
+			return false
+		}
+		require.NoError(t, err)
+		return true
+	}
+
+	// the second shard is not finished
+	require.NoError(t, runSummaryStore.CreateOrPatchRunSummary(t.Context(), "kubewarden", &report.RunSummary{RunUID: runUID, ShardIndex: 0, ShardCount: 2}))
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, scanner.FinishShardedRun(ctx, runUID, false), context.DeadlineExceeded)
+	assert.True(t, oldPolicyReportExists())
+
+	// the second shard is interrupted
+	require.NoError(t, runSummaryStore.CreateOrPatchRunSummary(t.Context(), "kubewarden", &report.RunSummary{RunUID: runUID, ShardIndex: 1, ShardCount: 2, Incomplete: true}))
+	require.ErrorContains(t, scanner.FinishShardedRun(t.Context(), runUID, false), "the run of shard 1 is incomplete")
+	assert.True(t, oldPolicyReportExists())
+
+	// all the shards are finished
+	require.NoError(t, runSummaryStore.CreateOrPatchRunSummary(t.Context(), "kubewarden", &report.RunSummary{RunUID: runUID, ShardIndex: 1, ShardCount: 2}))
+	require.NoError(t, scanner.FinishShardedRun(t.Context(), runUID, false))
+	assert.False(t, oldPolicyReportExists())
+	require.NoError(t, client.Get(t.Context(), types.NamespacedName{Name: policyReport.GetName(), Namespace: policyReport.GetNamespace()}, &wgpolicy.PolicyReport{}))
+}
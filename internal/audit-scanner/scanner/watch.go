@@ -0,0 +1,573 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// cacheSyncTimeout is the time to wait for the informers to sync. Informers
+// of resources that cannot be listed never sync, the watcher continues without
+// them instead of blocking forever.
+const cacheSyncTimeout = time.Minute
+
+// watchedPolicyResources are the Kubewarden policies watched to detect when
+// the audited resources have to be evaluated again.
+var watchedPolicyResources = []string{
+	constants.KubewardenResourceClusterAdmissionPolicies,
+	constants.KubewardenResourceClusterAdmissionPolicyGroups,
+	constants.KubewardenResourceAdmissionPolicies,
+	constants.KubewardenResourceAdmissionPolicyGroups,
+}
+
+var namespacesGVR = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}
+
+// resourceKey identifies a resource to audit.
+type resourceKey struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// resourceInformer watches the resources of a GroupVersionResource. Each one
+// has its own factory, so it can be stopped once no policy targets its
+// resources anymore.
+type resourceInformer struct {
+	informers.GenericInformer
+	factory dynamicinformer.DynamicSharedInformerFactory
+	stop    context.CancelFunc
+}
+
+func (i *resourceInformer) shutdown() {
+	i.stop()
+	i.factory.Shutdown()
+}
+
+// watcher audits the resources when they change, or when the policies
+// targeting them change. The resources are watched with informers, one for
+// each GroupVersionResource targeted by the policies.
+type watcher struct {
+	scanner *Scanner
+	// factory holds the informers of the policies and of the namespaces
+	factory dynamicinformer.DynamicSharedInformerFactory
+	// queue holds the resources to audit. The same resource is never audited
+	// concurrently, and multiple changes are audited only once
+	queue workqueue.TypedInterface[resourceKey]
+	// refresh is signaled when the policies or the namespaces change
+	refresh chan struct{}
+
+	mu sync.RWMutex
+	// runUID labels the reports created by the watcher, it changes on each resync
+	runUID string
+	// clusterPolicies are the policies auditing the cluster-wide resources
+	clusterPolicies *policies.Policies
+	// namespacePolicies are the policies auditing the resources of each audited namespace
+	namespacePolicies map[string]*policies.Policies
+	// informers are the informers of the resources targeted by the
+	// policies, by GroupVersionResource
+	informers map[schema.GroupVersionResource]*resourceInformer
+}
+
+// Watch audits the resources continuously, until the context is canceled.
+// All the resources are scanned on start and every resyncPeriod, to catch
+// missed events and to remove the reports of the deleted policies. In between,
+// a resource is audited again when it changes, and all the resources targeted
+// by a policy are audited again when the policy changes.
+func (s *Scanner) Watch(ctx context.Context, resyncPeriod time.Duration) error {
+	if resyncPeriod <= 0 {
+		return fmt.Errorf("invalid resync period %s: it must be greater than zero", resyncPeriod)
+	}
+	factory, err := s.k8sClient.NewInformerFactory(0)
+	if err != nil {
+		return fmt.Errorf("failed to watch the resources: %w", err)
+	}
+
+	watcher := &watcher{
+		scanner:           s,
+		factory:           factory,
+		queue:             workqueue.NewTyped[resourceKey](),
+		refresh:           make(chan struct{}, 1),
+		namespacePolicies: map[string]*policies.Policies{},
+		informers:         map[schema.GroupVersionResource]*resourceInformer{},
+	}
+
+	return watcher.run(ctx, resyncPeriod)
+}
+
+func (w *watcher) run(ctx context.Context, resyncPeriod time.Duration) error {
+	w.scanner.logger.InfoContext(ctx, "watch started",
+		slog.Duration("resync-period", resyncPeriod),
+		slog.Int("parallel-resources-audits", w.scanner.parallelResourcesAudits))
+
+	if err := w.watchPolicies(ctx); err != nil {
+		return err
+	}
+	if err := w.refreshPolicies(ctx, false); err != nil {
+		return err
+	}
+
+	var workers sync.WaitGroup
+	for range w.scanner.parallelResourcesAudits {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+
+			for w.processNextResource(ctx) {
+			}
+		}()
+	}
+	defer func() {
+		w.queue.ShutDown()
+		workers.Wait()
+		w.factory.Shutdown()
+		w.stopInformers(ctx, nil)
+	}()
+
+	// the full resyncs run in their own worker, so the changes of the
+	// policies are handled in the meantime. A resync is skipped when the
+	// previous one is still running
+	var resyncs sync.WaitGroup
+	defer resyncs.Wait()
+	resyncDone := make(chan struct{}, 1)
+	resyncing := false
+	startResync := func() {
+		if resyncing {
+			w.scanner.logger.WarnContext(ctx, "the previous full resync is still running, skipping this one")
+			return
+		}
+		resyncing = true
+		resyncs.Go(func() {
+			w.resync(ctx)
+			resyncDone <- struct{}{}
+		})
+	}
+	startResync()
+
+	ticker := time.NewTicker(resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.scanner.logger.InfoContext(ctx, "watch finished")
+			return nil
+		case <-resyncDone:
+			resyncing = false
+		case <-ticker.C:
+			if err := w.refreshPolicies(ctx, false); err != nil {
+				w.scanner.logger.ErrorContext(ctx, "error refreshing policies", slog.String("error", err.Error()))
+			}
+			startResync()
+		case <-w.refresh:
+			if err := w.refreshPolicies(ctx, true); err != nil {
+				w.scanner.logger.ErrorContext(ctx, "error refreshing policies", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// resync scans all the resources with a new runUID, deleting the reports
+// created before.
+func (w *watcher) resync(ctx context.Context) {
+	runUID := uuid.New().String()
+	// the resources audited because of an event must get the new runUID too,
+	// otherwise their reports would be deleted at the end of the scan
+	w.mu.Lock()
+	w.runUID = runUID
+	w.mu.Unlock()
+
+	w.scanner.logger.InfoContext(ctx, "full resync started", slog.String("RunUID", runUID))
+	if err := w.scanner.ScanClusterWideResources(ctx, runUID); err != nil {
+		w.scanner.logger.ErrorContext(ctx, "error scanning cluster-wide resources", slog.String("error", err.Error()))
+	}
+	if err := w.scanner.ScanAllNamespaces(ctx, runUID); err != nil {
+		w.scanner.logger.ErrorContext(ctx, "error scanning namespaces", slog.String("error", err.Error()))
+	}
+	if _, err := w.scanner.FinishRun(ctx, runUID); err != nil {
+		w.scanner.logger.ErrorContext(ctx, "error finishing the run", slog.String("error", err.Error()))
+	}
+	w.scanner.logger.InfoContext(ctx, "full resync finished", slog.String("RunUID", runUID))
+}
+
+// watchPolicies requests a refresh of the policies when a policy or a
+// namespace changes. Namespace labels are used by the policies namespaceSelector.
+func (w *watcher) watchPolicies(ctx context.Context) error {
+	handler := cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(_ any, isInInitialList bool) {
+			if !isInInitialList {
+				w.requestRefresh()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			if resourceVersionChanged(oldObj, newObj) {
+				w.requestRefresh()
+			}
+		},
+		DeleteFunc: func(_ any) {
+			w.requestRefresh()
+		},
+	}
+
+	for _, resource := range watchedPolicyResources {
+		gvr := schema.GroupVersionResource{
+			Group:    constants.KubewardenPoliciesGroup,
+			Version:  constants.KubewardenPoliciesVersion,
+			Resource: resource,
+		}
+		if _, err := w.factory.ForResource(gvr).Informer().AddEventHandler(handler); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", gvr.String(), err)
+		}
+	}
+
+	namespaceHandler := handler
+	namespaceHandler.UpdateFunc = func(oldObj, newObj any) {
+		if labelsChanged(oldObj, newObj) {
+			w.requestRefresh()
+		}
+	}
+	if _, err := w.factory.ForResource(namespacesGVR).Informer().AddEventHandler(namespaceHandler); err != nil {
+		return fmt.Errorf("failed to watch namespaces: %w", err)
+	}
+
+	w.factory.Start(ctx.Done())
+	w.waitForCacheSync(ctx, w.factory)
+
+	return nil
+}
+
+func (w *watcher) requestRefresh() {
+	select {
+	case w.refresh <- struct{}{}:
+	default:
+		// a refresh is already pending
+	}
+}
+
+// refreshPolicies fetches the policies auditing the cluster-wide resources and
+// the resources of each namespace, starts the informers of the resources
+// targeted by them and stops the other ones. The cached discovery is
+// refreshed first, so the resources of the CRDs created since the last
+// refresh are targeted by the wildcard rules. When enqueueChanged is true,
+// the resources targeted by policies that changed are audited again.
+func (w *watcher) refreshPolicies(ctx context.Context, enqueueChanged bool) error {
+	w.scanner.policiesClient.InvalidateDiscovery()
+	clusterPolicies, err := w.scanner.policiesClient.GetClusterWidePolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to obtain cluster auditable policies: %w", err)
+	}
+	namespaceList, err := w.scanner.k8sClient.GetAuditedNamespaces(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to obtain the audited namespaces: %w", err)
+	}
+	namespacePolicies := make(map[string]*policies.Policies, len(namespaceList.Items))
+	for _, namespace := range namespaceList.Items {
+		namespacePolicies[namespace.Name], err = w.scanner.policiesClient.GetPoliciesByNamespace(ctx, &namespace)
+		if err != nil {
+			return fmt.Errorf("failed to obtain auditable policies for namespace %s: %w", namespace.Name, err)
+		}
+	}
+
+	gvrs := maps.Clone(clusterPolicies.PoliciesByGVR)
+	for _, nsPolicies := range namespacePolicies {
+		maps.Copy(gvrs, nsPolicies.PoliciesByGVR)
+	}
+	w.startInformers(ctx, slices.Collect(maps.Keys(gvrs)))
+
+	w.mu.Lock()
+	previousClusterPolicies := w.clusterPolicies
+	previousNamespacePolicies := w.namespacePolicies
+	w.clusterPolicies = clusterPolicies
+	w.namespacePolicies = namespacePolicies
+	w.mu.Unlock()
+	w.stopInformers(ctx, gvrs)
+
+	if !enqueueChanged {
+		return nil
+	}
+
+	for _, gvr := range changedGroupVersionResources(previousClusterPolicies, clusterPolicies) {
+		w.enqueueAll(gvr, "")
+	}
+	for namespace, nsPolicies := range namespacePolicies {
+		for _, gvr := range changedGroupVersionResources(previousNamespacePolicies[namespace], nsPolicies) {
+			w.enqueueAll(gvr, namespace)
+		}
+	}
+	// the reports of the resources no longer audited by any policy are
+	// deleted by the next resync
+
+	return nil
+}
+
+// startInformers starts the informers of the given resources not watched yet.
+func (w *watcher) startInformers(ctx context.Context, gvrs []schema.GroupVersionResource) {
+	var factories []dynamicinformer.DynamicSharedInformerFactory
+	for _, gvr := range gvrs {
+		w.mu.RLock()
+		_, found := w.informers[gvr]
+		w.mu.RUnlock()
+		if found {
+			continue
+		}
+
+		factory, err := w.scanner.k8sClient.NewInformerFactory(0)
+		if err != nil {
+			w.scanner.logger.ErrorContext(ctx, "failed to watch resources",
+				slog.String("error", err.Error()),
+				slog.String("resource-GVR", gvr.String()))
+			continue
+		}
+		informer := factory.ForResource(gvr)
+		_, err = informer.Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+			AddFunc: func(obj any, isInInitialList bool) {
+				// resources existing on start are audited by the full resync
+				if !isInInitialList {
+					w.enqueue(gvr, obj)
+				}
+			},
+			UpdateFunc: func(oldObj, newObj any) {
+				if resourceVersionChanged(oldObj, newObj) {
+					w.enqueue(gvr, newObj)
+				}
+			},
+			// reports are owned by the audited resources, they are garbage
+			// collected when the resources are deleted
+		})
+		if err != nil {
+			w.scanner.logger.ErrorContext(ctx, "failed to watch resources",
+				slog.String("error", err.Error()),
+				slog.String("resource-GVR", gvr.String()))
+			continue
+		}
+
+		informerCtx, stop := context.WithCancel(ctx)
+		factory.Start(informerCtx.Done())
+		w.mu.Lock()
+		w.informers[gvr] = &resourceInformer{GenericInformer: informer, factory: factory, stop: stop}
+		w.mu.Unlock()
+		factories = append(factories, factory)
+	}
+
+	w.waitForCacheSync(ctx, factories...)
+}
+
+// stopInformers stops the informers of the resources not in the given ones,
+// they are no longer targeted by any policy.
+func (w *watcher) stopInformers(ctx context.Context, gvrs map[schema.GroupVersionResource][]*policies.Policy) {
+	var stopped []*resourceInformer
+	w.mu.Lock()
+	for gvr, informer := range w.informers {
+		if _, found := gvrs[gvr]; found {
+			continue
+		}
+		delete(w.informers, gvr)
+		stopped = append(stopped, informer)
+		w.scanner.logger.DebugContext(ctx, "resources no longer targeted by any policy, stopped watching them",
+			slog.String("resource-GVR", gvr.String()))
+	}
+	w.mu.Unlock()
+
+	for _, informer := range stopped {
+		informer.shutdown()
+	}
+}
+
+// waitForCacheSync waits for the informers started by the given factories to
+// sync, at most for cacheSyncTimeout.
+func (w *watcher) waitForCacheSync(ctx context.Context, factories ...dynamicinformer.DynamicSharedInformerFactory) {
+	syncCtx, cancel := context.WithTimeout(ctx, cacheSyncTimeout)
+	defer cancel()
+	for _, factory := range factories {
+		for gvr, synced := range factory.WaitForCacheSync(syncCtx.Done()) {
+			if !synced {
+				w.scanner.logger.WarnContext(ctx, "failed to sync the resources cache, changes are detected on the next resync",
+					slog.String("resource-GVR", gvr.String()))
+			}
+		}
+	}
+}
+
+func (w *watcher) enqueue(gvr schema.GroupVersionResource, obj any) {
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	w.queue.Add(resourceKey{
+		gvr:       gvr,
+		namespace: object.GetNamespace(),
+		name:      object.GetName(),
+	})
+}
+
+// enqueueAll enqueues all the resources of the given namespace. An empty
+// namespace means the cluster-wide resources.
+func (w *watcher) enqueueAll(gvr schema.GroupVersionResource, namespace string) {
+	w.mu.RLock()
+	informer, found := w.informers[gvr]
+	w.mu.RUnlock()
+	if !found {
+		return
+	}
+
+	var objects []any
+	if namespace == "" {
+		objects = informer.Informer().GetStore().List()
+	} else {
+		resources, err := informer.Lister().ByNamespace(namespace).List(labels.Everything())
+		if err != nil {
+			return
+		}
+		for _, resource := range resources {
+			objects = append(objects, resource)
+		}
+	}
+
+	for _, obj := range objects {
+		object, err := meta.Accessor(obj)
+		if err != nil || object.GetNamespace() != namespace {
+			continue
+		}
+		w.enqueue(gvr, obj)
+	}
+}
+
+// processNextResource audits the next resource in the queue. It returns
+// false when the queue has been shut down.
+func (w *watcher) processNextResource(ctx context.Context) bool {
+	key, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.queue.Done(key)
+
+	if err := w.auditResource(ctx, key); err != nil {
+		w.scanner.logger.ErrorContext(ctx, "error auditing resource",
+			slog.String("error", err.Error()),
+			slog.String("resource-GVR", key.gvr.String()),
+			slog.String("namespace", key.namespace),
+			slog.String("name", key.name))
+	}
+
+	return true
+}
+
+func (w *watcher) auditResource(ctx context.Context, key resourceKey) error {
+	w.mu.RLock()
+	runUID := w.runUID
+	informer, found := w.informers[key.gvr]
+	auditablePolicies := w.clusterPolicies
+	if key.namespace != "" {
+		auditablePolicies = w.namespacePolicies[key.namespace]
+	}
+	w.mu.RUnlock()
+
+	// the namespace is not audited, or the resource is no longer targeted by any policy
+	if !found || auditablePolicies == nil || len(auditablePolicies.PoliciesByGVR[key.gvr]) == 0 {
+		return nil
+	}
+
+	storeKey := key.name
+	if key.namespace != "" {
+		storeKey = key.namespace + "/" + key.name
+	}
+	obj, exists, err := informer.Informer().GetStore().GetByKey(storeKey)
+	if err != nil {
+		return fmt.Errorf("failed to get resource from cache: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	cachedResource, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return errors.New("failed to convert cached object to *unstructured.Unstructured")
+	}
+	// objects in the informer cache must not be modified
+	resource := cachedResource.DeepCopy()
+
+	policiesToAudit := auditablePolicies.PoliciesByGVR[key.gvr]
+	if key.namespace == "" {
+		w.scanner.auditClusterResource(ctx, policiesToAudit, key.gvr, *resource, runUID, auditablePolicies.SkippedNum, auditablePolicies.ErroredNum)
+		return nil
+	}
+
+	return w.scanner.auditResource(ctx, policiesToAudit, key.gvr, *resource, runUID, auditablePolicies.SkippedNum, auditablePolicies.ErroredNum)
+}
+
+// changedGroupVersionResources returns the resources whose auditing policies
+// changed: a policy has been added, removed, or its resourceVersion changed.
+func changedGroupVersionResources(previous, current *policies.Policies) []schema.GroupVersionResource {
+	previousFingerprints := policiesFingerprints(previous)
+	currentFingerprints := policiesFingerprints(current)
+
+	var changed []schema.GroupVersionResource
+	for gvr, fingerprint := range currentFingerprints {
+		if previousFingerprints[gvr] != fingerprint {
+			changed = append(changed, gvr)
+		}
+	}
+
+	return changed
+}
+
+// policiesFingerprints identifies the policies auditing each resource, with their resourceVersion.
+func policiesFingerprints(p *policies.Policies) map[schema.GroupVersionResource]string {
+	fingerprints := map[schema.GroupVersionResource]string{}
+	if p == nil {
+		return fingerprints
+	}
+
+	for gvr, pols := range p.PoliciesByGVR {
+		ids := make([]string, 0, len(pols))
+		for _, policy := range pols {
+			ids = append(ids, policy.GetUniqueName()+"@"+policy.GetResourceVersion()+"/"+string(policy.Operation))
+		}
+		slices.Sort(ids)
+		fingerprints[gvr] = strings.Join(ids, ",")
+	}
+
+	return fingerprints
+}
+
+func resourceVersionChanged(oldObj, newObj any) bool {
+	oldObject, err := meta.Accessor(oldObj)
+	if err != nil {
+		return true
+	}
+	newObject, err := meta.Accessor(newObj)
+	if err != nil {
+		return true
+	}
+
+	return oldObject.GetResourceVersion() != newObject.GetResourceVersion()
+}
+
+func labelsChanged(oldObj, newObj any) bool {
+	oldObject, err := meta.Accessor(oldObj)
+	if err != nil {
+		return true
+	}
+	newObject, err := meta.Accessor(newObj)
+	if err != nil {
+		return true
+	}
+
+	return !maps.Equal(oldObject.GetLabels(), newObject.GetLabels())
+}
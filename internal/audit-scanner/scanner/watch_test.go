@@ -0,0 +1,250 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/k8s"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/policies"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	auditscheme "github.com/kubewarden/kubewarden-controller/internal/audit-scanner/scheme"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	openreports "github.com/openreports/reports-api/apis/openreports.io/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatchAuditsChangedResourcesAndPolicies(t *testing.T) {
+	var requests atomic.Int32
+	mockPolicyServer := newMockPolicyServerWithRequestCounter(&requests)
+	defer mockPolicyServer.Close()
+
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+		},
+	}
+
+	pod1 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pod1",
+			Namespace:       "namespace1",
+			UID:             "pod1-uid",
+			ResourceVersion: "1",
+		},
+	}
+
+	podRule := admissionregistrationv1.Rule{
+		APIGroups:   []string{""},
+		APIVersions: []string{"v1"},
+		Resources:   []string{"pods"},
+	}
+
+	// an AdmissionPolicy targeting pods in namespace1
+	admissionPolicy1 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy1").
+		Namespace("namespace1").
+		Rule(podRule).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(
+		auditScheme,
+		pod1,
+		namespace1)
+	clientset := fake.NewClientset(
+		namespace1,
+	)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		admissionPolicy1,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", mockPolicyServer.URL, nil, logger)
+	openReportStore := report.NewOpenReportStore(client, report.WriterConfig{}, logger)
+
+	config := newTestConfig(policiesClient, k8sClient, openReportStore)
+	config.ReportKind = report.ReportKindOpenReport
+	scanner, err := NewScanner(config)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- scanner.Watch(ctx, time.Hour)
+	}()
+
+	getReport := func() *openreports.Report {
+		policyReport := openreports.Report{}
+		if err := client.Get(t.Context(), types.NamespacedName{Name: string(pod1.GetUID()), Namespace: "namespace1"}, &policyReport); err != nil {
+			return nil
+		}
+		return &policyReport
+	}
+
+	// the resources are audited on start
+	require.Eventually(t, func() bool {
+		return getReport() != nil && requests.Load() == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// the resource changed, it must be audited again
+	pod1.ResourceVersion = "2"
+	pod1.Labels = map[string]string{"env": "prod"}
+	_, err = dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).
+		Namespace("namespace1").
+		Update(t.Context(), toUnstructured(t, pod1, corev1.SchemeGroupVersion.WithKind("Pod")), metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return requests.Load() == 2
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// a new policy targets the resource, it must be audited again with both policies
+	admissionPolicy2 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy2").
+		Namespace("namespace1").
+		Rule(podRule).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+	require.NoError(t, client.Create(t.Context(), admissionPolicy2))
+	_, err = dynamicClient.Resource(schema.GroupVersionResource{Group: policiesv1.GroupVersion.Group, Version: policiesv1.GroupVersion.Version, Resource: "admissionpolicies"}).
+		Namespace("namespace1").
+		Create(t.Context(), toUnstructured(t, admissionPolicy2, policiesv1.GroupVersion.WithKind("AdmissionPolicy")), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		policyReport := getReport()
+		return policyReport != nil && len(policyReport.Results) == 2
+	}, 10*time.Second, 50*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-watchErr)
+	assert.Equal(t, int32(4), requests.Load())
+}
+
+func TestWatchStopsTheInformersOfTheResourcesNoLongerTargeted(t *testing.T) {
+	policyServer := &policiesv1.PolicyServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}
+
+	policyServerService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/instance": "policy-server-default",
+			},
+			Name:      "policy-server-default",
+			Namespace: "kubewarden",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "http",
+					Port: 443,
+				},
+			},
+		},
+	}
+
+	namespace1 := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "namespace1",
+		},
+	}
+
+	// an AdmissionPolicy targeting pods in namespace1
+	admissionPolicy1 := testutils.
+		NewAdmissionPolicyFactory().
+		Name("admissionPolicy1").
+		Namespace("namespace1").
+		Rule(admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"pods"},
+		}).
+		Status(policiesv1.PolicyStatusActive).
+		Build()
+
+	auditScheme, err := auditscheme.NewScheme()
+	require.NoError(t, err)
+	dynamicClient := dynamicFake.NewSimpleDynamicClient(auditScheme, namespace1)
+	clientset := fake.NewClientset(namespace1)
+	client, err := testutils.NewFakeClient(
+		namespace1,
+		policyServer,
+		policyServerService,
+		admissionPolicy1,
+	)
+	require.NoError(t, err)
+
+	logger := slog.Default()
+	k8sClient := k8s.NewClient(dynamicClient, clientset, "kubewarden", nil, pageSize, logger)
+	policiesClient := policies.NewClient(client, nil, "kubewarden", "http://policy-server", nil, logger)
+	scanner, err := NewScanner(newTestConfig(policiesClient, k8sClient, report.NewOpenReportStore(client, report.WriterConfig{}, logger)))
+	require.NoError(t, err)
+
+	factory, err := k8sClient.NewInformerFactory(0)
+	require.NoError(t, err)
+	watcher := &watcher{
+		scanner:           scanner,
+		factory:           factory,
+		namespacePolicies: map[string]*policies.Policies{},
+		informers:         map[schema.GroupVersionResource]*resourceInformer{},
+	}
+	defer watcher.stopInformers(t.Context(), nil)
+
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	require.NoError(t, watcher.refreshPolicies(t.Context(), false))
+	require.Contains(t, watcher.informers, podsGVR)
+	assert.True(t, watcher.informers[podsGVR].Informer().HasSynced())
+
+	// the policy is deleted, the pods are no longer watched
+	require.NoError(t, client.Delete(t.Context(), admissionPolicy1))
+	require.NoError(t, watcher.refreshPolicies(t.Context(), false))
+	assert.Empty(t, watcher.informers)
+}
@@ -0,0 +1,133 @@
+package sink
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+)
+
+// FanOutStore is a report.Store writing the reports to several stores, like
+// a CRD store and the sinks. The reads are served by the first store.
+type FanOutStore struct {
+	report.Store
+	// stores are all the stores written, including the first one
+	stores []report.Store
+}
+
+// NewFanOutStore returns a store writing to all the given stores, and
+// reading from the first one. At least one store is required.
+func NewFanOutStore(stores ...report.Store) *FanOutStore {
+	return &FanOutStore{
+		Store:  stores[0],
+		stores: stores,
+	}
+}
+
+// CreateOrPatchReport stores the given report in all the stores.
+func (s *FanOutStore) CreateOrPatchReport(ctx context.Context, obj any) error {
+	return s.forEach(func(store report.Store) error {
+		return store.CreateOrPatchReport(ctx, obj)
+	})
+}
+
+// DeleteOldReports deletes the old reports of the given namespace from all
+// the stores.
+func (s *FanOutStore) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
+	return s.forEach(func(store report.Store) error {
+		return store.DeleteOldReports(ctx, scanRunID, namespace)
+	})
+}
+
+// CreateOrPatchClusterReport stores the given cluster report in all the stores.
+func (s *FanOutStore) CreateOrPatchClusterReport(ctx context.Context, obj any) error {
+	return s.forEach(func(store report.Store) error {
+		return store.CreateOrPatchClusterReport(ctx, obj)
+	})
+}
+
+// DeleteOldClusterReports deletes the old cluster reports from all the stores.
+func (s *FanOutStore) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
+	return s.forEach(func(store report.Store) error {
+		return store.DeleteOldClusterReports(ctx, scanRunID)
+	})
+}
+
+// DeleteOldPolicyResultsReports deletes the old reports of the policies from
+// all the stores.
+func (s *FanOutStore) DeleteOldPolicyResultsReports(ctx context.Context, scanRunID string) error {
+	return s.forEach(func(store report.Store) error {
+		return store.DeleteOldPolicyResultsReports(ctx, scanRunID)
+	})
+}
+
+// Close closes the sinks among the stores.
+func (s *FanOutStore) Close(ctx context.Context) error {
+	return s.forEach(func(store report.Store) error {
+		if sink, ok := store.(*Sink); ok {
+			return sink.Close(ctx)
+		}
+		return nil
+	})
+}
+
+// forEach calls the given function with each store, a failing store does not
+// prevent the others from being written.
+func (s *FanOutStore) forEach(fn func(store report.Store) error) error {
+	var err error
+	for _, store := range s.stores {
+		err = errors.Join(err, fn(store))
+	}
+	return err
+}
+
+// FanOutRunSummaryStore is a report.RunSummaryStore sending the summaries of
+// the runs to the sinks too. The reads and the deletions are served by the
+// wrapped store, the sinks only send the summaries.
+type FanOutRunSummaryStore struct {
+	// store keeps the summaries, it's nil when they are only sent
+	store report.RunSummaryStore
+	sinks []*Sink
+}
+
+// NewFanOutRunSummaryStore returns a store keeping the summaries in the given
+// store, when not nil, and sending them to the given sinks.
+func NewFanOutRunSummaryStore(store report.RunSummaryStore, sinks ...*Sink) *FanOutRunSummaryStore {
+	return &FanOutRunSummaryStore{
+		store: store,
+		sinks: sinks,
+	}
+}
+
+// CreateOrPatchRunSummary stores the summary of a scan run and sends it to
+// all the sinks.
+func (s *FanOutRunSummaryStore) CreateOrPatchRunSummary(ctx context.Context, namespace string, summary *report.RunSummary) error {
+	var err error
+	if s.store != nil {
+		err = s.store.CreateOrPatchRunSummary(ctx, namespace, summary)
+	}
+	for _, sink := range s.sinks {
+		err = errors.Join(err, sink.CreateOrPatchRunSummary(ctx, namespace, summary))
+	}
+	return err
+}
+
+// GetRunSummaries returns the summaries of the given scan run kept by the
+// wrapped store.
+func (s *FanOutRunSummaryStore) GetRunSummaries(ctx context.Context, scanRunID, namespace string) ([]report.RunSummary, error) {
+	if s.store == nil {
+		return nil, nil
+	}
+	//nolint:wrapcheck // the stores already wrap the errors with context
+	return s.store.GetRunSummaries(ctx, scanRunID, namespace)
+}
+
+// DeleteOldRunSummaries deletes the summaries of the old scan runs kept by
+// the wrapped store.
+func (s *FanOutRunSummaryStore) DeleteOldRunSummaries(ctx context.Context, scanRunID, namespace string) error {
+	if s.store == nil {
+		return nil
+	}
+	//nolint:wrapcheck // the stores already wrap the errors with context
+	return s.store.DeleteOldRunSummaries(ctx, scanRunID, namespace)
+}
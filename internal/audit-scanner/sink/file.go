@@ -0,0 +1,66 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// fileWriter appends the events to a file, an event per line.
+type fileWriter struct {
+	mutex   sync.Mutex
+	file    *os.File
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewFileSink returns a Sink appending the events to the given file, a JSON
+// encoded CloudEvent per line. The file is created when it does not exist.
+func NewFileSink(path string, logger *slog.Logger) (*Sink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the report sink file: %w", err)
+	}
+	buffer := bufio.NewWriter(file)
+
+	return newSink(&fileWriter{
+		file:    file,
+		buffer:  buffer,
+		encoder: json.NewEncoder(buffer),
+	}, logger.With("component", "filesink")), nil
+}
+
+func (w *fileWriter) write(_ context.Context, events []Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, event := range events {
+		if err := w.encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to write the event: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *fileWriter) flush(_ context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.buffer.Flush(); err != nil {
+		return fmt.Errorf("failed to write the events to the file: %w", err)
+	}
+	return nil
+}
+
+func (w *fileWriter) close(ctx context.Context) error {
+	flushErr := w.flush(ctx)
+	if err := w.file.Close(); err != nil {
+		return errors.Join(flushErr, fmt.Errorf("failed to close the file: %w", err))
+	}
+	return flushErr
+}
@@ -0,0 +1,174 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/httpclient"
+)
+
+const (
+	defaultHTTPTimeout   = 10 * time.Second
+	defaultHTTPBatchSize = 100
+)
+
+// HTTPConfig configures the Sink posting the events to an HTTP endpoint.
+type HTTPConfig struct {
+	// URL of the endpoint receiving the batches of events
+	URL string
+	// CAFile is the CA certificate validating the endpoint, in PEM format.
+	// The system CAs are used too
+	CAFile string
+	// ClientCertFile and ClientKeyFile are the client certificate and key,
+	// in PEM format, authenticating the scanner to the endpoint with mTLS
+	ClientCertFile string
+	ClientKeyFile  string
+	// Timeout of each request. Defaults to 10 seconds
+	Timeout time.Duration
+	// MaxRetries is the number of times a batch failing with a network error
+	// or with the 429 or a 5xx status code is sent again
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. It's doubled at
+	// each retry, up to MaxBackoff, and randomized with a jitter
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// BatchSize is the number of events sent with each request. Defaults to 100
+	BatchSize int
+}
+
+// httpWriter posts the events to an HTTP endpoint, in batches.
+type httpWriter struct {
+	config HTTPConfig
+	client *http.Client
+	logger *slog.Logger
+
+	mutex  sync.Mutex
+	events []Event
+}
+
+// NewHTTPSink returns a Sink posting the events to the given endpoint, as
+// batches of CloudEvents in the JSON batched format. The events are sent once
+// a batch is full, and when a run is completed.
+func NewHTTPSink(config HTTPConfig, logger *slog.Logger) (*Sink, error) {
+	if config.URL == "" {
+		return nil, errors.New("the URL of the HTTP report sink is required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultHTTPTimeout
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultHTTPBatchSize
+	}
+
+	tlsConfig, err := httpclient.NewTLSConfig(config.CAFile, config.ClientCertFile, config.ClientKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("failed to build httpClient: failed http.Transport type assertion")
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	logger = logger.With("component", "httpsink")
+	return newSink(&httpWriter{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout, Transport: transport},
+		logger: logger,
+	}, logger), nil
+}
+
+func (w *httpWriter) write(ctx context.Context, events []Event) error {
+	w.mutex.Lock()
+	w.events = append(w.events, events...)
+	if len(w.events) < w.config.BatchSize {
+		w.mutex.Unlock()
+		return nil
+	}
+	batch := w.events
+	w.events = nil
+	w.mutex.Unlock()
+
+	return w.post(ctx, batch)
+}
+
+func (w *httpWriter) flush(ctx context.Context) error {
+	w.mutex.Lock()
+	batch := w.events
+	w.events = nil
+	w.mutex.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return w.post(ctx, batch)
+}
+
+func (w *httpWriter) close(ctx context.Context) error {
+	defer w.client.CloseIdleConnections()
+
+	return w.flush(ctx)
+}
+
+// post sends a batch of events, retrying when the endpoint is unreachable or
+// unavailable. The batch is dropped when all the attempts fail.
+func (w *httpWriter) post(ctx context.Context, batch []Event) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode the events: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		retryable, postErr := w.postOnce(ctx, body)
+		if postErr == nil {
+			return nil
+		}
+		if !retryable || attempt >= w.config.MaxRetries {
+			return fmt.Errorf("failed to post %d events: %w", len(batch), postErr)
+		}
+
+		delay := httpclient.Backoff(attempt, w.config.InitialBackoff, w.config.MaxBackoff)
+		w.logger.DebugContext(ctx, "retrying to post the events",
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", delay),
+			slog.String("error", postErr.Error()))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to post %d events: %w", len(batch), errors.Join(postErr, ctx.Err()))
+		case <-time.After(delay):
+		}
+	}
+}
+
+// postOnce sends the encoded batch, it returns true with the errors worth a
+// retry.
+func (w *httpWriter) postOnce(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to build the request: %w", err)
+	}
+	req.Header.Set("Content-Type", eventBatchContentType)
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("failed to send the request: %w", err)
+	}
+	defer res.Body.Close()
+	// drain the body, so the connection is reused
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+		return false, nil
+	}
+	retryable := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+	return retryable, fmt.Errorf("the report sink returned status code %d", res.StatusCode)
+}
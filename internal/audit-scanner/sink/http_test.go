@@ -0,0 +1,108 @@
+package sink
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventsServer is an endpoint receiving batches of events. The first
+// requests fail with the given status codes.
+type eventsServer struct {
+	mutex    sync.Mutex
+	failures []int
+	requests int
+	batches  [][]Event
+}
+
+func (s *eventsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests++
+	if len(s.failures) > 0 {
+		w.WriteHeader(s.failures[0])
+		s.failures = s.failures[1:]
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/cloudevents-batch+json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	var batch []Event
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.batches = append(s.batches, batch)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func TestHTTPSink(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         []int
+		expectedRequests int
+		expectedError    bool
+	}{
+		{"sent", nil, 1, false},
+		{"retried when the endpoint is unavailable", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, false},
+		{"dropped when the retries are exhausted", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 3, true},
+		{"not retried when the batch is rejected", []int{http.StatusBadRequest}, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &eventsServer{failures: test.failures}
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			httpSink, err := NewHTTPSink(HTTPConfig{
+				URL:        httpServer.URL,
+				MaxRetries: 2,
+				BatchSize:  10,
+			}, slog.Default())
+			require.NoError(t, err)
+
+			// the results are kept until the namespace is completed
+			resourceReport, _ := newTestReport(t, "run")
+			require.NoError(t, httpSink.CreateOrPatchReport(t.Context(), resourceReport))
+			assert.Zero(t, server.requests)
+
+			err = httpSink.DeleteOldReports(t.Context(), "run", "namespace")
+			if test.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, server.batches, 1)
+				require.Len(t, server.batches[0], 2)
+				assert.Equal(t, EventTypeResult, server.batches[0][0].Type)
+				assert.Equal(t, EventTypeRunCompleted, server.batches[0][1].Type)
+			}
+			assert.Equal(t, test.expectedRequests, server.requests)
+			require.NoError(t, httpSink.Close(t.Context()))
+		})
+	}
+}
+
+func TestHTTPSinkBatchSize(t *testing.T) {
+	server := &eventsServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	httpSink, err := NewHTTPSink(HTTPConfig{URL: httpServer.URL, BatchSize: 1}, slog.Default())
+	require.NoError(t, err)
+
+	// a full batch is sent right away
+	resourceReport, _ := newTestReport(t, "run")
+	require.NoError(t, httpSink.CreateOrPatchReport(t.Context(), resourceReport))
+	assert.Len(t, server.batches, 1)
+	require.NoError(t, httpSink.Close(t.Context()))
+	assert.Len(t, server.batches, 1)
+}
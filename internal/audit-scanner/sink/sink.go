@@ -0,0 +1,224 @@
+// Package sink sends the audit results to the systems collecting them out of
+// the cluster, like a SIEM, as CloudEvents.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Kind is the kind of destination of the audit results.
+type Kind string
+
+const (
+	// KindCRD stores the reports in the cluster, as PolicyReports or OpenReports
+	KindCRD Kind = "crd"
+	// KindHTTP posts the results as batches of CloudEvents to an HTTP endpoint
+	KindHTTP Kind = "http"
+	// KindFile appends the results as CloudEvents to a JSON lines file
+	KindFile Kind = "file"
+)
+
+// SupportedKinds returns the kinds of destination of the audit results.
+func SupportedKinds() []Kind {
+	return []Kind{KindCRD, KindHTTP, KindFile}
+}
+
+// The types of the events sent by the sinks.
+const (
+	// EventTypeResult is the type of the events holding a policy result, the
+	// data is a report.ResultEntry
+	EventTypeResult = "io.kubewarden.audit.result"
+	// EventTypeRunCompleted is the type of the events sent when the scan run
+	// audited all the resources of a namespace, or all the cluster wide
+	// resources. The data is a RunCompleted
+	EventTypeRunCompleted = "io.kubewarden.audit.run.completed"
+	// EventTypeRunSummary is the type of the events holding the summary of a
+	// scan run, the data is a report.RunSummary
+	EventTypeRunSummary = "io.kubewarden.audit.run.summary"
+
+	eventSource           = "kubewarden/audit-scanner"
+	eventSpecVersion      = "1.0"
+	eventDataContentType  = "application/json"
+	eventBatchContentType = "application/cloudevents-batch+json"
+)
+
+// Event is a CloudEvent, in the JSON structured format.
+type Event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            any       `json:"data"`
+}
+
+// RunCompleted is the data of the run completed events. They are sent when
+// the CRD stores delete the reports of the previous runs.
+type RunCompleted struct {
+	RunUID string `json:"runUID"`
+	// Namespace is the audited namespace, empty for the cluster wide resources
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func newEvent(eventType, subject string, eventTime time.Time, data any) Event {
+	return Event{
+		SpecVersion:     eventSpecVersion,
+		ID:              uuid.New().String(),
+		Source:          eventSource,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            eventTime,
+		DataContentType: eventDataContentType,
+		Data:            data,
+	}
+}
+
+// writer writes the events to the destination of a Sink. It's safe for
+// concurrent use.
+type writer interface {
+	// write sends the given events, or keeps them to send them later
+	write(ctx context.Context, events []Event) error
+	// flush sends the events kept by the previous writes
+	flush(ctx context.Context) error
+	// close flushes the events and releases the destination
+	close(ctx context.Context) error
+}
+
+// Sink is a report.Store sending the audit results as CloudEvents, a result
+// event for each policy result of the stored reports. The deletion of the
+// reports of the previous runs is sent as a run completed event, and the
+// summaries of the runs are sent as run summary events.
+//
+// The sinks never read back what they write: the reports are never found.
+type Sink struct {
+	writer writer
+	logger *slog.Logger
+}
+
+func newSink(writer writer, logger *slog.Logger) *Sink {
+	return &Sink{
+		writer: writer,
+		logger: logger,
+	}
+}
+
+// GetReport always returns constants.ErrResourceNotFound.
+func (s *Sink) GetReport(_ context.Context, _ unstructured.Unstructured) (report.Report, error) {
+	return nil, constants.ErrResourceNotFound
+}
+
+// CreateOrPatchReport sends the results of the given report.
+func (s *Sink) CreateOrPatchReport(ctx context.Context, obj any) error {
+	return s.writeResults(ctx, obj)
+}
+
+// DeleteOldReports sends a run completed event for the given namespace.
+func (s *Sink) DeleteOldReports(ctx context.Context, scanRunID, namespace string) error {
+	return s.writeRunCompleted(ctx, scanRunID, namespace)
+}
+
+// GetClusterReport always returns constants.ErrResourceNotFound.
+func (s *Sink) GetClusterReport(_ context.Context, _ unstructured.Unstructured) (report.Report, error) {
+	return nil, constants.ErrResourceNotFound
+}
+
+// CreateOrPatchClusterReport sends the results of the given cluster report.
+func (s *Sink) CreateOrPatchClusterReport(ctx context.Context, obj any) error {
+	return s.writeResults(ctx, obj)
+}
+
+// DeleteOldClusterReports sends a run completed event for the cluster wide
+// resources.
+func (s *Sink) DeleteOldClusterReports(ctx context.Context, scanRunID string) error {
+	return s.writeRunCompleted(ctx, scanRunID, "")
+}
+
+// DeleteOldPolicyResultsReports does nothing: the reports of the policies are
+// not sent, their results are the ones of the reports of the resources.
+func (s *Sink) DeleteOldPolicyResultsReports(_ context.Context, _ string) error {
+	return nil
+}
+
+// CreateOrPatchRunSummary sends the summary of a scan run. It's the last
+// event of the run, the events kept until now are sent with it.
+func (s *Sink) CreateOrPatchRunSummary(ctx context.Context, _ string, summary *report.RunSummary) error {
+	event := newEvent(EventTypeRunSummary, summary.RunUID, time.Now(), summary)
+	if err := s.writer.write(ctx, []Event{event}); err != nil {
+		return fmt.Errorf("failed to send the summary of run %s: %w", summary.RunUID, err)
+	}
+	return s.flush(ctx)
+}
+
+// Close sends the events not sent yet and releases the destination of the
+// sink.
+func (s *Sink) Close(ctx context.Context) error {
+	if err := s.writer.close(ctx); err != nil {
+		return fmt.Errorf("failed to close the report sink: %w", err)
+	}
+	return nil
+}
+
+func (s *Sink) writeResults(ctx context.Context, obj any) error {
+	resourceReport, ok := obj.(report.Report)
+	if !ok {
+		return fmt.Errorf("expected report.Report, got %T", obj)
+	}
+	// the results of the reports of the policies are the ones of the reports
+	// of the resources, which are already sent
+	if report.IsPolicyResultsReport(resourceReport) {
+		return nil
+	}
+
+	entries := resourceReport.Entries()
+	if len(entries) == 0 {
+		return nil
+	}
+	events := make([]Event, 0, len(entries))
+	for _, entry := range entries {
+		// the policy and the audited resource
+		subject := fmt.Sprintf("%s/%s", entry.Policy, entry.Resource.UID)
+		events = append(events, newEvent(EventTypeResult, subject, entry.Timestamp, entry))
+	}
+	if err := s.writer.write(ctx, events); err != nil {
+		return fmt.Errorf("failed to send the results of run %s: %w", entries[0].RunUID, err)
+	}
+
+	s.logger.DebugContext(ctx, "Results sent",
+		slog.String("resource-name", entries[0].Resource.Name),
+		slog.String("resource-namespace", entries[0].Resource.Namespace),
+		slog.Int("results", len(entries)))
+	return nil
+}
+
+func (s *Sink) writeRunCompleted(ctx context.Context, scanRunID, namespace string) error {
+	event := newEvent(EventTypeRunCompleted, scanRunID, time.Now(), RunCompleted{
+		RunUID:    scanRunID,
+		Namespace: namespace,
+	})
+	if err := s.writer.write(ctx, []Event{event}); err != nil {
+		return fmt.Errorf("failed to send the completion of run %s: %w", scanRunID, err)
+	}
+	s.logger.DebugContext(ctx, "Run completed event sent",
+		slog.String("RunUID", scanRunID),
+		slog.String("namespace", namespace))
+
+	// the results of the namespace are all sent once it's completed
+	return s.flush(ctx)
+}
+
+func (s *Sink) flush(ctx context.Context) error {
+	if err := s.writer.flush(ctx); err != nil {
+		return fmt.Errorf("failed to send the events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	policiesv1 "github.com/kubewarden/kubewarden-controller/api/policies/v1"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/constants"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/report"
+	"github.com/kubewarden/kubewarden-controller/internal/audit-scanner/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	wgpolicy "sigs.k8s.io/wg-policy-prototypes/policy-report/pkg/api/wgpolicyk8s.io/v1alpha2"
+)
+
+func newTestReport(t *testing.T, runUID string) (report.Report, unstructured.Unstructured) {
+	t.Helper()
+
+	resource := unstructured.Unstructured{}
+	resource.SetAPIVersion("v1")
+	resource.SetKind("Pod")
+	resource.SetName("pod")
+	resource.SetNamespace("namespace")
+	resource.SetUID("pod-uid")
+
+	policy := &policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "namespace"},
+	}
+	rejected := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Message: "rejected"},
+		},
+	}
+
+	resourceReport := report.NewReportOfKind(report.ReportKindPolicyReport, runUID, resource)
+	resourceReport.AddResult(policy, rejected, false)
+	return resourceReport, resource
+}
+
+// readEvents returns the events of the given JSON lines file.
+func readEvents(t *testing.T, path string) []map[string]any {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var events []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		event := map[string]any{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	fileSink, err := NewFileSink(path, slog.Default())
+	require.NoError(t, err)
+
+	resourceReport, resource := newTestReport(t, "run")
+	require.NoError(t, fileSink.CreateOrPatchReport(t.Context(), resourceReport))
+	require.NoError(t, fileSink.DeleteOldReports(t.Context(), "run", "namespace"))
+	require.NoError(t, fileSink.DeleteOldClusterReports(t.Context(), "run"))
+	require.NoError(t, fileSink.Close(t.Context()))
+
+	// the sink never reads back the results
+	_, err = fileSink.GetReport(t.Context(), resource)
+	require.ErrorIs(t, err, constants.ErrResourceNotFound)
+
+	events := readEvents(t, path)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, "1.0", events[0]["specversion"])
+	assert.Equal(t, "kubewarden/audit-scanner", events[0]["source"])
+	assert.Equal(t, EventTypeResult, events[0]["type"])
+	assert.Equal(t, "namespaced-namespace-policy/pod-uid", events[0]["subject"])
+	assert.NotEmpty(t, events[0]["id"])
+	data, ok := events[0]["data"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "run", data["runUID"])
+	assert.Equal(t, report.ResultFail, data["result"])
+	assert.Equal(t, "rejected", data["message"])
+
+	assert.Equal(t, EventTypeRunCompleted, events[1]["type"])
+	assert.Equal(t, map[string]any{"runUID": "run", "namespace": "namespace"}, events[1]["data"])
+	assert.Equal(t, EventTypeRunCompleted, events[2]["type"])
+	assert.Equal(t, map[string]any{"runUID": "run"}, events[2]["data"])
+}
+
+func TestFileSinkSkipsPolicyReports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	fileSink, err := NewFileSink(path, slog.Default())
+	require.NoError(t, err)
+
+	policy := &policiesv1.AdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "namespace"},
+	}
+	rejected := &admissionv1.AdmissionReview{
+		Response: &admissionv1.AdmissionResponse{Allowed: false},
+	}
+	_, resource := newTestReport(t, "run")
+	policyResultsReport := report.NewPolicyResultsReport(fileSink, report.ReportKindPolicyReport, "run", policy, "")
+	require.NoError(t, policyResultsReport.AddPolicyResult(policy, resource, rejected, false))
+	require.NoError(t, policyResultsReport.Flush(t.Context()))
+	require.NoError(t, fileSink.Close(t.Context()))
+
+	// the results are sent with the report of the resource only
+	assert.Empty(t, readEvents(t, path))
+}
+
+func TestFanOutStore(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	crdStore := report.NewPolicyReportStore(fakeClient, report.WriterConfig{}, slog.Default())
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	fileSink, err := NewFileSink(path, slog.Default())
+	require.NoError(t, err)
+
+	store := NewFanOutStore(crdStore, fileSink)
+	resourceReport, resource := newTestReport(t, "run")
+	require.NoError(t, store.CreateOrPatchReport(t.Context(), resourceReport))
+	require.NoError(t, store.Close(t.Context()))
+
+	// the report is stored in the cluster, and read back from it
+	policyReport := &wgpolicy.PolicyReport{}
+	require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Name: "pod-uid", Namespace: "namespace"}, policyReport))
+	storedReport, err := store.GetReport(t.Context(), resource)
+	require.NoError(t, err)
+	assert.Len(t, storedReport.Entries(), 1)
+
+	// and sent to the sink
+	events := readEvents(t, path)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventTypeResult, events[0]["type"])
+}
+
+func TestFanOutRunSummaryStore(t *testing.T) {
+	fakeClient, err := testutils.NewFakeClient()
+	require.NoError(t, err)
+	configMapStore := report.NewConfigMapStore(fakeClient, slog.Default())
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	fileSink, err := NewFileSink(path, slog.Default())
+	require.NoError(t, err)
+
+	store := NewFanOutRunSummaryStore(configMapStore, fileSink)
+	require.NoError(t, store.CreateOrPatchRunSummary(t.Context(), "kubewarden", &report.RunSummary{RunUID: "run"}))
+	require.NoError(t, fileSink.Close(t.Context()))
+
+	// the summary is stored in the cluster, and read back from it
+	summaries, err := store.GetRunSummaries(t.Context(), "run", "kubewarden")
+	require.NoError(t, err)
+	assert.Len(t, summaries, 1)
+
+	// and sent to the sink
+	events := readEvents(t, path)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventTypeRunSummary, events[0]["type"])
+
+	// the summaries are only sent without a store keeping them
+	store = NewFanOutRunSummaryStore(nil)
+	require.NoError(t, store.CreateOrPatchRunSummary(t.Context(), "kubewarden", &report.RunSummary{RunUID: "run"}))
+	summaries, err = store.GetRunSummaries(t.Context(), "run", "kubewarden")
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+}
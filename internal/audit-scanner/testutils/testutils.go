@@ -80,6 +80,12 @@ func (factory *PolicyReportFactory) WithAppLabel() *PolicyReportFactory {
 	return factory
 }
 
+func (factory *PolicyReportFactory) WithPolicyResultsLabel() *PolicyReportFactory {
+	factory.labels["kubewarden.io/report-type"] = "policy"
+
+	return factory
+}
+
 func (factory *PolicyReportFactory) Build() *wgpolicy.PolicyReport {
 	return &wgpolicy.PolicyReport{
 		ObjectMeta: metav1.ObjectMeta{
@@ -128,6 +134,12 @@ func (factory *ClusterPolicyReportFactory) WithAppLabel() *ClusterPolicyReportFa
 	return factory
 }
 
+func (factory *ClusterPolicyReportFactory) WithPolicyResultsLabel() *ClusterPolicyReportFactory {
+	factory.labels["kubewarden.io/report-type"] = "policy"
+
+	return factory
+}
+
 func (factory *ClusterPolicyReportFactory) Build() *wgpolicy.ClusterPolicyReport {
 	return &wgpolicy.ClusterPolicyReport{
 		ObjectMeta: metav1.ObjectMeta{
@@ -151,6 +163,7 @@ type AdmissionPolicyFactory struct {
 	namespace       string
 	objectSelector  *metav1.LabelSelector
 	rules           []admissionregistrationv1.RuleWithOperations
+	matchConditions []admissionregistrationv1.MatchCondition
 	backgroundAudit bool
 	status          policiesv1.PolicyStatusEnum
 }
@@ -192,6 +205,14 @@ func (factory *AdmissionPolicyFactory) Rule(rule admissionregistrationv1.Rule, o
 	return factory
 }
 
+func (factory *AdmissionPolicyFactory) MatchCondition(name, expression string) *AdmissionPolicyFactory {
+	factory.matchConditions = append(factory.matchConditions, admissionregistrationv1.MatchCondition{
+		Name: name, Expression: expression,
+	})
+
+	return factory
+}
+
 func (factory *AdmissionPolicyFactory) BackgroundAudit(backgroundAudit bool) *AdmissionPolicyFactory {
 	factory.backgroundAudit = backgroundAudit
 
@@ -219,6 +240,7 @@ func (factory *AdmissionPolicyFactory) Build() *policiesv1.AdmissionPolicy {
 				ObjectSelector:  factory.objectSelector,
 				PolicyServer:    "default",
 				Rules:           factory.rules,
+				MatchConditions: factory.matchConditions,
 				BackgroundAudit: factory.backgroundAudit,
 			},
 		},
@@ -331,6 +353,7 @@ type ClusterAdmissionPolicyFactory struct {
 	namespaceSelector *metav1.LabelSelector
 	objectSelector    *metav1.LabelSelector
 	rules             []admissionregistrationv1.RuleWithOperations
+	matchConditions   []admissionregistrationv1.MatchCondition
 	backgroundAudit   bool
 	status            policiesv1.PolicyStatusEnum
 }
@@ -372,6 +395,14 @@ func (factory *ClusterAdmissionPolicyFactory) Rule(rule admissionregistrationv1.
 	return factory
 }
 
+func (factory *ClusterAdmissionPolicyFactory) MatchCondition(name, expression string) *ClusterAdmissionPolicyFactory {
+	factory.matchConditions = append(factory.matchConditions, admissionregistrationv1.MatchCondition{
+		Name: name, Expression: expression,
+	})
+
+	return factory
+}
+
 func (factory *ClusterAdmissionPolicyFactory) BackgroundAudit(backgroundAudit bool) *ClusterAdmissionPolicyFactory {
 	factory.backgroundAudit = backgroundAudit
 
@@ -399,6 +430,7 @@ func (factory *ClusterAdmissionPolicyFactory) Build() *policiesv1.ClusterAdmissi
 				ObjectSelector:  factory.objectSelector,
 				PolicyServer:    "default",
 				Rules:           factory.rules,
+				MatchConditions: factory.matchConditions,
 				BackgroundAudit: factory.backgroundAudit,
 			},
 		},